@@ -0,0 +1,179 @@
+package chunker
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	DEFAULT_MAX_TOKENS     = 200
+	DEFAULT_OVERLAP_TOKENS = 40
+)
+
+// Options controls chunk sizes. Tokens are approximated as whitespace
+// separated words, which is close enough to keep chunks well inside the
+// context window of common embedding models.
+type Options struct {
+	MaxTokens     int
+	OverlapTokens int
+}
+
+func DefaultOptions() Options {
+	return Options{
+		MaxTokens:     DEFAULT_MAX_TOKENS,
+		OverlapTokens: DEFAULT_OVERLAP_TOKENS,
+	}
+}
+
+type Chunk struct {
+	Index int
+	Text  string
+}
+
+var (
+	paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+	sentenceEnd    = regexp.MustCompile(`[.!?]+["')\]]*\s+`)
+)
+
+// Split breaks text into overlapping chunks of at most MaxTokens words. It
+// prefers to cut between paragraphs, then between sentences, and only splits
+// inside a sentence when that sentence alone is longer than a chunk. Text that
+// fits in a single chunk is returned unchanged as one chunk.
+func Split(text string, opts Options) []Chunk {
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = DEFAULT_MAX_TOKENS
+	}
+	if opts.OverlapTokens < 0 || opts.OverlapTokens >= opts.MaxTokens {
+		opts.OverlapTokens = 0
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if countTokens(text) <= opts.MaxTokens {
+		return []Chunk{{Index: 0, Text: text}}
+	}
+
+	var units []string
+	for _, paragraph := range paragraphBreak.Split(text, -1) {
+		for _, sentence := range splitSentences(paragraph) {
+			units = append(units, splitLongSentence(sentence, opts.MaxTokens)...)
+		}
+		// An empty unit marks a paragraph boundary so chunks can be joined
+		// back with blank lines where the source had them.
+		units = append(units, "")
+	}
+
+	var chunks []Chunk
+	var current []string
+	currentTokens := 0
+
+	flush := func() {
+		body := joinUnits(current)
+		if body != "" {
+			chunks = append(chunks, Chunk{Index: len(chunks), Text: body})
+		}
+		current, currentTokens = overlapTail(current, opts.OverlapTokens)
+	}
+
+	for _, unit := range units {
+		tokens := countTokens(unit)
+		if currentTokens+tokens > opts.MaxTokens && currentTokens > 0 {
+			flush()
+			// Drop the overlap if it would not leave room for the next unit.
+			if currentTokens+tokens > opts.MaxTokens {
+				current, currentTokens = nil, 0
+			}
+		}
+		current = append(current, unit)
+		currentTokens += tokens
+	}
+	if currentTokens > 0 {
+		before := len(chunks)
+		body := joinUnits(current)
+		// Skip a trailing chunk that would only repeat the previous overlap.
+		if before == 0 || !strings.HasSuffix(chunks[before-1].Text, body) {
+			chunks = append(chunks, Chunk{Index: before, Text: body})
+		}
+	}
+	return chunks
+}
+
+func countTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func splitSentences(paragraph string) []string {
+	paragraph = strings.TrimSpace(paragraph)
+	if paragraph == "" {
+		return nil
+	}
+
+	var sentences []string
+	start := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(paragraph, -1) {
+		sentences = append(sentences, strings.TrimSpace(paragraph[start:loc[1]]))
+		start = loc[1]
+	}
+	if rest := strings.TrimSpace(paragraph[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+func splitLongSentence(sentence string, maxTokens int) []string {
+	words := strings.Fields(sentence)
+	if len(words) <= maxTokens {
+		return []string{sentence}
+	}
+
+	var parts []string
+	for start := 0; start < len(words); start += maxTokens {
+		end := min(start+maxTokens, len(words))
+		parts = append(parts, strings.Join(words[start:end], " "))
+	}
+	return parts
+}
+
+// overlapTail returns the trailing sentences of units that fit within the
+// overlap budget, to be repeated at the start of the next chunk.
+func overlapTail(units []string, overlapTokens int) ([]string, int) {
+	if overlapTokens == 0 {
+		return nil, 0
+	}
+
+	tokens := 0
+	start := len(units)
+	for start > 0 {
+		t := countTokens(units[start-1])
+		if tokens+t > overlapTokens {
+			break
+		}
+		tokens += t
+		start--
+	}
+	tail := append([]string(nil), units[start:]...)
+	return tail, tokens
+}
+
+func joinUnits(units []string) string {
+	var b strings.Builder
+	pendingBreak := false
+	for _, unit := range units {
+		if unit == "" {
+			pendingBreak = b.Len() > 0
+			continue
+		}
+		if b.Len() > 0 {
+			if pendingBreak {
+				b.WriteString("\n\n")
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(unit)
+		pendingBreak = false
+	}
+	return b.String()
+}
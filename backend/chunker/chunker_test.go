@@ -0,0 +1,152 @@
+package chunker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// sentences returns n five-word sentences numbered from 1.
+func sentences(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("Sentence %d has five words.", i+1)
+	}
+	return out
+}
+
+// checkChunks verifies the properties every split must have.
+func checkChunks(t *testing.T, chunks []Chunk, opts Options) {
+	t.Helper()
+	for i, chunk := range chunks {
+		if chunk.Index != i {
+			t.Errorf("chunk %d has index %d", i, chunk.Index)
+		}
+		if tokens := countTokens(chunk.Text); tokens > opts.MaxTokens || tokens == 0 {
+			t.Errorf("chunk %d has %d tokens, want 1 to %d: %q", i, tokens, opts.MaxTokens, chunk.Text)
+		}
+	}
+}
+
+func TestSplitEmptyAndShort(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Chunk
+	}{
+		{"empty", "", nil},
+		{"whitespace", " \n\t\n ", nil},
+		{"short", "  One short note.\n\nWith two paragraphs.  ", []Chunk{{Index: 0, Text: "One short note.\n\nWith two paragraphs."}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split(tt.text, DefaultOptions())
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Split = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitOverlap(t *testing.T) {
+	opts := Options{MaxTokens: 15, OverlapTokens: 5}
+	units := sentences(10)
+	chunks := Split(strings.Join(units, " "), opts)
+	checkChunks(t, chunks, opts)
+
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+	for i := 1; i < len(chunks); i++ {
+		previous := strings.Fields(chunks[i-1].Text)
+		overlap := strings.Join(previous[len(previous)-5:], " ")
+		if !strings.HasPrefix(chunks[i].Text, overlap) {
+			t.Errorf("chunk %d = %q, want it to start with %q", i, chunks[i].Text, overlap)
+		}
+	}
+	all := joinChunks(chunks)
+	for _, unit := range units {
+		if !strings.Contains(all, unit) {
+			t.Errorf("sentence %q missing from chunks", unit)
+		}
+	}
+	if last := chunks[len(chunks)-1].Text; !strings.HasSuffix(last, units[len(units)-1]) {
+		t.Errorf("last chunk = %q, want it to end with the last sentence", last)
+	}
+}
+
+func TestSplitWithoutOverlap(t *testing.T) {
+	opts := Options{MaxTokens: 10, OverlapTokens: 0}
+	units := sentences(6)
+	chunks := Split(strings.Join(units, " "), opts)
+	checkChunks(t, chunks, opts)
+
+	want := []string{
+		units[0] + " " + units[1],
+		units[2] + " " + units[3],
+		units[4] + " " + units[5],
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d: %q", len(chunks), len(want), chunks)
+	}
+	for i := range want {
+		if chunks[i].Text != want[i] {
+			t.Errorf("chunk %d = %q, want %q", i, chunks[i].Text, want[i])
+		}
+	}
+}
+
+func TestSplitOversizedSentence(t *testing.T) {
+	opts := Options{MaxTokens: 10, OverlapTokens: 3}
+	words := make([]string, 25)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%d", i)
+	}
+	chunks := Split(strings.Join(words, " "), opts)
+	checkChunks(t, chunks, opts)
+
+	// Every word appears, in order, once overlaps are discounted.
+	next := 0
+	for _, chunk := range chunks {
+		for _, word := range strings.Fields(chunk.Text) {
+			if next < len(words) && word == words[next] {
+				next++
+			}
+		}
+	}
+	if next != len(words) {
+		t.Errorf("chunks dropped words after %q: %q", words[next-1], chunks)
+	}
+}
+
+func TestSplitKeepsParagraphBreaks(t *testing.T) {
+	opts := Options{MaxTokens: 12, OverlapTokens: 0}
+	units := sentences(4)
+	text := units[0] + "\n\n" + units[1] + "\n\n" + units[2] + " " + units[3]
+	chunks := Split(text, opts)
+	checkChunks(t, chunks, opts)
+
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2: %q", len(chunks), chunks)
+	}
+	if want := units[0] + "\n\n" + units[1]; chunks[0].Text != want {
+		t.Errorf("chunk 0 = %q, want %q", chunks[0].Text, want)
+	}
+}
+
+func TestSplitInvalidOptions(t *testing.T) {
+	text := strings.Join(sentences(100), " ")
+	chunks := Split(text, Options{MaxTokens: 0, OverlapTokens: 500})
+	checkChunks(t, chunks, DefaultOptions())
+	if len(chunks) < 2 {
+		t.Errorf("got %d chunks, want the default chunk size to apply", len(chunks))
+	}
+}
+
+func joinChunks(chunks []Chunk) string {
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	return strings.Join(texts, " ")
+}
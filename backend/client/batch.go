@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_BATCH_SIZE     = 32
+	DEFAULT_FLUSH_INTERVAL = 10 * time.Millisecond
+	DEFAULT_MAX_IN_FLIGHT  = 4
+)
+
+// BatchEmbedder is implemented by embedders that can embed several inputs in
+// a single request to the model server.
+type BatchEmbedder interface {
+	Embedder
+	GenerateEmbeddings(ctx context.Context, inputs []string) ([][]float64, error)
+}
+
+// GenerateEmbeddings embeds inputs with as few requests as embedder allows,
+// falling back to one request per input when it cannot batch.
+func GenerateEmbeddings(ctx context.Context, embedder Embedder, inputs []string) ([][]float64, error) {
+	if batcher, ok := embedder.(BatchEmbedder); ok {
+		return batcher.GenerateEmbeddings(ctx, inputs)
+	}
+
+	embeddings := make([][]float64, len(inputs))
+	for i, input := range inputs {
+		embedding, err := embedder.GenerateEmbedding(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = embedding
+	}
+	return embeddings, nil
+}
+
+// CoalescingEmbedder merges concurrent GenerateEmbedding calls into batched
+// requests. A batch is sent once it holds maxBatchSize inputs or when
+// flushInterval has passed since its first input arrived, whichever is first.
+// At most maxInFlight batches are sent at once; later ones wait for a slot.
+type CoalescingEmbedder struct {
+	embedder      BatchEmbedder
+	maxBatchSize  int
+	flushInterval time.Duration
+	inFlight      chan struct{}
+
+	mu      sync.Mutex
+	pending []*embeddingRequest
+	timer   *time.Timer
+}
+
+type embeddingRequest struct {
+	ctx    context.Context
+	input  string
+	result chan embeddingResult
+}
+
+type embeddingResult struct {
+	embedding []float64
+	err       error
+}
+
+func NewCoalescingEmbedder(embedder BatchEmbedder, maxBatchSize int, flushInterval time.Duration, maxInFlight int) *CoalescingEmbedder {
+	if maxBatchSize <= 0 {
+		maxBatchSize = DEFAULT_BATCH_SIZE
+	}
+	if flushInterval <= 0 {
+		flushInterval = DEFAULT_FLUSH_INTERVAL
+	}
+	if maxInFlight <= 0 {
+		maxInFlight = DEFAULT_MAX_IN_FLIGHT
+	}
+	return &CoalescingEmbedder{
+		embedder:      embedder,
+		maxBatchSize:  maxBatchSize,
+		flushInterval: flushInterval,
+		inFlight:      make(chan struct{}, maxInFlight),
+	}
+}
+
+func (e *CoalescingEmbedder) Model() string {
+	return e.embedder.Model()
+}
+
+// GenerateEmbedding queues input for the next batch and waits for its result.
+func (e *CoalescingEmbedder) GenerateEmbedding(ctx context.Context, input string) ([]float64, error) {
+	embeddings, err := e.GenerateEmbeddings(ctx, []string{input})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// GenerateEmbeddings queues inputs, which may share batches with other
+// callers or span several batches, and waits for all of their results.
+func (e *CoalescingEmbedder) GenerateEmbeddings(ctx context.Context, inputs []string) ([][]float64, error) {
+	requests := make([]*embeddingRequest, len(inputs))
+	e.mu.Lock()
+	for i, input := range inputs {
+		requests[i] = &embeddingRequest{ctx: ctx, input: input, result: make(chan embeddingResult, 1)}
+		e.pending = append(e.pending, requests[i])
+		switch {
+		case len(e.pending) >= e.maxBatchSize:
+			batch := e.takePending()
+			go e.send(batch)
+		case len(e.pending) == 1:
+			e.timer = time.AfterFunc(e.flushInterval, e.flush)
+		}
+	}
+	e.mu.Unlock()
+
+	embeddings := make([][]float64, len(inputs))
+	for i, request := range requests {
+		select {
+		case result := <-request.result:
+			if result.err != nil {
+				return nil, result.err
+			}
+			embeddings[i] = result.embedding
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return embeddings, nil
+}
+
+func (e *CoalescingEmbedder) flush() {
+	e.mu.Lock()
+	batch := e.takePending()
+	e.mu.Unlock()
+	e.send(batch)
+}
+
+// takePending must be called with mu held.
+func (e *CoalescingEmbedder) takePending() []*embeddingRequest {
+	batch := e.pending
+	e.pending = nil
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+	return batch
+}
+
+func (e *CoalescingEmbedder) send(batch []*embeddingRequest) {
+	e.inFlight <- struct{}{}
+	defer func() { <-e.inFlight }()
+
+	// Callers that gave up while queued, or while the batch waited for a
+	// slot, are dropped from the request.
+	live := batch[:0]
+	for _, request := range batch {
+		if request.ctx.Err() == nil {
+			live = append(live, request)
+		}
+	}
+	if len(live) == 0 {
+		return
+	}
+
+	inputs := make([]string, len(live))
+	for i, request := range live {
+		inputs[i] = request.input
+	}
+
+	// The batch outlives any single caller, so it runs on its own context;
+	// the HTTP client's timeout still bounds it.
+	embeddings, err := e.embedder.GenerateEmbeddings(context.Background(), inputs)
+	for i, request := range live {
+		if err != nil {
+			request.result <- embeddingResult{err: err}
+			continue
+		}
+		request.result <- embeddingResult{embedding: embeddings[i]}
+	}
+}
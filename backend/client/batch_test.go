@@ -0,0 +1,287 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchServer implements the OpenAI embeddings API, embedding the input "n"
+// as [n, 1] and returning the data in reverse order. It records the size of
+// every batch it receives.
+type batchServer struct {
+	*httptest.Server
+	status int
+
+	mu      sync.Mutex
+	batches []int
+	// delay holds each response back, so that batches overlap.
+	delay time.Duration
+	// active and peak count the requests being served at once.
+	active int
+	peak   int
+}
+
+func newBatchServer(t *testing.T) *batchServer {
+	s := &batchServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Input json.RawMessage `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var inputs []string
+		if err := json.Unmarshal(request.Input, &inputs); err != nil {
+			var input string
+			if err := json.Unmarshal(request.Input, &input); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			inputs = []string{input}
+		}
+
+		s.mu.Lock()
+		s.batches = append(s.batches, len(inputs))
+		status := s.status
+		s.active++
+		s.peak = max(s.peak, s.active)
+		s.mu.Unlock()
+		time.Sleep(s.delay)
+		s.mu.Lock()
+		s.active--
+		s.mu.Unlock()
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		var response OpenAIEmbeddingResponse
+		response.Data = make([]struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		}, len(inputs))
+		for i, input := range inputs {
+			n, _ := strconv.Atoi(input)
+			data := &response.Data[len(inputs)-1-i]
+			data.Index = i
+			data.Embedding = []float64{float64(n), 1}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *batchServer) embedder() *OpenAIEmbedder {
+	return NewOpenAIEmbedder("test server", EmbedderConfig{URL: s.URL, Model: "m", Timeout: time.Second})
+}
+
+func (s *batchServer) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.batches...)
+}
+
+func numberedInputs(n int) []string {
+	inputs := make([]string, n)
+	for i := range inputs {
+		inputs[i] = strconv.Itoa(i)
+	}
+	return inputs
+}
+
+func checkEmbeddings(t *testing.T, inputs []string, embeddings [][]float64) {
+	t.Helper()
+	if len(embeddings) != len(inputs) {
+		t.Fatalf("got %d embeddings for %d inputs", len(embeddings), len(inputs))
+	}
+	for i, input := range inputs {
+		n, _ := strconv.Atoi(input)
+		if want := []float64{float64(n), 1}; fmt.Sprint(embeddings[i]) != fmt.Sprint(want) {
+			t.Errorf("embedding %d = %v, want %v", i, embeddings[i], want)
+		}
+	}
+}
+
+func TestOpenAIBatch(t *testing.T) {
+	server := newBatchServer(t)
+	inputs := numberedInputs(5)
+
+	embeddings, err := server.embedder().GenerateEmbeddings(context.Background(), inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEmbeddings(t, inputs, embeddings)
+	if got := server.batchSizes(); fmt.Sprint(got) != "[5]" {
+		t.Errorf("batches = %v, want one batch of 5", got)
+	}
+
+	if embeddings, err := server.embedder().GenerateEmbeddings(context.Background(), nil); err != nil || len(embeddings) != 0 {
+		t.Errorf("empty batch = %v, %v", embeddings, err)
+	}
+	if got := server.batchSizes(); len(got) != 1 {
+		t.Errorf("batches = %v, want no request for the empty batch", got)
+	}
+}
+
+func TestCoalescingFanOut(t *testing.T) {
+	server := newBatchServer(t)
+	embedder := NewCoalescingEmbedder(server.embedder(), 4, 20*time.Millisecond, 0)
+	inputs := numberedInputs(10)
+
+	embeddings := make([][]float64, len(inputs))
+	errs := make([]error, len(inputs))
+	var wg sync.WaitGroup
+	for i, input := range inputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			embeddings[i], errs[i] = embedder.GenerateEmbedding(context.Background(), input)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("input %d: %v", i, err)
+		}
+	}
+	checkEmbeddings(t, inputs, embeddings)
+
+	total := 0
+	batches := server.batchSizes()
+	for _, size := range batches {
+		if size > 4 {
+			t.Errorf("batch of %d exceeds the maximum of 4", size)
+		}
+		total += size
+	}
+	if total != len(inputs) || len(batches) >= len(inputs) {
+		t.Errorf("batches = %v, want %d inputs in fewer requests", batches, len(inputs))
+	}
+}
+
+func TestCoalescingSpansBatches(t *testing.T) {
+	server := newBatchServer(t)
+	embedder := NewCoalescingEmbedder(server.embedder(), 4, time.Millisecond, 0)
+	inputs := numberedInputs(9)
+
+	embeddings, err := embedder.GenerateEmbeddings(context.Background(), inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEmbeddings(t, inputs, embeddings)
+	if got := server.batchSizes(); len(got) != 3 {
+		t.Errorf("batches = %v, want 3", got)
+	}
+}
+
+func TestCoalescingSharesErrors(t *testing.T) {
+	server := newBatchServer(t)
+	server.status = http.StatusServiceUnavailable
+	embedder := NewCoalescingEmbedder(server.embedder(), 3, 20*time.Millisecond, 0)
+
+	var wg sync.WaitGroup
+	for i := range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := embedder.GenerateEmbedding(context.Background(), strconv.Itoa(i)); !errors.Is(err, ErrUnavailable) {
+				t.Errorf("input %d: error = %v, want ErrUnavailable", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := server.batchSizes(); fmt.Sprint(got) != "[3]" {
+		t.Errorf("batches = %v, want one batch of 3", got)
+	}
+}
+
+func TestCoalescingLimitsBatchesInFlight(t *testing.T) {
+	server := newBatchServer(t)
+	server.delay = 20 * time.Millisecond
+	embedder := NewCoalescingEmbedder(server.embedder(), 2, time.Millisecond, 2)
+	inputs := numberedInputs(20)
+
+	embeddings, err := embedder.GenerateEmbeddings(context.Background(), inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEmbeddings(t, inputs, embeddings)
+	if got := server.batchSizes(); len(got) != 10 {
+		t.Errorf("batches = %v, want 10", got)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.peak != 2 {
+		t.Errorf("server saw %d batches at once, want 2", server.peak)
+	}
+}
+
+func TestCoalescingDropsCancelledCallers(t *testing.T) {
+	server := newBatchServer(t)
+	embedder := NewCoalescingEmbedder(server.embedder(), 10, 30*time.Millisecond, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := embedder.GenerateEmbedding(ctx, "1")
+		cancelled <- err
+	}()
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller got %v, want context.Canceled", err)
+	}
+
+	embedding, err := embedder.GenerateEmbedding(context.Background(), "2")
+	if err != nil || embedding[0] != 2 {
+		t.Fatalf("GenerateEmbedding = %v, %v", embedding, err)
+	}
+	if got := server.batchSizes(); fmt.Sprint(got) != "[1]" {
+		t.Errorf("batches = %v, want the cancelled input left out", got)
+	}
+}
+
+// singleEmbedder cannot batch, so GenerateEmbeddings must call it per input.
+type singleEmbedder struct {
+	calls int
+}
+
+func (e *singleEmbedder) Model() string { return "single" }
+
+func (e *singleEmbedder) GenerateEmbedding(ctx context.Context, input string) ([]float64, error) {
+	e.calls++
+	if input == "fail" {
+		return nil, errors.New("failed")
+	}
+	n, _ := strconv.Atoi(input)
+	return []float64{float64(n), 1}, nil
+}
+
+func TestGenerateEmbeddingsFallback(t *testing.T) {
+	embedder := &singleEmbedder{}
+	inputs := numberedInputs(3)
+
+	embeddings, err := GenerateEmbeddings(context.Background(), embedder, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEmbeddings(t, inputs, embeddings)
+	if embedder.calls != 3 {
+		t.Errorf("embedder called %d times, want 3", embedder.calls)
+	}
+
+	if _, err := GenerateEmbeddings(context.Background(), embedder, []string{"1", "fail", "2"}); err == nil {
+		t.Error("a failed input did not fail the batch")
+	}
+}
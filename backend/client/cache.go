@@ -0,0 +1,68 @@
+package client
+
+import "context"
+
+// EmbeddingCache stores embeddings by model and input text. Get returns one
+// entry per text, nil where the text is not cached.
+type EmbeddingCache interface {
+	Get(model string, texts []string) ([][]float64, error)
+	Put(model string, texts []string, embeddings [][]float64) error
+}
+
+// CachingEmbedder answers from an EmbeddingCache where it can and only sends
+// the inputs it has not seen before to the wrapped embedder. The cache is
+// best effort: if it fails, the embedder is used as if it were empty.
+type CachingEmbedder struct {
+	embedder Embedder
+	cache    EmbeddingCache
+}
+
+func NewCachingEmbedder(embedder Embedder, cache EmbeddingCache) *CachingEmbedder {
+	return &CachingEmbedder{embedder: embedder, cache: cache}
+}
+
+func (e *CachingEmbedder) Model() string {
+	return e.embedder.Model()
+}
+
+func (e *CachingEmbedder) GenerateEmbedding(ctx context.Context, input string) ([]float64, error) {
+	embeddings, err := e.GenerateEmbeddings(ctx, []string{input})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+func (e *CachingEmbedder) GenerateEmbeddings(ctx context.Context, inputs []string) ([][]float64, error) {
+	model := e.embedder.Model()
+	embeddings, err := e.cache.Get(model, inputs)
+	if err != nil {
+		logger.Warn("Embedding cache lookup failed", "error", err)
+		embeddings = make([][]float64, len(inputs))
+	}
+
+	var missing []int
+	var missingInputs []string
+	for i, embedding := range embeddings {
+		if embedding == nil {
+			missing = append(missing, i)
+			missingInputs = append(missingInputs, inputs[i])
+		}
+	}
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+
+	generated, err := GenerateEmbeddings(ctx, e.embedder, missingInputs)
+	if err != nil {
+		return nil, err
+	}
+	for i, index := range missing {
+		embeddings[index] = generated[i]
+	}
+
+	if err := e.cache.Put(model, missingInputs, generated); err != nil {
+		logger.Warn("Failed to store embeddings in cache", "error", err)
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,183 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	LMSTUDIO_CHAT_URL = "http://127.0.0.1:1234/v1/chat/completions"
+	// CHAT_TIMEOUT is longer than HTTP_TIMEOUT because local models can take
+	// minutes to write a long answer.
+	CHAT_TIMEOUT = 5 * time.Minute
+)
+
+// ChatMessage is one turn of a chat completion conversation.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatModel generates a reply to a conversation. When onDelta is set the
+// reply is streamed and onDelta is called with each piece as it arrives;
+// returning an error from onDelta aborts the request. The full reply is
+// returned either way.
+type ChatModel interface {
+	Chat(ctx context.Context, messages []ChatMessage, onDelta func(delta string) error) (string, error)
+	Model() string
+}
+
+// ChatConfig configures an OpenAIChat. An empty URL uses LM Studio's local
+// endpoint; an empty Model lets the server pick its loaded model.
+type ChatConfig struct {
+	URL         string
+	Model       string
+	APIKey      string
+	Timeout     time.Duration
+	Temperature float64
+}
+
+type openAIChatRequest struct {
+	Model       string        `json:"model,omitempty"`
+	Messages    []ChatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+	Stream      bool          `json:"stream"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message ChatMessage `json:"message"`
+		Delta   ChatMessage `json:"delta"`
+	} `json:"choices"`
+}
+
+// OpenAIChat talks to any server implementing the OpenAI
+// /v1/chat/completions API, which includes LM Studio.
+type OpenAIChat struct {
+	url         string
+	model       string
+	apiKey      string
+	temperature float64
+	client      *http.Client
+}
+
+func NewOpenAIChat(cfg ChatConfig) *OpenAIChat {
+	if cfg.URL == "" {
+		cfg.URL = LMSTUDIO_CHAT_URL
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = CHAT_TIMEOUT
+	}
+	return &OpenAIChat{
+		url:         cfg.URL,
+		model:       cfg.Model,
+		apiKey:      cfg.APIKey,
+		temperature: cfg.Temperature,
+		client:      newHTTPClient(cfg.Timeout),
+	}
+}
+
+func (c *OpenAIChat) Model() string {
+	return c.model
+}
+
+func (c *OpenAIChat) Chat(ctx context.Context, messages []ChatMessage, onDelta func(delta string) error) (string, error) {
+	requestPayload := openAIChatRequest{
+		Model:       c.model,
+		Messages:    messages,
+		Temperature: c.temperature,
+		Stream:      onDelta != nil,
+	}
+
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		logger.Error("Failed to marshal request", "error", err)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		logger.Error("Failed to create HTTP request", "error", err)
+		return "", fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		logger.Error("Failed to execute chat request", "error", err)
+		return "", &ChatError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("Chat server returned non-200 status code", "status_code", resp.StatusCode)
+		return "", &ChatError{StatusCode: resp.StatusCode}
+	}
+
+	if onDelta != nil {
+		return readChatStream(resp, onDelta)
+	}
+
+	var chatResponse openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResponse); err != nil {
+		logger.Error("Failed to decode response body", "error", err)
+		return "", fmt.Errorf("failed to decode response body: %w", err)
+	}
+	if len(chatResponse.Choices) == 0 {
+		logger.Error("Chat server returned no choices")
+		return "", errors.New("chat server returned no choices")
+	}
+	logger.Debug("Successfully generated chat completion", "length", len(chatResponse.Choices[0].Message.Content))
+	return chatResponse.Choices[0].Message.Content, nil
+}
+
+// readChatStream reads the server-sent events of a streamed completion, each
+// a "data:" line holding a JSON chunk, until the "[DONE]" sentinel.
+func readChatStream(resp *http.Response, onDelta func(delta string) error) (string, error) {
+	var reply strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			logger.Error("Failed to decode chat stream chunk", "error", err)
+			return reply.String(), fmt.Errorf("failed to decode chat stream chunk: %w", err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		reply.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return reply.String(), err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Error("Failed to read chat stream", "error", err)
+		return reply.String(), &ChatError{Err: err}
+	}
+
+	logger.Debug("Successfully streamed chat completion", "length", reply.Len())
+	return reply.String(), nil
+}
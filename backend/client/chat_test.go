@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newChatServer(t *testing.T, handler http.HandlerFunc) *OpenAIChat {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewOpenAIChat(ChatConfig{URL: server.URL, Model: "m"})
+}
+
+func TestChat(t *testing.T) {
+	chat := newChatServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "An answer [1]."}}]}`))
+	})
+
+	reply, err := chat.Chat(context.Background(), []ChatMessage{{Role: "user", Content: "Question?"}}, nil)
+	if err != nil || reply != "An answer [1]." {
+		t.Errorf("Chat = %q, %v", reply, err)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	chat := newChatServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{"An ", "answer", "."} {
+			fmt.Fprintf(w, "data: {\"choices\": [{\"delta\": {\"content\": %q}}]}\n\n", delta)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+
+	var deltas []string
+	reply, err := chat.Chat(context.Background(), nil, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil || reply != "An answer." {
+		t.Fatalf("Chat = %q, %v", reply, err)
+	}
+	if got := strings.Join(deltas, "|"); got != "An |answer|." {
+		t.Errorf("deltas = %q", got)
+	}
+}
+
+func TestChatErrors(t *testing.T) {
+	tests := []struct {
+		status          int
+		wantUnavailable bool
+	}{
+		{http.StatusServiceUnavailable, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		chat := newChatServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		})
+		_, err := chat.Chat(context.Background(), nil, nil)
+
+		var chatErr *ChatError
+		if !errors.As(err, &chatErr) || chatErr.StatusCode != tt.status {
+			t.Fatalf("status %d: error = %#v, want a ChatError", tt.status, err)
+		}
+		if got := errors.Is(err, ErrChatUnavailable); got != tt.wantUnavailable {
+			t.Errorf("status %d: errors.Is(err, ErrChatUnavailable) = %v, want %v", tt.status, got, tt.wantUnavailable)
+		}
+		// A chat outage must not look like the embedding server being down.
+		if errors.Is(err, ErrUnavailable) {
+			t.Errorf("status %d: chat error matches ErrUnavailable", tt.status)
+		}
+	}
+}
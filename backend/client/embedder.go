@@ -1,12 +1,9 @@
 package client
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"os"
 	"time"
 )
@@ -14,66 +11,120 @@ import (
 const (
 	LMSTUDIO_EMBEDDINGS_URL = "http://127.0.0.1:1234/v1/embeddings"
 	LMSTUDIO_MODEL          = "text-embedding-nomic-embed-text-v1.5"
+	OLLAMA_EMBEDDINGS_URL   = "http://127.0.0.1:11434/api/embeddings"
+	OLLAMA_MODEL            = "nomic-embed-text"
 	HTTP_TIMEOUT            = 30 * time.Second
 )
 
-type LMStudioRequest struct {
-	Model string `json:"model"`
-	Input string `json:"input"`
+const (
+	ProviderLMStudio = "lmstudio"
+	ProviderOllama   = "ollama"
+	ProviderOpenAI   = "openai"
+)
+
+// Embedder turns text into an embedding vector using a model server.
+type Embedder interface {
+	GenerateEmbedding(ctx context.Context, input string) ([]float64, error)
+	Model() string
 }
 
-type LMStudioResponse struct {
-	Data []struct {
-		Embedding []float64 `json:"embedding"`
-	} `json:"data"`
+// EmbedderConfig selects and configures an Embedder implementation.
+// Empty URL and Model fall back to the provider defaults. Concurrent requests
+// to providers that accept batches are coalesced into batches of up to
+// BatchSize inputs, waiting at most FlushInterval, with at most MaxInFlight
+// batches sent at once; a BatchSize of 1 turns coalescing off. Retry and breaker settings are described on RetryPolicy
+// and CircuitBreaker. When Cache is set, embeddings found there skip the
+// model server entirely.
+type EmbedderConfig struct {
+	Provider      string
+	URL           string
+	Model         string
+	APIKey        string
+	Timeout       time.Duration
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxInFlight   int
+
+	MaxRetries       int
+	RetryBackoff     time.Duration
+	RetryMaxBackoff  time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	Cache EmbeddingCache
 }
 
 var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
-func GenerateEmbedding(ctx context.Context, input string) ([]float64, error) {
-	requestPayload := LMStudioRequest{
-		Model: LMSTUDIO_MODEL,
-		Input: input,
+func NewEmbedder(cfg EmbedderConfig) (Embedder, error) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = HTTP_TIMEOUT
 	}
-
-	requestBody, err := json.Marshal(requestPayload)
-	if err != nil {
-		logger.Error("Failed to marshal request", "error", err)
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = DEFAULT_RETRY_BACKOFF
 	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", LMSTUDIO_EMBEDDINGS_URL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		logger.Error("Failed to create HTTP request", "error", err)
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	if cfg.RetryMaxBackoff <= 0 {
+		cfg.RetryMaxBackoff = DEFAULT_RETRY_MAX_BACKOFF
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = DEFAULT_BREAKER_COOLDOWN
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := http.Client{Timeout: HTTP_TIMEOUT}
 
-	resp, err := client.Do(req)
+	embedder, err := newProviderEmbedder(cfg)
 	if err != nil {
-		logger.Error("Failed to execute request to LM Studio", "error", err)
-		return nil, fmt.Errorf("failed to execute request to LM Studio: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		logger.Error("LM Studio returned non-200 status code", "status_code", resp.StatusCode)
-		return nil, fmt.Errorf("LM Studio returned non-200 status code: %d", resp.StatusCode)
+	if cfg.Cache != nil {
+		return NewCachingEmbedder(embedder, cfg.Cache), nil
 	}
+	return embedder, nil
+}
 
-	var lmStudioResponse LMStudioResponse
-	if err := json.NewDecoder(resp.Body).Decode(&lmStudioResponse); err != nil {
-		logger.Error("Failed to decode response body", "error", err)
-		return nil, fmt.Errorf("failed to decode response body: %w", err)
+func newProviderEmbedder(cfg EmbedderConfig) (Embedder, error) {
+	switch cfg.Provider {
+	case "", ProviderLMStudio:
+		if cfg.URL == "" {
+			cfg.URL = LMSTUDIO_EMBEDDINGS_URL
+		}
+		if cfg.Model == "" {
+			cfg.Model = LMSTUDIO_MODEL
+		}
+		return withCoalescing(withRetries(NewOpenAIEmbedder("LM Studio", cfg), cfg), cfg), nil
+	case ProviderOllama:
+		if cfg.URL == "" {
+			cfg.URL = OLLAMA_EMBEDDINGS_URL
+		}
+		if cfg.Model == "" {
+			cfg.Model = OLLAMA_MODEL
+		}
+		return withRetries(NewOllamaEmbedder(cfg), cfg), nil
+	case ProviderOpenAI:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("embedder provider %q requires a URL", cfg.Provider)
+		}
+		if cfg.Model == "" {
+			return nil, fmt.Errorf("embedder provider %q requires a model", cfg.Provider)
+		}
+		return withCoalescing(withRetries(NewOpenAIEmbedder("OpenAI-compatible server", cfg), cfg), cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown embedder provider %q (expected %s, %s or %s)",
+			cfg.Provider, ProviderLMStudio, ProviderOllama, ProviderOpenAI)
 	}
+}
 
-	if len(lmStudioResponse.Data) == 0 || len(lmStudioResponse.Data[0].Embedding) == 0 {
-		logger.Error("LM Studio returned empty embedding data")
-		return nil, fmt.Errorf("LM Studio returned empty embedding data")
+func withCoalescing(embedder BatchEmbedder, cfg EmbedderConfig) Embedder {
+	if cfg.BatchSize == 1 {
+		return embedder
 	}
+	return NewCoalescingEmbedder(embedder, cfg.BatchSize, cfg.FlushInterval, cfg.MaxInFlight)
+}
 
-	logger.Debug("Successfully generated embedding", "input_length", len(input))
-	return lmStudioResponse.Data[0].Embedding, nil
+func withRetries(embedder Embedder, cfg EmbedderConfig) *ResilientEmbedder {
+	policy := RetryPolicy{
+		MaxRetries: cfg.MaxRetries,
+		Backoff:    cfg.RetryBackoff,
+		MaxBackoff: cfg.RetryMaxBackoff,
+	}
+	breaker := &CircuitBreaker{Threshold: cfg.BreakerThreshold, Cooldown: cfg.BreakerCooldown}
+	return NewResilientEmbedder(embedder, policy, breaker)
 }
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewEmbedderProviders(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       EmbedderConfig
+		wantModel string
+		wantErr   string
+	}{
+		{"default provider", EmbedderConfig{}, LMSTUDIO_MODEL, ""},
+		{"lmstudio with a model", EmbedderConfig{Provider: ProviderLMStudio, Model: "custom"}, "custom", ""},
+		{"ollama", EmbedderConfig{Provider: ProviderOllama}, OLLAMA_MODEL, ""},
+		{"openai", EmbedderConfig{Provider: ProviderOpenAI, URL: "http://127.0.0.1:1/v1/embeddings", Model: "m"}, "m", ""},
+		{"openai without a URL", EmbedderConfig{Provider: ProviderOpenAI, Model: "m"}, "", "requires a URL"},
+		{"openai without a model", EmbedderConfig{Provider: ProviderOpenAI, URL: "http://127.0.0.1:1"}, "", "requires a model"},
+		{"unknown provider", EmbedderConfig{Provider: "word2vec"}, "", "unknown embedder provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedder, err := NewEmbedder(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := embedder.Model(); got != tt.wantModel {
+				t.Errorf("Model = %q, want %q", got, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestOllamaEmbedder(t *testing.T) {
+	var status int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OllamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		if request.Prompt == "empty" {
+			w.Write([]byte(`{"embedding": []}`))
+			return
+		}
+		fmt.Fprintf(w, `{"embedding": [%d, 0.5]}`, len(request.Model))
+	}))
+	t.Cleanup(server.Close)
+
+	embedder, err := NewEmbedder(EmbedderConfig{Provider: ProviderOllama, URL: server.URL, Model: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	status = http.StatusOK
+	embedding, err := embedder.GenerateEmbedding(ctx, "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(embedding) != "[3 0.5]" {
+		t.Errorf("embedding = %v, want [3 0.5] for a request naming model abc", embedding)
+	}
+	if _, err := embedder.GenerateEmbedding(ctx, "empty"); err == nil {
+		t.Error("an empty embedding was accepted")
+	}
+
+	status = http.StatusBadRequest
+	if _, err := embedder.GenerateEmbedding(ctx, "text"); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Errorf("rejected request error = %v, want an error that is not ErrUnavailable", err)
+	}
+}
+
+func TestOpenAIEmbedderSendsAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data": [{"index": 0, "embedding": [1, 0]}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	for _, tt := range []struct {
+		apiKey  string
+		wantErr bool
+	}{{"secret", false}, {"", true}} {
+		embedder, err := NewEmbedder(EmbedderConfig{Provider: ProviderOpenAI, URL: server.URL, Model: "m", APIKey: tt.apiKey, BatchSize: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := embedder.GenerateEmbedding(context.Background(), "text"); (err != nil) != tt.wantErr {
+			t.Errorf("API key %q: error = %v, want error %v", tt.apiKey, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrUnavailable matches, via errors.Is, every error caused by the model
+// server being unreachable, overloaded or failing, as opposed to rejecting
+// the request. Callers can report these as temporary.
+var ErrUnavailable = errors.New("embedding server unavailable")
+
+// ErrCircuitOpen is matched by the CircuitOpenError returned without
+// contacting the server while the circuit breaker is open. It matches
+// ErrUnavailable.
+var ErrCircuitOpen = fmt.Errorf("%w: too many recent failures, not retrying yet", ErrUnavailable)
+
+// ErrChatUnavailable is ErrUnavailable for the chat server. It is separate so
+// that a chat outage is not mistaken for the embedding server being down.
+var ErrChatUnavailable = errors.New("chat server unavailable")
+
+// CircuitOpenError is returned while the circuit breaker is open.
+type CircuitOpenError struct {
+	// RetryAfter is how long until the breaker lets a trial request through.
+	// It is zero while a trial is already in flight.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return ErrCircuitOpen.Error()
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen || target == ErrUnavailable
+}
+
+// ServerError describes a failed request to a model server.
+type ServerError struct {
+	Server string
+	// StatusCode is zero when no response was received.
+	StatusCode int
+	// RetryAfter is the delay the server asked for, if any.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ServerError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s returned non-200 status code: %d", e.Server, e.StatusCode)
+	}
+	return fmt.Sprintf("failed to execute request to %s: %v", e.Server, e.Err)
+}
+
+func (e *ServerError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports connection failures, 429 and 5xx responses as ErrUnavailable.
+func (e *ServerError) Is(target error) bool {
+	return target == ErrUnavailable && e.Temporary()
+}
+
+// Temporary reports whether retrying the request may succeed.
+func (e *ServerError) Temporary() bool {
+	return temporaryStatus(e.StatusCode)
+}
+
+// ChatError describes a failed request to the chat server. Connection
+// failures, 429 and 5xx responses match ErrChatUnavailable.
+type ChatError struct {
+	// StatusCode is zero when no response was received.
+	StatusCode int
+	Err        error
+}
+
+func (e *ChatError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("chat server returned non-200 status code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("failed to execute request to chat server: %v", e.Err)
+}
+
+func (e *ChatError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ChatError) Is(target error) bool {
+	return target == ErrChatUnavailable && temporaryStatus(e.StatusCode)
+}
+
+// temporaryStatus reports whether a request that got status code, or no
+// response at all when it is zero, may succeed if retried.
+func temporaryStatus(code int) bool {
+	return code == 0 || code == http.StatusTooManyRequests || code >= 500
+}
+
+func newStatusError(server string, resp *http.Response) *ServerError {
+	err := &ServerError{Server: server, StatusCode: resp.StatusCode}
+	if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return err
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// sharedTransport pools connections to model servers across every embedder
+// and request. Embedding traffic is many small requests to one or two local
+// hosts, so more idle connections per host are kept than the default two.
+var sharedTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   16,
+	IdleConnTimeout:       90 * time.Second,
+	ExpectContinueTimeout: 1 * time.Second,
+}
+
+// newHTTPClient returns a client on the shared transport. timeout bounds each
+// request attempt.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: sharedTransport, Timeout: timeout}
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type OllamaEmbeddingRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+type OllamaEmbeddingResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+// OllamaEmbedder talks to Ollama's native /api/embeddings endpoint.
+type OllamaEmbedder struct {
+	url    string
+	model  string
+	client *http.Client
+}
+
+func NewOllamaEmbedder(cfg EmbedderConfig) *OllamaEmbedder {
+	return &OllamaEmbedder{
+		url:    cfg.URL,
+		model:  cfg.Model,
+		client: newHTTPClient(cfg.Timeout),
+	}
+}
+
+func (e *OllamaEmbedder) Model() string {
+	return e.model
+}
+
+func (e *OllamaEmbedder) GenerateEmbedding(ctx context.Context, input string) ([]float64, error) {
+	requestPayload := OllamaEmbeddingRequest{
+		Model:  e.model,
+		Prompt: input,
+	}
+
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		logger.Error("Failed to marshal request", "error", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		logger.Error("Failed to create HTTP request", "error", err)
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		logger.Error("Failed to execute request to Ollama", "error", err)
+		return nil, &ServerError{Server: "Ollama", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("Ollama returned non-200 status code", "status_code", resp.StatusCode)
+		return nil, newStatusError("Ollama", resp)
+	}
+
+	var ollamaResponse OllamaEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ollamaResponse); err != nil {
+		logger.Error("Failed to decode response body", "error", err)
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	if len(ollamaResponse.Embedding) == 0 {
+		logger.Error("Ollama returned empty embedding data")
+		return nil, fmt.Errorf("Ollama returned empty embedding data")
+	}
+
+	logger.Debug("Successfully generated embedding", "server", "Ollama", "input_length", len(input))
+	return ollamaResponse.Embedding, nil
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// OpenAIEmbeddingRequest carries either a single string or, for batches, an
+// array of strings in Input.
+type OpenAIEmbeddingRequest struct {
+	Model string `json:"model"`
+	Input any    `json:"input"`
+}
+
+type OpenAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+// OpenAIEmbedder talks to any server implementing the OpenAI /v1/embeddings
+// API, which includes LM Studio.
+type OpenAIEmbedder struct {
+	name   string
+	url    string
+	model  string
+	apiKey string
+	client *http.Client
+}
+
+func NewOpenAIEmbedder(name string, cfg EmbedderConfig) *OpenAIEmbedder {
+	return &OpenAIEmbedder{
+		name:   name,
+		url:    cfg.URL,
+		model:  cfg.Model,
+		apiKey: cfg.APIKey,
+		client: newHTTPClient(cfg.Timeout),
+	}
+}
+
+func (e *OpenAIEmbedder) Model() string {
+	return e.model
+}
+
+func (e *OpenAIEmbedder) GenerateEmbedding(ctx context.Context, input string) ([]float64, error) {
+	embeddings, err := e.embed(ctx, input, 1)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("Successfully generated embedding", "server", e.name, "input_length", len(input))
+	return embeddings[0], nil
+}
+
+// GenerateEmbeddings embeds all inputs in one request using the array form
+// of the input field.
+func (e *OpenAIEmbedder) GenerateEmbeddings(ctx context.Context, inputs []string) ([][]float64, error) {
+	if len(inputs) == 0 {
+		return [][]float64{}, nil
+	}
+	embeddings, err := e.embed(ctx, inputs, len(inputs))
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("Successfully generated embeddings", "server", e.name, "batch_size", len(inputs))
+	return embeddings, nil
+}
+
+// embed sends input, a string or a slice of count strings, and returns the
+// embeddings in input order.
+func (e *OpenAIEmbedder) embed(ctx context.Context, input any, count int) ([][]float64, error) {
+	requestPayload := OpenAIEmbeddingRequest{
+		Model: e.model,
+		Input: input,
+	}
+
+	requestBody, err := json.Marshal(requestPayload)
+	if err != nil {
+		logger.Error("Failed to marshal request", "error", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		logger.Error("Failed to create HTTP request", "error", err)
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		logger.Error("Failed to execute embedding request", "server", e.name, "error", err)
+		return nil, &ServerError{Server: e.name, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logger.Error("Embedding server returned non-200 status code", "server", e.name, "status_code", resp.StatusCode)
+		return nil, newStatusError(e.name, resp)
+	}
+
+	var embeddingResponse OpenAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResponse); err != nil {
+		logger.Error("Failed to decode response body", "error", err)
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	if len(embeddingResponse.Data) != count {
+		logger.Error("Embedding server returned the wrong number of embeddings", "server", e.name, "expected", count, "got", len(embeddingResponse.Data))
+		return nil, fmt.Errorf("%s returned %d embeddings for %d inputs", e.name, len(embeddingResponse.Data), count)
+	}
+
+	// The index field gives each embedding's input position; servers are not
+	// required to return them in order.
+	embeddings := make([][]float64, count)
+	for _, data := range embeddingResponse.Data {
+		if data.Index < 0 || data.Index >= count || embeddings[data.Index] != nil || len(data.Embedding) == 0 {
+			logger.Error("Embedding server returned empty or misindexed embedding data", "server", e.name)
+			return nil, fmt.Errorf("%s returned empty or misindexed embedding data", e.name)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+	return embeddings, nil
+}
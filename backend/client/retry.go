@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_MAX_RETRIES       = 3
+	DEFAULT_RETRY_BACKOFF     = 250 * time.Millisecond
+	DEFAULT_RETRY_MAX_BACKOFF = 5 * time.Second
+	DEFAULT_BREAKER_THRESHOLD = 5
+	DEFAULT_BREAKER_COOLDOWN  = 30 * time.Second
+)
+
+// RetryPolicy controls how failed requests are retried. Only errors matching
+// ErrUnavailable are retried. The delay before retry n is drawn at random
+// between half and all of Backoff*2^(n-1), capped at MaxBackoff, unless the
+// server asked for a specific delay with Retry-After.
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (p RetryPolicy) delay(retry int, err error) time.Duration {
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) && serverErr.RetryAfter > 0 {
+		return min(serverErr.RetryAfter, p.MaxBackoff)
+	}
+
+	backoff := p.Backoff << (retry - 1)
+	if backoff <= 0 || backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	half := backoff / 2
+	return half + rand.N(half+1)
+}
+
+// CircuitBreaker fails requests fast once Threshold consecutive requests have
+// failed with ErrUnavailable. After Cooldown a single trial request is let
+// through; its success closes the circuit again and its failure restarts the
+// cooldown. A Threshold of zero disables the breaker.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// allow reports whether a request may be sent now.
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Threshold <= 0 || b.failures < b.Threshold {
+		return true
+	}
+	if b.trial || time.Now().Before(b.openUntil) {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// retryAfter returns how long until an open breaker lets a trial through.
+func (b *CircuitBreaker) retryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.trial {
+		return 0
+	}
+	return max(time.Until(b.openUntil), 0)
+}
+
+// cancelTrial lets another request try the server after a trial ended
+// without an answer, without counting that as a failure.
+func (b *CircuitBreaker) cancelTrial() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Threshold <= 0 {
+		return
+	}
+	b.trial = false
+	if !errors.Is(err, ErrUnavailable) {
+		if b.failures >= b.Threshold {
+			logger.Info("Embedding server reachable again, closing circuit breaker")
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.Threshold {
+		if b.failures == b.Threshold {
+			logger.Warn("Embedding server keeps failing, opening circuit breaker", "cooldown", b.Cooldown.String())
+		}
+		b.openUntil = time.Now().Add(b.Cooldown)
+	}
+}
+
+// ResilientEmbedder retries temporary failures of another embedder and stops
+// calling it while its circuit breaker is open.
+type ResilientEmbedder struct {
+	embedder Embedder
+	policy   RetryPolicy
+	breaker  *CircuitBreaker
+}
+
+func NewResilientEmbedder(embedder Embedder, policy RetryPolicy, breaker *CircuitBreaker) *ResilientEmbedder {
+	return &ResilientEmbedder{embedder: embedder, policy: policy, breaker: breaker}
+}
+
+func (e *ResilientEmbedder) Model() string {
+	return e.embedder.Model()
+}
+
+func (e *ResilientEmbedder) GenerateEmbedding(ctx context.Context, input string) ([]float64, error) {
+	var embedding []float64
+	err := e.do(ctx, func() error {
+		var err error
+		embedding, err = e.embedder.GenerateEmbedding(ctx, input)
+		return err
+	})
+	return embedding, err
+}
+
+// GenerateEmbeddings retries the whole batch, using the wrapped embedder's
+// batch support when it has any.
+func (e *ResilientEmbedder) GenerateEmbeddings(ctx context.Context, inputs []string) ([][]float64, error) {
+	var embeddings [][]float64
+	err := e.do(ctx, func() error {
+		var err error
+		embeddings, err = GenerateEmbeddings(ctx, e.embedder, inputs)
+		return err
+	})
+	return embeddings, err
+}
+
+func (e *ResilientEmbedder) do(ctx context.Context, attempt func() error) error {
+	for retry := 0; ; retry++ {
+		if !e.breaker.allow() {
+			return &CircuitOpenError{RetryAfter: e.breaker.retryAfter()}
+		}
+
+		err := attempt()
+		if ctx.Err() != nil {
+			// A cancelled caller says nothing about the server's health,
+			// but a trial it was running must not hold the breaker open.
+			e.breaker.cancelTrial()
+			return err
+		}
+		e.breaker.record(err)
+		if err == nil || !errors.Is(err, ErrUnavailable) || retry >= e.policy.MaxRetries {
+			return err
+		}
+
+		delay := e.policy.delay(retry+1, err)
+		logger.Warn("Embedding request failed, retrying", "error", err, "retry", retry+1, "delay", delay.String())
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
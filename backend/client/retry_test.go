@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedServer answers embedding requests with the given status codes in
+// turn, repeating the last one, and counts the requests it receives.
+type scriptedServer struct {
+	*httptest.Server
+	statuses   []int
+	retryAfter string
+	requests   atomic.Int32
+}
+
+func newScriptedServer(t *testing.T, statuses ...int) *scriptedServer {
+	s := &scriptedServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(s.requests.Add(1))
+		status := s.statuses[min(n, len(s.statuses))-1]
+		if status != http.StatusOK {
+			if s.retryAfter != "" {
+				w.Header().Set("Retry-After", s.retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"data": [{"index": 0, "embedding": [1, 0]}]}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *scriptedServer) embedder(maxRetries int, breaker *CircuitBreaker) *ResilientEmbedder {
+	if breaker == nil {
+		breaker = &CircuitBreaker{}
+	}
+	policy := RetryPolicy{MaxRetries: maxRetries, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+	return NewResilientEmbedder(NewOpenAIEmbedder("test server", EmbedderConfig{URL: s.URL, Model: "m", Timeout: time.Second}), policy, breaker)
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name            string
+		statuses        []int
+		maxRetries      int
+		wantRequests    int32
+		wantErr         bool
+		wantUnavailable bool
+	}{
+		{"success", []int{200}, 3, 1, false, false},
+		{"rate limited then success", []int{429, 200}, 3, 2, false, false},
+		{"server errors then success", []int{503, 500, 200}, 3, 3, false, false},
+		{"server errors exhaust retries", []int{502}, 2, 3, true, true},
+		{"client error is not retried", []int{400, 200}, 3, 1, true, false},
+		{"retries disabled", []int{503, 200}, 0, 1, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newScriptedServer(t, tt.statuses...)
+			_, err := server.embedder(tt.maxRetries, nil).GenerateEmbedding(context.Background(), "text")
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrUnavailable); got != tt.wantUnavailable {
+				t.Errorf("errors.Is(%v, ErrUnavailable) = %v, want %v", err, got, tt.wantUnavailable)
+			}
+			if got := server.requests.Load(); got != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	server := newScriptedServer(t, http.StatusTooManyRequests)
+	server.retryAfter = "7"
+	_, err := server.embedder(0, nil).GenerateEmbedding(context.Background(), "text")
+
+	var serverErr *ServerError
+	if !errors.As(err, &serverErr) || serverErr.RetryAfter != 7*time.Second {
+		t.Fatalf("error = %#v, want a ServerError with RetryAfter 7s", err)
+	}
+
+	policy := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 10 * time.Second}
+	if got := policy.delay(1, err); got != 7*time.Second {
+		t.Errorf("delay = %v, want the server's 7s", got)
+	}
+	policy.MaxBackoff = 2 * time.Second
+	if got := policy.delay(1, err); got != 2*time.Second {
+		t.Errorf("delay = %v, want it capped at MaxBackoff 2s", got)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	err := &ServerError{Server: "test", StatusCode: http.StatusServiceUnavailable}
+
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{5, 500 * time.Millisecond, time.Second},
+		{40, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if got := policy.delay(tt.retry, err); got < tt.min || got > tt.max {
+				t.Errorf("delay(%d) = %v, want between %v and %v", tt.retry, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	server := newScriptedServer(t, 503)
+	breaker := &CircuitBreaker{Threshold: 2, Cooldown: 50 * time.Millisecond}
+	embedder := server.embedder(0, breaker)
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := embedder.GenerateEmbedding(ctx, "text"); !errors.Is(err, ErrUnavailable) || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("error = %v, want a server error", err)
+		}
+	}
+
+	// Open: requests fail without reaching the server.
+	_, err := embedder.GenerateEmbedding(ctx, "text")
+	if !errors.Is(err, ErrCircuitOpen) || !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("error = %v, want ErrCircuitOpen", err)
+	}
+	var circuitOpen *CircuitOpenError
+	if !errors.As(err, &circuitOpen) || circuitOpen.RetryAfter <= 0 || circuitOpen.RetryAfter > breaker.Cooldown {
+		t.Errorf("error = %#v, want a CircuitOpenError with RetryAfter within the cooldown", err)
+	}
+	if got := server.requests.Load(); got != 2 {
+		t.Fatalf("server got %d requests while the circuit was open, want 2", got)
+	}
+
+	// Half-open: a failed trial restarts the cooldown.
+	time.Sleep(60 * time.Millisecond)
+	if _, err := embedder.GenerateEmbedding(ctx, "text"); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("trial request was not let through after the cooldown")
+	}
+	if _, err := embedder.GenerateEmbedding(ctx, "text"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("error = %v after a failed trial, want ErrCircuitOpen", err)
+	}
+	if got := server.requests.Load(); got != 3 {
+		t.Fatalf("server got %d requests, want 3", got)
+	}
+
+	// A successful trial closes the circuit.
+	server.statuses = []int{200}
+	time.Sleep(60 * time.Millisecond)
+	for range 3 {
+		if _, err := embedder.GenerateEmbedding(ctx, "text"); err != nil {
+			t.Fatalf("error = %v after the server recovered", err)
+		}
+	}
+	if got := server.requests.Load(); got != 6 {
+		t.Errorf("server got %d requests, want 6", got)
+	}
+}
+
+func TestCircuitBreakerSingleTrial(t *testing.T) {
+	breaker := &CircuitBreaker{Threshold: 1, Cooldown: time.Millisecond}
+	breaker.record(ErrUnavailable)
+	if breaker.allow() {
+		t.Fatal("open breaker allowed a request before the cooldown")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	if !breaker.allow() {
+		t.Fatal("breaker did not allow a trial after the cooldown")
+	}
+	if breaker.allow() {
+		t.Error("breaker allowed a second request during the trial")
+	}
+	breaker.record(nil)
+	if !breaker.allow() || !breaker.allow() {
+		t.Error("breaker stayed open after a successful trial")
+	}
+}
+
+func TestCircuitBreakerCancelledTrial(t *testing.T) {
+	var requests atomic.Int32
+	hang := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// The trial hangs until its caller has given up.
+			<-hang
+			return
+		}
+		w.Write([]byte(`{"data": [{"index": 0, "embedding": [1, 0]}]}`))
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(hang) })
+
+	breaker := &CircuitBreaker{Threshold: 1, Cooldown: time.Millisecond}
+	breaker.record(ErrUnavailable)
+	time.Sleep(2 * time.Millisecond)
+	embedder := NewResilientEmbedder(NewOpenAIEmbedder("test server", EmbedderConfig{URL: server.URL, Model: "m", Timeout: time.Second}), RetryPolicy{}, breaker)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := embedder.GenerateEmbedding(ctx, "text"); err == nil {
+		t.Fatal("cancelled trial succeeded")
+	}
+
+	if _, err := embedder.GenerateEmbedding(context.Background(), "text"); err != nil {
+		t.Fatalf("error = %v after a cancelled trial, want another trial", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("server got %d requests, want 2", got)
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	breaker := &CircuitBreaker{Threshold: 1, Cooldown: time.Hour}
+	breaker.record(&ServerError{Server: "test", StatusCode: http.StatusBadRequest})
+	if !breaker.allow() {
+		t.Error("a rejected request opened the breaker")
+	}
+
+	disabled := &CircuitBreaker{}
+	for range 10 {
+		disabled.record(ErrUnavailable)
+	}
+	if !disabled.allow() {
+		t.Error("a breaker with a zero threshold opened")
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	server := newScriptedServer(t, 503)
+	embedder := server.embedder(100, nil)
+	embedder.policy.Backoff, embedder.policy.MaxBackoff = time.Hour, time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := embedder.GenerateEmbedding(ctx, "text")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if got := server.requests.Load(); got != 1 {
+		t.Errorf("server got %d requests, want 1", got)
+	}
+}
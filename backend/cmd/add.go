@@ -2,35 +2,73 @@ package cmd
 
 import (
 	"fmt"
+	"synapse/database"
+	"synapse/service"
 
 	"github.com/spf13/cobra"
 )
 
+var addNote database.Note
+var addOnDuplicate string
+
 var addCmd = &cobra.Command{
 	Use:   "add <note text>",
 	Short: "Add a new note to your knowledge base.",
 	Long: `Create a new note and automatically generate its semantic embedding.
 
 The note will be stored in the database along with its embedding vector,
-enabling semantic search across your knowledge base.
+enabling semantic search across your knowledge base. Optionally record where
+the note came from with the source flags.
+
+A note whose content matches a stored note, or whose embedding is within
+dedupe.threshold of one, is rejected by default. Use --on-duplicate merge to
+fold its tags and source fields into the existing note, or allow to store it
+anyway.
 
 Examples:
   synapse add "Einstein's theory of relativity"
-  synapse add "Machine learning is a subset of AI"`,
+  synapse add "Machine learning is a subset of AI"
+  synapse add "Pods are the smallest deployable unit" --tag kubernetes --tag basics
+  synapse add "Hypermedia drives application state" --url https://hypermedia.systems --title "Hypermedia Systems"
+  synapse add "Pods are the smallest deployable unit" --tag k8s --on-duplicate merge`,
 
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		content := args[0]
+		note := addNote
+		note.Content = args[0]
+
+		policy, err := service.ParseDedupePolicy(addOnDuplicate)
+		if err != nil {
+			return err
+		}
 
-		if err := noteService.CreateNote(cmd.Context(), content); err != nil {
+		id, merged, err := noteService.CreateNoteWithPolicy(cmd.Context(), note, policy)
+		if err != nil {
 			return err
 		}
+		if merged {
+			fmt.Printf("Merged: Note duplicates note %d, which was updated instead.\n", id)
+			return nil
+		}
+
+		if saved, err := noteService.GetByID(id); err == nil && saved != nil && saved.EmbeddingStatus == database.EMBEDDING_PENDING {
+			fmt.Printf("Saved: Note %d stored, but the embedding server is unavailable.\n", id)
+			fmt.Println("Run 'synapse embed-pending' once it is back to make the note searchable by meaning.")
+			return nil
+		}
 
-		fmt.Printf("Success: Note saved successfully.")
+		fmt.Printf("Success: Note saved successfully (ID: %d).\n", id)
 		return nil
 	},
 }
 
 func init() {
+	addCmd.Flags().StringVar(&addNote.SourceURL, "url", "", "Source URL the note was captured from.")
+	addCmd.Flags().StringVar(&addNote.Title, "title", "", "Title of the source document.")
+	addCmd.Flags().StringVar(&addNote.SiteName, "site-name", "", "Name of the source site.")
+	addCmd.Flags().StringVar(&addNote.Author, "author", "", "Author of the source document.")
+	addCmd.Flags().StringSliceVarP(&addNote.Tags, "tag", "t", nil, "Tag to attach to the note (repeatable or comma-separated).")
+	addCmd.Flags().StringVar(&addNote.Metadata, "metadata", "", "Additional metadata as a JSON object.")
+	addCmd.Flags().StringVar(&addOnDuplicate, "on-duplicate", "", "What to do if the note duplicates a stored one: reject, merge or allow (defaults to dedupe.policy).")
 	rootCmd.AddCommand(addCmd)
 }
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"synapse/database"
+	"synapse/service"
+
+	"github.com/spf13/cobra"
+)
+
+var askNotes int
+var askTags []string
+var askMaxDistance float64
+var askNoStream bool
+
+var askCmd = &cobra.Command{
+	Use:   "ask <question>",
+	Short: "Answer a question from your notes.",
+	Long: `Find the notes closest to the question, hand them to a chat model as context
+and print its answer, citing the notes it used by ID. The answer is streamed
+as it is written unless --no-stream is given.
+
+The chat model is any OpenAI-compatible /v1/chat/completions endpoint, LM
+Studio's local server by default; set chat.url and chat.model in the config
+file to use another. chat.context_notes sets how many notes are retrieved.
+
+Examples:
+  synapse ask "How do Kubernetes pods get their IP addresses?"
+  synapse ask "What did I read about Raft leader election?" --tag distributed-systems
+  synapse ask "Summarise my notes on HTMX" -n 10 --no-stream`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		question := strings.Join(args, " ")
+		notes := cfg.Chat.ContextNotes
+		if cmd.Flags().Changed("notes") {
+			notes = askNotes
+		}
+		opts := service.AskOptions{
+			NoteFilter:  database.NoteFilter{Tags: askTags},
+			Notes:       notes,
+			MaxDistance: askMaxDistance,
+		}
+
+		var onDelta func(string) error
+		if !askNoStream {
+			onDelta = func(delta string) error {
+				_, err := fmt.Print(delta)
+				return err
+			}
+		}
+
+		answer, err := noteService.Ask(cmd.Context(), question, opts, onDelta)
+		if err != nil {
+			if !askNoStream && answer.Text != "" {
+				fmt.Println()
+			}
+			return err
+		}
+		if askNoStream {
+			fmt.Print(answer.Text)
+		}
+		fmt.Println()
+
+		if len(answer.Citations) == 0 {
+			return nil
+		}
+		fmt.Println()
+		fmt.Println("Sources:")
+		for _, note := range answer.Sources {
+			if slices.Contains(answer.Citations, note.Id) {
+				fmt.Printf("  [%d] %s\n", note.Id, sourceLabel(note))
+			}
+		}
+		return nil
+	},
+}
+
+// sourceLabel names a cited note by its title, URL or opening words.
+func sourceLabel(note database.Note) string {
+	switch {
+	case note.Title != "" && note.SourceURL != "":
+		return note.Title + " (" + note.SourceURL + ")"
+	case note.Title != "":
+		return note.Title
+	case note.SourceURL != "":
+		return note.SourceURL
+	}
+	label := strings.Join(strings.Fields(note.Content), " ")
+	if len(label) > 60 {
+		label = strings.ToValidUTF8(label[:60], "") + "…"
+	}
+	return label
+}
+
+func init() {
+	askCmd.Flags().IntVarP(&askNotes, "notes", "n", service.DEFAULT_ASK_NOTES, "Number of notes to answer from (defaults to chat.context_notes).")
+	askCmd.Flags().StringSliceVarP(&askTags, "tag", "t", nil, "Only answer from notes carrying this tag (repeatable; all must match).")
+	askCmd.Flags().Float64Var(&askMaxDistance, "max-distance", 0, "Ignore notes farther than this distance from the question (0 disables).")
+	askCmd.Flags().BoolVar(&askNoStream, "no-stream", false, "Print the answer only once it is complete.")
+	rootCmd.AddCommand(askCmd)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"synapse/service"
+)
+
+// readOnlyRoutes use POST only to carry a request body, so read tokens may
+// call them.
+var readOnlyRoutes = map[string]bool{
+	"POST /api/search": true,
+	"POST /api/ask":    true,
+}
+
+// requireToken refuses /api requests without a valid bearer token, and
+// requests that change notes when the token only has read scope.
+func requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="synapse"`)
+			writeError(w, http.StatusUnauthorized, CodeUnauthorized, "API token required", nil)
+			return
+		}
+
+		stored, err := noteService.Authenticate(token)
+		if errors.Is(err, service.ErrInvalidToken) {
+			requestLogger(r).Warn("Rejected invalid API token", "remote_addr", r.RemoteAddr, "path", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="synapse", error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Invalid API token", nil)
+			return
+		}
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+
+		scope := service.TokenScope(stored.Scope)
+		if !scope.Allows(r.Method, readOnlyRoutes[r.Method+" "+r.URL.Path]) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="synapse", error="insufficient_scope"`)
+			writeError(w, http.StatusForbidden, CodeForbidden, "This API token is read-only", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken reads the token from the Authorization header. EventSource
+// cannot set headers, so GET /api/events may pass it as ?access_token=
+// instead.
+func bearerToken(r *http.Request) (string, bool) {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		token = strings.TrimSpace(token)
+		return token, token != ""
+	}
+	if r.Method == http.MethodGet && r.URL.Path == "/api/events" {
+		token := r.URL.Query().Get("access_token")
+		return token, token != ""
+	}
+	return "", false
+}
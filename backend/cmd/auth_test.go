@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"synapse/service"
+	"testing"
+)
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		header    string
+		wantToken string
+		wantOK    bool
+	}{
+		{"bearer header", "GET", "/api/notes", "Bearer syn_abc", "syn_abc", true},
+		{"scheme is case-insensitive", "POST", "/api/notes", "bearer  syn_abc ", "syn_abc", true},
+		{"other scheme", "GET", "/api/notes", "Basic dXNlcg==", "", false},
+		{"empty bearer", "GET", "/api/notes", "Bearer ", "", false},
+		{"no token", "GET", "/api/notes", "", "", false},
+		{"query token on the event stream", "GET", "/api/events?access_token=syn_abc", "", "syn_abc", true},
+		{"header wins over the query", "GET", "/api/events?access_token=syn_q", "Bearer syn_h", "syn_h", true},
+		{"query token elsewhere", "GET", "/api/notes?access_token=syn_abc", "", "", false},
+		{"query token on a POST", "POST", "/api/events?access_token=syn_abc", "", "", false},
+		{"empty query token", "GET", "/api/events?access_token=", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			token, ok := bearerToken(r)
+			if token != tt.wantToken || ok != tt.wantOK {
+				t.Errorf("bearerToken = %q, %v, want %q, %v", token, ok, tt.wantToken, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	s := useTestService(t)
+	readToken, _, err := s.CreateToken("reader", service.ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeToken, _, err := s.CreateToken("writer", service.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := requireToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		token      string
+		wantStatus int
+	}{
+		{"no token", "GET", "/api/notes", "", http.StatusUnauthorized},
+		{"unknown token", "GET", "/api/notes", "syn_unknown", http.StatusUnauthorized},
+		{"read token reads", "GET", "/api/notes", readToken, http.StatusNoContent},
+		{"read token searches", "POST", "/api/search", readToken, http.StatusNoContent},
+		{"read token asks", "POST", "/api/ask", readToken, http.StatusNoContent},
+		{"read token cannot create", "POST", "/api/notes", readToken, http.StatusForbidden},
+		{"read token cannot delete", "DELETE", "/api/notes/1", readToken, http.StatusForbidden},
+		{"read token cannot import", "POST", "/api/import", readToken, http.StatusForbidden},
+		{"write token creates", "POST", "/api/notes", writeToken, http.StatusNoContent},
+		{"write token deletes", "DELETE", "/api/notes/1", writeToken, http.StatusNoContent},
+		{"paths outside the API are open", "GET", "/", "", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden {
+				if !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+					t.Errorf("WWW-Authenticate = %q, want a Bearer challenge", w.Header().Get("WWW-Authenticate"))
+				}
+			}
+		})
+	}
+
+	// The event stream takes the token from the query, since EventSource
+	// cannot set headers.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/events?access_token="+readToken, nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("event stream with a query token: status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var cacheClearModel string
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect or clear the embedding cache.",
+	Long: `Embeddings are cached in the database by model and by the SHA-256 of the
+whitespace-normalized text, so repeated searches and re-imported content skip
+the embedding server. Once embedder.cache_size entries are stored, the least
+recently used ones are evicted; a cache_size of 0 turns the cache off.
+
+Examples:
+  synapse cache stats
+  synapse cache clear
+  synapse cache clear --model nomic-embed-text`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the size and hit rate of the embedding cache.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stats, err := dbManager.EmbeddingCache(cfg.Embedder.CacheSize).Stats()
+		if err != nil {
+			return err
+		}
+		if len(stats) == 0 {
+			fmt.Println("The embedding cache is empty.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "MODEL\tENTRIES\tSIZE\tHITS\tMISSES\tHIT RATE")
+		fmt.Fprintln(w, "-----\t-------\t----\t----\t------\t--------")
+		for _, s := range stats {
+			hitRate := 0.0
+			if lookups := s.Hits + s.Misses; lookups > 0 {
+				hitRate = float64(s.Hits) / float64(lookups) * 100
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d KB\t%d\t%d\t%.1f%%\n",
+				s.Model, s.Entries, s.Bytes/1024, s.Hits, s.Misses, hitRate)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove cached embeddings and reset the statistics.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cleared, err := dbManager.EmbeddingCache(cfg.Embedder.CacheSize).Clear(cacheClearModel)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Success: %d cached embeddings removed.\n", cleared)
+		return nil
+	},
+}
+
+func init() {
+	cacheClearCmd.Flags().StringVar(&cacheClearModel, "model", "", "Only clear embeddings from this model (defaults to every model).")
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// corsMaxAge is how long, in seconds, browsers may cache a preflight answer.
+const corsMaxAge = 600
+
+var (
+	corsAllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	corsAllowedHeaders = []string{"Authorization", "Content-Type", requestIDHeader}
+	corsExposedHeaders = []string{"Retry-After", requestIDHeader}
+)
+
+// allowCORS lets the browser origins in origins call the API. It answers
+// preflight requests itself, before authentication, since browsers send them
+// without credentials. Requests from any other origin may only read: state
+// changing methods are refused outright, and reads get no CORS headers so the
+// browser hides the response from the page. Requests without an Origin
+// header, such as those from curl or the CLI, are not affected.
+func allowCORS(origins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		allowed := slices.Contains(origins, normalizeOrigin(origin))
+		w.Header().Add("Vary", "Origin")
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			if !allowed {
+				requestLogger(r).Warn("Rejected CORS preflight from unlisted origin", "origin", origin, "path", r.URL.Path)
+				writeError(w, http.StatusForbidden, CodeForbidden, "Origin not allowed", nil)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(corsAllowedMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if !allowed {
+			if !isSafeMethod(r.Method) {
+				requestLogger(r).Warn("Rejected request from unlisted origin", "origin", origin, "method", r.Method, "path", r.URL.Path)
+				writeError(w, http.StatusForbidden, CodeForbidden, "Origin not allowed", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// normalizeOrigin lowercases the scheme and host of origin, which are case
+// insensitive, and drops a trailing slash, so configured origins and Origin
+// headers compare equal however they are written.
+func normalizeOrigin(origin string) string {
+	origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return strings.ToLower(origin)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return u.String()
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
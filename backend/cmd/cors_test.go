@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNormalizeOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   string
+	}{
+		{"http://localhost:3000", "http://localhost:3000"},
+		{"HTTP://LocalHost:3000/", "http://localhost:3000"},
+		{"chrome-extension://AbCdEf", "chrome-extension://abcdef"},
+		{" https://notes.example.com ", "https://notes.example.com"},
+		{"null", "null"},
+	}
+	for _, tt := range tests {
+		if got := normalizeOrigin(tt.origin); got != tt.want {
+			t.Errorf("normalizeOrigin(%q) = %q, want %q", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestAllowCORS(t *testing.T) {
+	origins := []string{normalizeOrigin("chrome-extension://ABCDEF/")}
+	handler := allowCORS(origins, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantAllowed bool
+	}{
+		{"no origin", http.MethodPost, "", false, http.StatusOK, false},
+		{"listed origin", http.MethodPost, "chrome-extension://abcdef", false, http.StatusOK, true},
+		{"listed origin in another case", http.MethodPost, "Chrome-Extension://AbCdEf", false, http.StatusOK, true},
+		{"listed preflight", http.MethodOptions, "chrome-extension://ABCDEF", true, http.StatusNoContent, true},
+		{"unlisted preflight", http.MethodOptions, "https://evil.example", true, http.StatusForbidden, false},
+		{"unlisted write", http.MethodDelete, "https://evil.example", false, http.StatusForbidden, false},
+		{"unlisted read", http.MethodGet, "https://evil.example", false, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api/notes", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			allowOrigin := w.Header().Get("Access-Control-Allow-Origin")
+			if tt.wantAllowed && allowOrigin != tt.origin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want the request's %q", allowOrigin, tt.origin)
+			}
+			if !tt.wantAllowed && allowOrigin != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q for an unlisted origin", allowOrigin)
+			}
+		})
+	}
+}
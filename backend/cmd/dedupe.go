@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var dedupeThreshold float64
+var dedupeNear bool
+var dedupeDryRun bool
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Find and merge duplicate notes.",
+	Long: `Group notes that are exact duplicates of each other and merge each group into
+its oldest note. Merged notes hand their tags, missing source fields and
+metadata to the kept note and are then deleted.
+
+With --near, notes whose embeddings are within --threshold (by default
+dedupe.threshold) of each other are merged too. Their content differs, and
+only the kept note's survives, so review the groups with --dry-run first.
+Near-duplicates are only found among notes embedded with the active model;
+run 'synapse reindex' first to include the others.
+
+Examples:
+  synapse dedupe --dry-run
+  synapse dedupe
+  synapse dedupe --near --dry-run
+  synapse dedupe --near --threshold 0.1`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Only exact duplicates can be merged without losing text, so
+		// near-duplicates have to be asked for.
+		threshold := 0.0
+		if dedupeNear {
+			threshold = noteService.DuplicateThreshold
+			if cmd.Flags().Changed("threshold") {
+				threshold = dedupeThreshold
+			}
+			if threshold <= 0 {
+				return fmt.Errorf("--near needs a positive --threshold or dedupe.threshold")
+			}
+		} else if cmd.Flags().Changed("threshold") {
+			return fmt.Errorf("--threshold only applies to near-duplicates, pass --near as well")
+		}
+
+		clusters, err := noteService.FindDuplicates(threshold)
+		if err != nil {
+			return err
+		}
+		if len(clusters) == 0 {
+			fmt.Println("No duplicate notes found.")
+			return nil
+		}
+
+		duplicates := 0
+		for _, cluster := range clusters {
+			ids := make([]string, len(cluster.DuplicateIds))
+			for i, id := range cluster.DuplicateIds {
+				ids[i] = strconv.Itoa(id)
+			}
+			fmt.Printf("Keep %d, merge %s\n", cluster.KeepId, strings.Join(ids, ", "))
+			duplicates += len(cluster.DuplicateIds)
+		}
+
+		if dedupeDryRun {
+			fmt.Printf("Dry run: %d duplicate notes in %d groups would be merged.\n", duplicates, len(clusters))
+			return nil
+		}
+
+		for _, cluster := range clusters {
+			if err := noteService.MergeDuplicates(cmd.Context(), cluster); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Success: %d duplicate notes merged into %d notes.\n", duplicates, len(clusters))
+		return nil
+	},
+}
+
+func init() {
+	dedupeCmd.Flags().BoolVar(&dedupeNear, "near", false, "Also merge near-duplicates, keeping only the oldest note's content.")
+	dedupeCmd.Flags().Float64Var(&dedupeThreshold, "threshold", 0, "Embedding distance under which notes are near-duplicates, with --near (defaults to dedupe.threshold).")
+	dedupeCmd.Flags().BoolVarP(&dedupeDryRun, "dry-run", "n", false, "List the duplicate groups without merging them.")
+	rootCmd.AddCommand(dedupeCmd)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"synapse/service"
+
+	"github.com/spf13/cobra"
+)
+
+var editCmd = &cobra.Command{
+	Use:   "edit <note-id>",
+	Short: "Edit a note in your text editor.",
+	Long: `Open the content of a note in $VISUAL or $EDITOR (falling back to vi) and
+save it when the editor exits.
+
+The note is only re-embedded if its content changed. Source fields can be
+changed at the same time with the flags below.
+
+Examples:
+  synapse edit 42
+  synapse edit 42 --title "Hypermedia Systems"
+  EDITOR="code --wait" synapse edit 7`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		noteID, err := strconv.Atoi(id)
+		if err != nil {
+			return fmt.Errorf("invalid ID format: %s is not a valid integer. Please provide a numeric ID", id)
+		}
+
+		note, err := noteService.GetByID(noteID)
+		if err != nil {
+			return err
+		}
+		if note == nil {
+			return fmt.Errorf("note with ID %d not found", noteID)
+		}
+
+		edited, err := editInEditor(note.Content)
+		if err != nil {
+			return err
+		}
+
+		var update service.NoteUpdate
+		if edited = strings.TrimSpace(edited); edited != strings.TrimSpace(note.Content) {
+			update.Content = &edited
+		}
+		flags := cmd.Flags()
+		for name, field := range map[string]**string{
+			"url":       &update.SourceURL,
+			"title":     &update.Title,
+			"site-name": &update.SiteName,
+			"author":    &update.Author,
+			"metadata":  &update.Metadata,
+		} {
+			if flags.Changed(name) {
+				value, _ := flags.GetString(name)
+				*field = &value
+			}
+		}
+
+		if update == (service.NoteUpdate{}) {
+			fmt.Println("No changes made.")
+			return nil
+		}
+
+		if _, err := noteService.UpdateNote(cmd.Context(), noteID, update); err != nil {
+			return err
+		}
+		fmt.Println("Success: Note updated successfully.")
+		return nil
+	},
+}
+
+// editInEditor writes content to a temporary file, opens it in the user's
+// editor and returns the saved text.
+func editInEditor(content string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	file, err := os.CreateTemp("", "synapse-note-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(content); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	// The editor setting may carry arguments, e.g. "code --wait".
+	parts := strings.Fields(editor)
+	editorCmd := exec.Command(parts[0], append(parts[1:], file.Name())...)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+	if err := editorCmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited note: %w", err)
+	}
+	return string(edited), nil
+}
+
+func init() {
+	editCmd.Flags().String("url", "", "New source URL.")
+	editCmd.Flags().String("title", "", "New title.")
+	editCmd.Flags().String("site-name", "", "New site name.")
+	editCmd.Flags().String("author", "", "New author.")
+	editCmd.Flags().String("metadata", "", "New metadata as a JSON object.")
+	rootCmd.AddCommand(editCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"synapse/client"
+	"synapse/service"
+
+	"github.com/spf13/cobra"
+)
+
+var embedPendingConcurrency int
+
+var embedPendingCmd = &cobra.Command{
+	Use:   "embed-pending",
+	Short: "Embed notes that were saved while the embedding server was down.",
+	Long: `Embed every note that was captured while the embedding server was
+unreachable. Such notes are stored right away and can be listed and found by
+keyword search, but semantic search only covers them once they are embedded.
+
+'synapse serve' does this in the background; this command is for when the
+server is not running. Like reindex, an interrupted run resumes where it
+stopped.
+
+Examples:
+  synapse embed-pending
+  synapse embed-pending --concurrency 8`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Embedding pending notes with %s...\n", noteService.Embedder.Model())
+		result, err := noteService.EmbedPending(ctx, embedPendingConcurrency, func(progress service.ReindexProgress) {
+			fmt.Printf("Progress: %d/%d notes embedded (%d failed)\n",
+				progress.Embedded, progress.Total, progress.Failed)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Printf("Interrupted: %d/%d notes embedded. Run 'synapse embed-pending' again to resume.\n",
+					result.Embedded, result.Total)
+				return nil
+			}
+			if errors.Is(err, client.ErrUnavailable) {
+				return fmt.Errorf("embedding server is still unavailable, %d notes left pending: %w",
+					result.Total-result.Embedded, err)
+			}
+			return err
+		}
+
+		if result.Total == 0 {
+			fmt.Println("No notes are waiting to be embedded.")
+			return nil
+		}
+		fmt.Printf("Success: %d notes embedded, %d failed.\n", result.Embedded, result.Failed)
+		if result.Failed > 0 {
+			fmt.Println("Run 'synapse embed-pending' again to retry the failed notes.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	embedPendingCmd.Flags().IntVarP(&embedPendingConcurrency, "concurrency", "c", service.DEFAULT_REINDEX_CONCURRENCY, "Number of embedding requests to run in parallel.")
+	rootCmd.AddCommand(embedPendingCmd)
+}
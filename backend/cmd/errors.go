@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"synapse/client"
+	"synapse/service"
+)
+
+// Error codes sent in ErrorResponse. Clients should branch on these rather
+// than on messages, which may change.
+const (
+	CodeInvalidRequest = "invalid_request"
+	CodeUnauthorized   = "unauthorized"
+	CodeForbidden      = "forbidden"
+	CodeNotFound       = "not_found"
+	CodeConflict       = "conflict"
+	CodeUnavailable    = "unavailable"
+	CodeInternal       = "internal"
+)
+
+// ErrorResponse is the body of every API error.
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+// DuplicateDetails are the details of the conflict sent when a new note
+// duplicates a stored one under the reject policy.
+type DuplicateDetails struct {
+	ExistingID int     `json:"existing_id"`
+	Distance   float64 `json:"distance,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string, details any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Code: code, Message: message, Details: details})
+}
+
+func badRequest(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusBadRequest, CodeInvalidRequest, message, nil)
+}
+
+// writeServiceError reports an error returned by the service with the status
+// its kind calls for. Internal errors are logged with the request ID and
+// hidden from the client, whose message would otherwise leak details such
+// as SQL errors.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	status, response := errorResponse(err)
+	switch status {
+	case http.StatusServiceUnavailable:
+		requestLogger(r).Warn("Model server unavailable", "error", err)
+		// Only an open circuit breaker knows when trying again makes sense.
+		var circuitOpen *client.CircuitOpenError
+		if errors.As(err, &circuitOpen) {
+			seconds := int(math.Ceil(circuitOpen.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+		}
+	case http.StatusInternalServerError:
+		requestLogger(r).Error("Request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+	}
+	writeError(w, status, response.Code, response.Message, response.Details)
+}
+
+// errorResponse maps a service error to its HTTP status and body.
+func errorResponse(err error) (int, ErrorResponse) {
+	var duplicate *service.DuplicateError
+	switch {
+	case errors.As(err, &duplicate):
+		return http.StatusConflict, ErrorResponse{
+			Code:    CodeConflict,
+			Message: duplicate.Error(),
+			Details: DuplicateDetails{ExistingID: duplicate.ExistingId, Distance: duplicate.Distance},
+		}
+	case errors.Is(err, service.ErrInvalid):
+		return http.StatusBadRequest, ErrorResponse{Code: CodeInvalidRequest, Message: err.Error()}
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound, ErrorResponse{Code: CodeNotFound, Message: err.Error()}
+	case errors.Is(err, service.ErrConflict):
+		return http.StatusConflict, ErrorResponse{Code: CodeConflict, Message: err.Error()}
+	case errors.Is(err, client.ErrUnavailable):
+		return http.StatusServiceUnavailable, ErrorResponse{
+			Code:    CodeUnavailable,
+			Message: "Embedding server is unavailable, please try again later",
+		}
+	case errors.Is(err, service.ErrNoChatModel):
+		return http.StatusServiceUnavailable, ErrorResponse{Code: CodeUnavailable, Message: "No chat model is configured"}
+	case errors.Is(err, client.ErrChatUnavailable):
+		return http.StatusServiceUnavailable, ErrorResponse{
+			Code:    CodeUnavailable,
+			Message: "Chat server is unavailable, please try again later",
+		}
+	default:
+		return http.StatusInternalServerError, ErrorResponse{Code: CodeInternal, Message: "Internal server error"}
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"synapse/client"
+	"synapse/service"
+	"testing"
+	"time"
+)
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"invalid", fmt.Errorf("wrapped: %w", service.ErrInvalid), http.StatusBadRequest, CodeInvalidRequest},
+		{"not found", service.ErrNoteNotFound, http.StatusNotFound, CodeNotFound},
+		{"conflict", service.ErrNoteNotEmbedded, http.StatusConflict, CodeConflict},
+		{"duplicate", &service.DuplicateError{ExistingId: 3}, http.StatusConflict, CodeConflict},
+		{"embedding server", &client.ServerError{Server: "test", StatusCode: 503}, http.StatusServiceUnavailable, CodeUnavailable},
+		{"circuit open", &client.CircuitOpenError{RetryAfter: time.Second}, http.StatusServiceUnavailable, CodeUnavailable},
+		{"chat server", fmt.Errorf("chat completion failed: %w", &client.ChatError{StatusCode: 502}), http.StatusServiceUnavailable, CodeUnavailable},
+		{"no chat model", service.ErrNoChatModel, http.StatusServiceUnavailable, CodeUnavailable},
+		{"rejected by chat server", &client.ChatError{StatusCode: 400}, http.StatusInternalServerError, CodeInternal},
+		{"internal", errors.New("SQL logic error"), http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, response := errorResponse(tt.err)
+			if status != tt.wantStatus || response.Code != tt.wantCode {
+				t.Errorf("errorResponse = %d %q, want %d %q", status, response.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+
+	_, embedding := errorResponse(&client.ServerError{Server: "test"})
+	_, chat := errorResponse(&client.ChatError{})
+	if embedding.Message == chat.Message {
+		t.Errorf("chat and embedding outages share the message %q", chat.Message)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"circuit open", &client.CircuitOpenError{RetryAfter: 2500 * time.Millisecond}, "3"},
+		{"trial in flight", &client.CircuitOpenError{}, "1"},
+		{"embedding server down", &client.ServerError{Server: "test"}, ""},
+		{"chat server down", &client.ChatError{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeServiceError(w, httptest.NewRequest(http.MethodGet, "/api/notes", nil), tt.err)
+			if got := w.Header().Get("Retry-After"); got != tt.want {
+				t.Errorf("Retry-After = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
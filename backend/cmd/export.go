@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"synapse/database"
+	"synapse/exporter"
+
+	"github.com/spf13/cobra"
+)
+
+var exportFormat string
+var exportOutput string
+var exportEmbeddings bool
+var exportTags []string
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export notes to JSONL, Markdown, CSV or HTML.",
+	Long: `Write notes with their metadata and tags to standard output or a file.
+
+Formats:
+  jsonl     One JSON note per line; can be read back with 'synapse import'.
+  markdown  A single document, or one file per note with YAML front-matter
+            when --output is a directory (an existing one, or a path ending
+            in /), ready for 'synapse import' or tools like Obsidian.
+  csv       One row per note with a header row.
+  html      A standalone page for reading or sharing.
+
+Use --embeddings to include each note's embedding as an array of floats
+(jsonl and csv only).
+
+Examples:
+  synapse export > backup.jsonl
+  synapse export --format markdown --output vault/
+  synapse export --format csv --tag kubernetes -o kubernetes.csv
+  synapse export --embeddings -o vectors.jsonl`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := exporter.ParseFormat(exportFormat)
+		if err != nil {
+			return err
+		}
+		filter := database.NoteFilter{Tags: exportTags}
+
+		var writer exporter.Writer
+		var out io.Writer = os.Stdout
+		flush := func() error { return nil }
+		if format == exporter.FormatMarkdown && isDirectoryOutput(exportOutput) {
+			if exportEmbeddings {
+				return fmt.Errorf("embeddings can only be exported as jsonl or csv, not %s", format)
+			}
+			writer, err = exporter.NewDirectoryWriter(exportOutput)
+			if err != nil {
+				return err
+			}
+		} else {
+			if exportOutput != "" && exportOutput != "-" {
+				file, err := os.Create(exportOutput)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
+			buffered := bufio.NewWriter(out)
+			flush = buffered.Flush
+
+			writer, err = exporter.NewWriter(format, buffered, exporter.Options{IncludeEmbeddings: exportEmbeddings})
+			if err != nil {
+				return err
+			}
+		}
+
+		count := 0
+		err = noteService.ExportNotes(filter, exportEmbeddings, func(note database.Note) error {
+			count++
+			return writer.Write(note)
+		})
+		if err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		if exportOutput != "" && exportOutput != "-" {
+			fmt.Printf("Success: Exported %d notes to %s.\n", count, exportOutput)
+		}
+		return nil
+	},
+}
+
+func isDirectoryOutput(path string) bool {
+	if path == "" || path == "-" {
+		return false
+	}
+	if strings.HasSuffix(path, "/") || strings.HasSuffix(path, string(os.PathSeparator)) {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", string(exporter.FormatJSONL), "Output format: jsonl, markdown, csv or html.")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "File or, for markdown, directory to write to (default: standard output).")
+	exportCmd.Flags().BoolVar(&exportEmbeddings, "embeddings", false, "Include embedding vectors as float arrays (jsonl and csv).")
+	exportCmd.Flags().StringSliceVarP(&exportTags, "tag", "t", nil, "Only export notes with this tag (repeatable or comma-separated).")
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"synapse/database"
+	"synapse/importer"
+	"synapse/service"
+
+	"github.com/spf13/cobra"
+)
+
+var importFormat string
+var importTags []string
+var importConcurrency int
+var importVerbose bool
+
+// importProgressInterval is how many notes pass between progress lines.
+const importProgressInterval = 100
+
+var importCmd = &cobra.Command{
+	Use:   "import <path>",
+	Short: "Import notes from files or directories.",
+	Long: `Import notes in bulk from a file or a directory tree.
+
+Formats:
+  markdown  One note per .md file. YAML front-matter sets the title, url,
+            author, site_name and tags; other keys are kept as metadata.
+  json      A note object, or an array of them, per .json file.
+  jsonl     One note object per line of a .jsonl file.
+  txt       One note per .txt file, titled after the file name.
+
+JSON notes use the same fields as the API ("content", "title", "url",
+"tags", "metadata", ...). Without --format, each file's format is taken from
+its extension. Hidden files and directories such as .obsidian are skipped,
+and notes whose content is already stored are not imported again.
+
+Examples:
+  synapse import ~/Obsidian/Research --format markdown
+  synapse import export.jsonl --tag archive
+  synapse import ./clippings --concurrency 8`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := importer.ParseFormat(importFormat)
+		if err != nil {
+			return err
+		}
+
+		var notes []database.Note
+		var sources []string
+		var parseFailures int
+		err = importer.Walk(args[0], format, func(record importer.Record) error {
+			if record.Err != nil {
+				parseFailures++
+				fmt.Fprintf(os.Stderr, "Failed: %s: %v\n", record.Source, record.Err)
+				return nil
+			}
+			record.Note.Tags = append(record.Note.Tags, importTags...)
+			notes = append(notes, record.Note)
+			sources = append(sources, record.Source)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Importing %d notes...\n", len(notes))
+		processed := 0
+		summary, err := noteService.ImportNotes(ctx, notes, importConcurrency, func(i int, id int, err error) {
+			var duplicate *service.DuplicateError
+			switch {
+			case err == nil:
+				if importVerbose {
+					fmt.Printf("Imported: %s (ID: %d)\n", sources[i], id)
+				}
+			case errors.As(err, &duplicate):
+				if importVerbose {
+					fmt.Printf("Skipped: %s: %v\n", sources[i], err)
+				}
+			default:
+				fmt.Fprintf(os.Stderr, "Failed: %s: %v\n", sources[i], err)
+			}
+
+			processed++
+			if processed%importProgressInterval == 0 {
+				fmt.Printf("Progress: %d/%d notes processed\n", processed, len(notes))
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+
+		summary.Failed += parseFailures
+		fmt.Printf("Imported: %d, skipped (duplicates): %d, failed: %d\n",
+			summary.Imported, summary.Skipped, summary.Failed)
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted: run the same import again to continue; imported notes are skipped.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVarP(&importFormat, "format", "f", "", "Input format: markdown, json, jsonl or txt (default: by file extension).")
+	importCmd.Flags().StringSliceVarP(&importTags, "tag", "t", nil, "Tag to attach to every imported note (repeatable or comma-separated).")
+	importCmd.Flags().IntVarP(&importConcurrency, "concurrency", "c", service.DEFAULT_IMPORT_CONCURRENCY, "Number of batches to embed and save in parallel.")
+	importCmd.Flags().BoolVarP(&importVerbose, "verbose", "v", false, "Print every imported and skipped file.")
+	rootCmd.AddCommand(importCmd)
+}
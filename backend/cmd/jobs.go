@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"synapse/service"
+	"sync"
+)
+
+// jobRunner runs the jobs started through the API in the background of the
+// server, on its NoteService, so that their progress reaches GET /api/events.
+// Only one job of each kind runs at a time.
+type jobRunner struct {
+	ctx context.Context
+	wg  sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[service.JobKind]bool
+}
+
+// serveJobs is set while serve runs.
+var serveJobs *jobRunner
+
+// newJobRunner returns a runner whose jobs are cancelled along with ctx.
+func newJobRunner(ctx context.Context) *jobRunner {
+	return &jobRunner{ctx: ctx, running: make(map[service.JobKind]bool)}
+}
+
+// start runs job unless one of the same kind is still running, and reports
+// whether it did.
+func (j *jobRunner) start(kind service.JobKind, job func(ctx context.Context) error) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.running[kind] {
+		return false
+	}
+	j.running[kind] = true
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		if err := job(j.ctx); err != nil && j.ctx.Err() == nil {
+			slog.Error("Job failed", "job", kind, "error", err)
+		}
+		j.mu.Lock()
+		delete(j.running, kind)
+		j.mu.Unlock()
+	}()
+	return true
+}
+
+// wait blocks until every job has returned.
+func (j *jobRunner) wait() {
+	j.wg.Wait()
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"synapse/database"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Inspect and apply database schema migrations.",
+	Long: `Manage the versioned schema migrations of the notes database.
+
+Pending migrations are applied automatically whenever Synapse opens the
+database. Use these subcommands to inspect the current state or to roll back
+the most recent migration.
+
+Examples:
+  synapse migrate status
+  synapse migrate up
+  synapse migrate down`,
+	// Overrides the root hook so the schema is not migrated before the
+	// subcommand gets a chance to inspect it.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+
+		database.RegisterCustomDriver()
+
+		var err error
+		dbManager, err = database.Open(cfg.Database.Path)
+		if err != nil {
+			return fmt.Errorf("Failed to open database: %w", err)
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List migrations and whether they have been applied.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		statuses, err := dbManager.MigrationStatuses()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		fmt.Fprintln(w, "-------\t----\t------\t----------")
+
+		for _, s := range statuses {
+			if s.Applied {
+				fmt.Fprintf(w, "%d\t%s\tapplied\t%s\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Fprintf(w, "%d\t%s\tpending\t-\n", s.Version, s.Name)
+			}
+		}
+		w.Flush()
+
+		return nil
+	},
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply all pending migrations.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		applied, err := dbManager.MigrateUp()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Success: Applied %d migration(s).\n", applied)
+		return nil
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Roll back the most recently applied migration.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		migration, err := dbManager.MigrateDown()
+		if err != nil {
+			return err
+		}
+
+		if migration == nil {
+			fmt.Println("No applied migrations to roll back.")
+			return nil
+		}
+		fmt.Printf("Success: Rolled back migration %d (%s).\n", migration.Version, migration.Name)
+		return nil
+	},
+}
+
+func init() {
+	migrateCmd.AddCommand(migrateStatusCmd, migrateUpCmd, migrateDownCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"synapse/client"
+	"synapse/service"
+
+	"github.com/spf13/cobra"
+)
+
+var reindexModel string
+var reindexConcurrency int
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Re-embed notes that were embedded with a different model.",
+	Long: `Re-embed every note whose stored embedding came from a model other than the
+active one, so that semantic search covers it again. Search only compares
+vectors from the active model.
+
+Notes are processed in batches and saved one by one, so an interrupted run
+(e.g. with Ctrl+C) resumes where it stopped when started again.
+
+Use --model to re-embed with a model other than the configured one, for
+example before switching to it; searches keep using the configured model
+until embedder.model is changed.
+
+Examples:
+  synapse reindex
+  synapse reindex --concurrency 8
+  synapse --embedder ollama reindex --model nomic-embed-text`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc := noteService
+		if reindexModel != "" && reindexModel != noteService.Embedder.Model() {
+			reindexConfig := embedderConfig()
+			reindexConfig.Model = reindexModel
+			embedder, err := client.NewEmbedder(reindexConfig)
+			if err != nil {
+				return fmt.Errorf("failed to configure embedder: %w", err)
+			}
+			// The persisted index belongs to the configured model, so this
+			// service keeps its own in memory only.
+			svc = service.NewNoteService(dbManager, embedder, "")
+			svc.VectorFormat = noteService.VectorFormat
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Re-embedding notes with %s...\n", svc.Embedder.Model())
+		result, err := svc.Reindex(ctx, reindexConcurrency, func(progress service.ReindexProgress) {
+			fmt.Printf("Progress: %d/%d notes re-embedded (%d failed)\n",
+				progress.Embedded, progress.Total, progress.Failed)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Printf("Interrupted: %d/%d notes re-embedded. Run 'synapse reindex' again to resume.\n",
+					result.Embedded, result.Total)
+				return nil
+			}
+			return err
+		}
+
+		if result.Total == 0 {
+			fmt.Println("All notes are already embedded with this model.")
+			return nil
+		}
+		fmt.Printf("Success: %d notes re-embedded, %d failed.\n", result.Embedded, result.Failed)
+		if result.Failed > 0 {
+			fmt.Println("Run 'synapse reindex' again to retry the failed notes.")
+		}
+		return nil
+	},
+}
+
+func init() {
+	reindexCmd.Flags().StringVar(&reindexModel, "model", "", "Embedding model to re-embed with (defaults to the configured model).")
+	reindexCmd.Flags().IntVarP(&reindexConcurrency, "concurrency", "c", service.DEFAULT_REINDEX_CONCURRENCY, "Number of embedding requests to run in parallel.")
+	rootCmd.AddCommand(reindexCmd)
+}
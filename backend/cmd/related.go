@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"synapse/database"
+	"synapse/service"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var relatedLimit int
+var relatedTags []string
+var relatedSince string
+var relatedUntil string
+
+var relatedCmd = &cobra.Command{
+	Use:   "related <id>",
+	Short: "Show the notes most similar to a note.",
+	Long: `List the notes closest in meaning to the note with the given ID, using its
+stored embedding, so the embedding server is not needed. The note itself is
+left out. Restrict the candidates by tag or by creation date; dates are
+YYYY-MM-DD or RFC 3339 timestamps and --until includes the whole day.
+
+Examples:
+  synapse related 42
+  synapse related 42 -n 5 --tag kubernetes
+  synapse related 42 --since 2024-01-01 --until 2024-06-30`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid note ID %q: must be an integer", args[0])
+		}
+
+		filter := database.NoteFilter{Tags: relatedTags}
+		if filter.Since, err = parseDateBound(relatedSince, false); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+		if filter.Until, err = parseDateBound(relatedUntil, true); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+
+		notes, err := noteService.Related(id, relatedLimit, filter)
+		if err != nil {
+			return err
+		}
+		if len(notes) == 0 {
+			fmt.Println("No related notes found.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "ID\tDISTANCE\tCONTENT")
+		fmt.Fprintln(w, "--\t--------\t-------")
+		for _, note := range notes {
+			fmt.Fprintf(w, "%d\t%.4f\t%s\n", note.Id, note.Distance, matchedText(note))
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+// parseDateBound reads a YYYY-MM-DD date or an RFC 3339 timestamp. A bare
+// date used as an upper bound moves to the start of the next day, so that
+// the bound covers the whole day it names.
+func parseDateBound(value string, upper bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		if upper {
+			date = date.AddDate(0, 0, 1)
+		}
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func init() {
+	relatedCmd.Flags().IntVarP(&relatedLimit, "limit", "n", service.DEFAULT_SEARCH_LIMIT, "Maximum number of related notes to return.")
+	relatedCmd.Flags().StringSliceVarP(&relatedTags, "tag", "t", nil, "Only return notes carrying this tag (repeatable; all must match).")
+	relatedCmd.Flags().StringVar(&relatedSince, "since", "", "Only return notes created on or after this date.")
+	relatedCmd.Flags().StringVar(&relatedUntil, "until", "", "Only return notes created on or before this date.")
+	rootCmd.AddCommand(relatedCmd)
+}
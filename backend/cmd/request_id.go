@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const requestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds client-supplied request IDs, which end up in logs.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// withRequestID gives every request an ID, reusing the client's X-Request-Id
+// when it sends a usable one, and echoes it in the response. Each request is
+// logged with its ID once it completes, as are errors logged through
+// requestLogger, so a failure reported by a client can be found in the logs.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+
+		slog.Info("Request handled", "request_id", id, "method", r.Method, "path", r.URL.Path,
+			"status", recorder.status, "duration", time.Since(start))
+	})
+}
+
+// requestLogger returns the default logger tagged with the request's ID.
+func requestLogger(r *http.Request) *slog.Logger {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return slog.With("request_id", id)
+}
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// validRequestID accepts printable ASCII without spaces, so a client cannot
+// forge log lines or headers through its ID.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder remembers the status code written through it. Unwrap lets
+// http.ResponseController reach the underlying writer to flush streams.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(data []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.ResponseWriter.Write(data)
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
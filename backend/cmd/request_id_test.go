@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"synapse/database"
+	"testing"
+)
+
+// captureLogs sends the default logger's JSON output to the returned buffer
+// for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &logs
+}
+
+func TestWithRequestID(t *testing.T) {
+	generated := regexp.MustCompile(`^[0-9a-f]{16}$`)
+	tests := []struct {
+		name   string
+		sent   string
+		echoed bool
+	}{
+		{"client ID", "req-42.a_b:c", true},
+		{"longest allowed", strings.Repeat("x", maxRequestIDLength), true},
+		{"none", "", false},
+		{"too long", strings.Repeat("x", maxRequestIDLength+1), false},
+		{"space", "req 42", false},
+		{"control character", "req\x0142", false},
+		{"non-ASCII", "réq", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen, _ = r.Context().Value(requestIDKey{}).(string)
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/api/notes", nil)
+			if tt.sent != "" {
+				r.Header.Set(requestIDHeader, tt.sent)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			got := w.Header().Get(requestIDHeader)
+			if tt.echoed && got != tt.sent {
+				t.Errorf("%s = %q, want the client's %q echoed", requestIDHeader, got, tt.sent)
+			}
+			if !tt.echoed && !generated.MatchString(got) {
+				t.Errorf("%s = %q, want a generated ID", requestIDHeader, got)
+			}
+			if seen != got {
+				t.Errorf("handler saw ID %q, response carries %q", seen, got)
+			}
+		})
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	s := useTestService(t)
+	pending, err := s.DBManager.SaveNote(database.Note{Content: "pending", EmbeddingStatus: database.EMBEDDING_PENDING}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/notes/{id}", handleGetNoteById)
+	mux.HandleFunc("GET /api/notes/{id}/related", handleRelatedNotes)
+	mux.HandleFunc("GET /api/broken", func(w http.ResponseWriter, r *http.Request) {
+		writeServiceError(w, r, errors.New("SQL logic error near secret_table"))
+	})
+	handler := withRequestID(mux)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"/api/notes/abc", http.StatusBadRequest, CodeInvalidRequest},
+		{"/api/notes/999", http.StatusNotFound, CodeNotFound},
+		{"/api/notes/999/related", http.StatusNotFound, CodeNotFound},
+		{"/api/notes/" + strconv.Itoa(pending) + "/related", http.StatusConflict, CodeConflict},
+		{"/api/notes/1/related?k=-1", http.StatusBadRequest, CodeInvalidRequest},
+		{"/api/broken", http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			logs := captureLogs(t)
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Header.Set(requestIDHeader, "trace-1")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if got := w.Header().Get(requestIDHeader); got != "trace-1" {
+				t.Errorf("%s = %q, want trace-1 echoed", requestIDHeader, got)
+			}
+			var response ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("invalid error body %q: %v", w.Body.String(), err)
+			}
+			if response.Code != tt.wantCode || response.Message == "" {
+				t.Errorf("body = %+v, want code %q and a message", response, tt.wantCode)
+			}
+			if strings.Contains(response.Message, "secret_table") {
+				t.Errorf("internal error leaked to the client: %q", response.Message)
+			}
+			if !strings.Contains(logs.String(), `"request_id":"trace-1"`) || !strings.Contains(logs.String(), `"status":`+strconv.Itoa(tt.wantStatus)) {
+				t.Errorf("request was not logged with its ID and status:\n%s", logs)
+			}
+		})
+	}
+}
@@ -4,42 +4,129 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"synapse/client"
+	"synapse/config"
 	"synapse/database"
 	"synapse/service"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var dbManager *database.SQLiteManager
-var dbFilepath = "synapse.db"
 var noteService *service.NoteService
+var cfg config.Config
+
+// embeddingCache is the cache behind the embedder, if one is configured. Its
+// use statistics are written to the database when the command finishes.
+var embeddingCache *database.EmbeddingCache
+
+// Values of the persistent flags. They only override the configuration file
+// and environment when set explicitly on the command line.
+var (
+	configFlag          string
+	dbFlag              string
+	embedderFlag        string
+	embedderURLFlag     string
+	embedderModelFlag   string
+	embedderAPIKeyFlag  string
+	embedderTimeoutFlag time.Duration
+)
 
 var rootCmd = &cobra.Command{
 	Use:   "synapse",
 	Short: "Synapse: A high-performance local notes and embedding tool.",
-	Long:  `Synapse allows you to capture notes, generate embeddings, and search your knowledge base semantically.`,
+	Long: `Synapse allows you to capture notes, generate embeddings, and search your knowledge base semantically.
+
+Settings are read from $XDG_CONFIG_HOME/synapse/config.yaml (or --config),
+then from environment variables, then from command-line flags, each
+overriding the last. Environment variables are named SYNAPSE_<SECTION>_<KEY>,
+such as SYNAPSE_DATABASE_PATH or SYNAPSE_EMBEDDER_BATCH_SIZE. For example:
+
+  database:
+    path: /home/me/notes/work.db
+    vector_format: int8
+  server:
+    addr: 127.0.0.1:8080
+    auth: true
+    cors_origins:
+      - chrome-extension://abcdefghijklmnopabcdefghijklmnop
+  embedder:
+    provider: ollama
+    model: nomic-embed-text
+    timeout: 30s
+    batch_size: 32
+    flush_interval: 10ms
+    max_in_flight: 4
+    max_retries: 3
+    breaker_threshold: 5
+    breaker_cooldown: 30s
+    cache_size: 10000
+  dedupe:
+    policy: reject
+    threshold: 0.05
+  chat:
+    url: http://127.0.0.1:1234/v1/chat/completions
+    model: qwen2.5-7b-instruct
+    context_notes: 5`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+
 		database.RegisterCustomDriver()
 
 		var err error
-		dbManager, err = database.Initialize("synapse.db")
+		dbManager, err = database.Initialize(cfg.Database.Path)
 		if err != nil {
 			return fmt.Errorf("Failed to initialize database: %w", err)
 		}
-		if err := dbManager.SetupSchema(); err != nil {
-			return fmt.Errorf("failed to setup schema: %w", err)
-		}
 
-		slog.Debug("Database initialized and ready.", "path", dbFilepath)
+		slog.Debug("Database initialized and ready.", "path", cfg.Database.Path)
 
-		noteService = service.NewNoteService(dbManager)
+		embedder, err := client.NewEmbedder(embedderConfig())
+		if err != nil {
+			return fmt.Errorf("failed to configure embedder: %w", err)
+		}
+		slog.Debug("Embedder configured.", "provider", cfg.Embedder.Provider, "model", embedder.Model())
+
+		noteService = service.NewNoteService(dbManager, embedder, cfg.Database.Path+".hnsw")
+		noteService.VectorFormat, err = database.ParseVectorFormat(cfg.Database.VectorFormat)
+		if err != nil {
+			return err
+		}
+		noteService.DedupePolicy, err = service.ParseDedupePolicy(cfg.Dedupe.Policy)
+		if err != nil {
+			return err
+		}
+		if noteService.DedupePolicy == "" {
+			noteService.DedupePolicy = service.DedupeReject
+		}
+		noteService.DuplicateThreshold = cfg.Dedupe.Threshold
+		noteService.Chat = client.NewOpenAIChat(client.ChatConfig{
+			URL:         cfg.Chat.URL,
+			Model:       cfg.Chat.Model,
+			APIKey:      cfg.Chat.APIKey,
+			Timeout:     cfg.Chat.Timeout,
+			Temperature: cfg.Chat.Temperature,
+		})
 
 		return nil
 	},
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if noteService != nil {
+			if err := noteService.SaveIndex(); err != nil {
+				slog.Error("Failed to persist vector index", "error", err)
+			}
+		}
 		if dbManager == nil || dbManager.DB == nil {
 			return
 		}
+		if embeddingCache != nil {
+			if err := embeddingCache.Flush(); err != nil {
+				slog.Error("Failed to save embedding cache statistics", "error", err)
+			}
+		}
 		if err := dbManager.DB.Close(); err != nil {
 			slog.Error("Failed to close database connection", "error", err)
 		} else {
@@ -51,6 +138,77 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// loadConfig fills cfg from the config file and environment, then applies
+// any persistent flags the user set and validates the result.
+func loadConfig(cmd *cobra.Command) error {
+	loaded, path, err := config.Load(configFlag)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	cfg = loaded
+	if path != "" {
+		slog.Debug("Configuration file loaded.", "path", path)
+	}
+
+	flags := cmd.Flags()
+	overrides := map[string]func(){
+		"db":               func() { cfg.Database.Path = dbFlag },
+		"embedder":         func() { cfg.Embedder.Provider = embedderFlag },
+		"embedder-url":     func() { cfg.Embedder.URL = embedderURLFlag },
+		"embedder-model":   func() { cfg.Embedder.Model = embedderModelFlag },
+		"embedder-api-key": func() { cfg.Embedder.APIKey = embedderAPIKeyFlag },
+		"embedder-timeout": func() { cfg.Embedder.Timeout = embedderTimeoutFlag },
+	}
+	for name, apply := range overrides {
+		if flags.Changed(name) {
+			apply()
+		}
+	}
+	return cfg.Validate()
+}
+
+// embedderConfig converts the loaded embedder settings for the client package.
+// The embedding cache lives in the database, so dbManager must be open. Every
+// embedder configured this way shares one cache, so that the usage it keeps
+// in memory is all flushed by the post-run hook.
+func embedderConfig() client.EmbedderConfig {
+	embedder := client.EmbedderConfig{
+		Provider:      cfg.Embedder.Provider,
+		URL:           cfg.Embedder.URL,
+		Model:         cfg.Embedder.Model,
+		APIKey:        cfg.Embedder.APIKey,
+		Timeout:       cfg.Embedder.Timeout,
+		BatchSize:     cfg.Embedder.BatchSize,
+		FlushInterval: cfg.Embedder.FlushInterval,
+		MaxInFlight:   cfg.Embedder.MaxInFlight,
+
+		MaxRetries:       cfg.Embedder.MaxRetries,
+		RetryBackoff:     cfg.Embedder.RetryBackoff,
+		RetryMaxBackoff:  cfg.Embedder.RetryMaxBackoff,
+		BreakerThreshold: cfg.Embedder.BreakerThreshold,
+		BreakerCooldown:  cfg.Embedder.BreakerCooldown,
+	}
+	if cfg.Embedder.CacheSize > 0 {
+		if embeddingCache == nil {
+			embeddingCache = dbManager.EmbeddingCache(cfg.Embedder.CacheSize)
+		}
+		embedder.Cache = embeddingCache
+	}
+	return embedder
+}
+
+func init() {
+	defaults := config.Default()
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&configFlag, "config", "", "Path to the YAML config file (default $XDG_CONFIG_HOME/synapse/config.yaml).")
+	flags.StringVar(&dbFlag, "db", defaults.Database.Path, "Path to the notes database.")
+	flags.StringVar(&embedderFlag, "embedder", defaults.Embedder.Provider, "Embedding provider: lmstudio, ollama or openai.")
+	flags.StringVar(&embedderURLFlag, "embedder-url", "", "Embeddings endpoint URL (defaults to the provider's local endpoint).")
+	flags.StringVar(&embedderModelFlag, "embedder-model", "", "Embedding model name (defaults to the provider's default model).")
+	flags.StringVar(&embedderAPIKeyFlag, "embedder-api-key", "", "Bearer token sent to OpenAI-compatible endpoints.")
+	flags.DurationVar(&embedderTimeoutFlag, "embedder-timeout", defaults.Embedder.Timeout, "Timeout for each embedding request.")
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -4,21 +4,41 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"synapse/database"
+	"synapse/service"
 	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 )
 
 var searchById bool
+var searchMode string
+var searchTags []string
+var searchLimit int
+var searchOffset int
+var searchMaxDistance float64
 var searchCmd = &cobra.Command{
 	Use:   "search <query>",
 	Short: "Search notes by semantic similarity or by ID.",
 	Long: `Search your notes database in two ways:
 
-Semantic Search (default):
-  Converts your query text to an embedding and finds the top 10 most similar notes.
+Semantic Search (default, --mode semantic):
+  Converts your query text to an embedding and finds the most similar notes,
+  showing the passage of each note that matched best. Use --max-distance to drop
+  weak matches.
   Results are sorted by distance (lower distance = higher similarity).
 
+Keyword Search (--mode keyword):
+  Full-text search over note content and titles, ranked by BM25. Best for exact
+  identifiers, names and error strings.
+
+Hybrid Search (--mode hybrid):
+  Runs both searches and fuses the rankings with reciprocal rank fusion.
+  Results are sorted by score (higher score = better match).
+
+All modes return 10 results by default; page through more with --limit and
+--offset.
+
 ID Search (with --id flag):
   Retrieves a specific note using its numeric ID. When using this flag, provide
   a numeric argument instead of text.
@@ -26,6 +46,11 @@ ID Search (with --id flag):
 Examples:
   synapse search "quantum mechanics"           # Semantic search
   synapse search "deep learning"               # Semantic search
+  synapse search "ECONNREFUSED" --mode keyword # Keyword search
+  synapse search "k8s ingress" --mode hybrid   # Hybrid search
+  synapse search "scaling" --tag kubernetes    # Only notes tagged kubernetes
+  synapse search "raft" -n 20 --offset 20      # Results 21-40
+  synapse search "raft" --max-distance 0.4     # Only close matches
   synapse search 42 --id                       # Get note with ID 42
   synapse search 7 -i                          # Short flag: get note with ID 7`,
 	Args: cobra.MinimumNArgs(1),
@@ -44,24 +69,50 @@ Examples:
 			}
 
 			if note != nil {
-				fmt.Printf("Note Found (ID: %d)\nContent:\n%s\n", note.Id, note.Content)
+				fmt.Printf("Note Found (ID: %d)\n", note.Id)
+				if note.Title != "" {
+					fmt.Printf("Title: %s\n", note.Title)
+				}
+				if note.SourceURL != "" {
+					fmt.Printf("URL: %s\n", note.SourceURL)
+				}
+				fmt.Printf("Content:\n%s\n", note.Content)
 			} else {
 				fmt.Printf("Note with ID %d not found.\n", noteId)
 			}
 			return nil
 		}
 
-		notes, err := noteService.SemanticSearch(cmd.Context(), input)
+		mode, err := service.ParseSearchMode(searchMode)
 		if err != nil {
 			return err
 		}
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-		fmt.Fprintln(w, "ID\tDISTANCE\tCONTENT")
-		fmt.Fprintln(w, "--\t--------\t-------")
+		opts := database.SearchOptions{
+			NoteFilter:  database.NoteFilter{Tags: searchTags},
+			Limit:       searchLimit,
+			Offset:      searchOffset,
+			MaxDistance: searchMaxDistance,
+		}
+
+		notes, err := noteService.Search(cmd.Context(), input, mode, opts)
+		if err != nil {
+			return err
+		}
 
-		for _, note := range notes {
-			fmt.Fprintf(w, "%d\t%.4f\t%s\n", note.Id, note.Distance, note.Content)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		if mode == service.SearchModeSemantic {
+			fmt.Fprintln(w, "ID\tDISTANCE\tCONTENT")
+			fmt.Fprintln(w, "--\t--------\t-------")
+			for _, note := range notes {
+				fmt.Fprintf(w, "%d\t%.4f\t%s\n", note.Id, note.Distance, matchedText(note))
+			}
+		} else {
+			fmt.Fprintln(w, "ID\tSCORE\tCONTENT")
+			fmt.Fprintln(w, "--\t-----\t-------")
+			for _, note := range notes {
+				fmt.Fprintf(w, "%d\t%.4f\t%s\n", note.Id, note.Score, matchedText(note))
+			}
 		}
 		w.Flush()
 
@@ -69,7 +120,20 @@ Examples:
 	},
 }
 
+// matchedText prefers the passage that matched the query over the full note.
+func matchedText(note database.Note) string {
+	if note.Snippet != "" {
+		return note.Snippet
+	}
+	return note.Content
+}
+
 func init() {
+	searchCmd.Flags().StringVarP(&searchMode, "mode", "m", string(service.SearchModeSemantic), "Search mode: keyword, semantic or hybrid.")
+	searchCmd.Flags().StringSliceVarP(&searchTags, "tag", "t", nil, "Only return notes carrying this tag (repeatable; all must match).")
+	searchCmd.Flags().IntVarP(&searchLimit, "limit", "n", service.DEFAULT_SEARCH_LIMIT, "Maximum number of results to return.")
+	searchCmd.Flags().IntVar(&searchOffset, "offset", 0, "Number of results to skip, for paging.")
+	searchCmd.Flags().Float64Var(&searchMaxDistance, "max-distance", 0, "Drop semantic matches farther than this distance (0 disables).")
 	searchCmd.Flags().BoolVarP(&searchById, "id", "i", false, "Search by exact Note ID instead of content.")
 	rootCmd.AddCommand(searchCmd)
 }
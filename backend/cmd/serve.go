@@ -1,48 +1,288 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"synapse/config"
+	"synapse/database"
+	"synapse/exporter"
+	"synapse/importer"
+	"synapse/service"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 type NoteResponse struct {
-	ID       int     `json:"id"`
-	Content  string  `json:"content"`
-	Distance float64 `json:"distance,omitempty"`
+	ID        int             `json:"id"`
+	Content   string          `json:"content"`
+	URL       string          `json:"url,omitempty"`
+	Title     string          `json:"title,omitempty"`
+	SiteName  string          `json:"site_name,omitempty"`
+	Author    string          `json:"author,omitempty"`
+	Metadata  json.RawMessage `json:"metadata,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Distance  float64         `json:"distance,omitempty"`
+	Score     float64         `json:"score,omitempty"`
+	Snippet   string          `json:"snippet,omitempty"`
+	Tags      []string        `json:"tags"`
+
+	EmbeddingModel  string `json:"embedding_model,omitempty"`
+	EmbeddingDim    int    `json:"embedding_dim,omitempty"`
+	EmbeddingStatus string `json:"embedding_status,omitempty"`
+}
+
+type TagResponse struct {
+	Name      string `json:"name"`
+	NoteCount int    `json:"note_count"`
 }
 
+// AddNoteRequest accepts both the legacy "input" field and the "content"
+// field sent by the browser extension. OnDuplicate overrides the configured
+// duplicate policy: reject, merge or allow.
 type AddNoteRequest struct {
-	Content string `json:"input"`
+	Input    string          `json:"input"`
+	Content  string          `json:"content"`
+	URL      string          `json:"url"`
+	Title    string          `json:"title"`
+	SiteName string          `json:"site_name"`
+	Author   string          `json:"author"`
+	Metadata json.RawMessage `json:"metadata"`
+	Tags     []string        `json:"tags"`
+
+	OnDuplicate string `json:"on_duplicate"`
 }
 
+// UpdateNoteRequest carries the fields to change; omitted fields are kept.
+type UpdateNoteRequest struct {
+	Content  *string         `json:"content"`
+	URL      *string         `json:"url"`
+	Title    *string         `json:"title"`
+	SiteName *string         `json:"site_name"`
+	Author   *string         `json:"author"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// AskRequest is the body of POST /api/ask. With Stream set the answer is
+// sent as server-sent events: "delta" events carrying {"text": ...} as the
+// answer is written, then a "done" event with the AskResponse, or an "error"
+// event carrying an ErrorResponse.
+type AskRequest struct {
+	Question    string   `json:"question"`
+	Tags        []string `json:"tags"`
+	Notes       int      `json:"notes"`
+	MaxDistance float64  `json:"max_distance"`
+	Stream      bool     `json:"stream"`
+}
+
+type AskResponse struct {
+	Answer    string         `json:"answer"`
+	Citations []int          `json:"citations"`
+	Sources   []NoteResponse `json:"sources"`
+}
+
+// NoteEventResponse is the data of the note.created, note.updated and
+// note.deleted events of GET /api/events.
+type NoteEventResponse struct {
+	ID int `json:"id"`
+}
+
+// JobProgressResponse is the data of job.progress events. Job is import,
+// reindex or embed_pending; finished is set on a job's last event.
+type JobProgressResponse struct {
+	Job      string `json:"job"`
+	Total    int    `json:"total"`
+	Done     int    `json:"done"`
+	Skipped  int    `json:"skipped,omitempty"`
+	Failed   int    `json:"failed"`
+	Finished bool   `json:"finished"`
+}
+
+// eventKeepAlive is how often an idle event stream gets a comment line, so
+// proxies and the browser don't time it out.
+const eventKeepAlive = 30 * time.Second
+
 type SemanticSearchRequest struct {
-	Content string `json:"input"`
+	Content     string   `json:"input"`
+	Mode        string   `json:"mode"`
+	Tags        []string `json:"tags"`
+	Limit       int      `json:"limit"`
+	Offset      int      `json:"offset"`
+	MaxDistance float64  `json:"max_distance"`
+}
+
+// NotePageResponse is one page of GET /api/notes. NextCursor is passed back
+// as ?cursor= to fetch the following page and is omitted on the last page.
+type NotePageResponse struct {
+	Data       []NoteResponse `json:"data"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+func toNoteResponse(note database.Note) NoteResponse {
+	response := NoteResponse{
+		ID:        note.Id,
+		Content:   note.Content,
+		URL:       note.SourceURL,
+		Title:     note.Title,
+		SiteName:  note.SiteName,
+		Author:    note.Author,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+		Distance:  note.Distance,
+		Score:     note.Score,
+		Snippet:   note.Snippet,
+		Tags:      note.Tags,
+
+		EmbeddingModel:  note.EmbeddingModel,
+		EmbeddingDim:    note.EmbeddingDim,
+		EmbeddingStatus: note.EmbeddingStatus,
+	}
+	if note.Metadata != "" && note.Metadata != "{}" {
+		response.Metadata = json.RawMessage(note.Metadata)
+	}
+	return response
+}
+
+func toNoteResponses(notes []database.Note) []NoteResponse {
+	responses := make([]NoteResponse, 0, len(notes))
+	for _, note := range notes {
+		responses = append(responses, toNoteResponse(note))
+	}
+	return responses
 }
 
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Start the Synapse API server",
+	Long: `Serve the notes over a JSON API under /api.
+
+Requests need a bearer token created with 'synapse token create' unless
+server.auth is false. Browsers may only call the API from the origins listed
+in server.cors_origins or with --cors-origin, for example the extension's
+chrome-extension://<id> origin, or https://example.com for content scripts
+running on that site. Other origins cannot create, change or delete notes.
+
+GET /api/events streams note changes and the progress of jobs run by this
+server: pending embeddings, and imports and reindexes started with
+POST /api/import and POST /api/reindex. The 'synapse import' and
+'synapse reindex' commands run in their own process, so their progress is
+not streamed.
+
+Examples:
+  synapse serve
+  synapse serve --addr 127.0.0.1:8080 --cors-origin chrome-extension://abcdefghijklmnopabcdefghijklmnop`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		mux := http.NewServeMux()
 
 		mux.HandleFunc("POST /api/notes", handleAddNote)
 		mux.HandleFunc("GET /api/notes", handleGetAllNotes)
 		mux.HandleFunc("GET /api/notes/{id}", handleGetNoteById)
+		mux.HandleFunc("PUT /api/notes/{id}", handleUpdateNote)
+		mux.HandleFunc("PATCH /api/notes/{id}", handleUpdateNote)
 		mux.HandleFunc("DELETE /api/notes/{id}", handleDeleteNoteById)
+		mux.HandleFunc("GET /api/notes/{id}/related", handleRelatedNotes)
 		mux.HandleFunc("POST /api/search", handleSemanticSearch)
+		mux.HandleFunc("POST /api/ask", handleAsk)
+		mux.HandleFunc("GET /api/tags", handleListTags)
+		mux.HandleFunc("GET /api/export", handleExport)
+		mux.HandleFunc("POST /api/import", handleImport)
+		mux.HandleFunc("POST /api/reindex", handleReindex)
+		mux.HandleFunc("GET /api/events", handleEvents)
+
+		if cmd.Flags().Changed("addr") {
+			cfg.Server.Addr = serveAddr
+		}
+		port := cfg.Server.Addr
+
+		var handler http.Handler = mux
+		if cfg.Server.Auth {
+			handler = requireToken(mux)
+			if tokens, err := noteService.ListTokens(); err == nil && len(tokens) == 0 {
+				slog.Warn("No API tokens exist yet, so every request will be refused. Create one with 'synapse token create --scope write'.")
+			}
+		} else {
+			slog.Warn("API authentication is disabled; any local process or web page can change your notes.")
+		}
+		if cmd.Flags().Changed("cors-origin") {
+			cfg.Server.CORSOrigins = serveCORSOrigins
+		}
+		origins := make([]string, 0, len(cfg.Server.CORSOrigins))
+		for _, origin := range cfg.Server.CORSOrigins {
+			origins = append(origins, normalizeOrigin(origin))
+		}
+		handler = withRequestID(allowCORS(origins, handler))
+
+		server := &http.Server{Addr: port, Handler: handler}
+		// Event streams never end on their own, so Shutdown would wait for
+		// them until it timed out.
+		server.RegisterOnShutdown(noteService.Events.Close)
 
-		port := ":8080"
-		slog.Info("Server starting...", "addr", port)
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 
-		return http.ListenAndServe(port, mux)
+		serveJobs = newJobRunner(ctx)
+
+		// The workers and jobs must be done with the database and the index
+		// before the post-run hook saves the index and closes the database.
+		var workers sync.WaitGroup
+		workers.Add(2)
+		go func() {
+			defer workers.Done()
+			noteService.RunPendingWorker(ctx, servePendingInterval)
+		}()
+		go func() {
+			defer workers.Done()
+			noteService.RunIndexSaver(ctx, serveIndexSaveInterval)
+		}()
+		defer func() {
+			stop()
+			workers.Wait()
+			serveJobs.wait()
+		}()
+
+		serverErr := make(chan error, 1)
+		go func() {
+			slog.Info("Server starting...", "addr", port)
+			serverErr <- server.ListenAndServe()
+		}()
+
+		select {
+		case err := <-serverErr:
+			return err
+		case <-ctx.Done():
+		}
+
+		// Shut down cleanly so the post-run hook can persist the vector index
+		// and close the database.
+		slog.Info("Server shutting down...")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
 	},
 }
 
+var serveAddr string
+var servePendingInterval time.Duration
+var serveIndexSaveInterval time.Duration
+var serveCORSOrigins []string
+
 func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", config.DEFAULT_SERVER_ADDR, "Address to listen on, e.g. 127.0.0.1:8080.")
+	serveCmd.Flags().DurationVar(&servePendingInterval, "pending-interval", service.DEFAULT_PENDING_INTERVAL, "How often to retry embedding notes saved while the embedding server was down.")
+	serveCmd.Flags().DurationVar(&serveIndexSaveInterval, "index-save-interval", service.DEFAULT_INDEX_SAVE_INTERVAL, "How often to persist the vector index while serving.")
+	serveCmd.Flags().StringSliceVar(&serveCORSOrigins, "cors-origin", nil, "Browser origin allowed to call the API (repeatable; replaces server.cors_origins).")
 	rootCmd.AddCommand(serveCmd)
 }
 
@@ -50,30 +290,86 @@ func handleAddNote(w http.ResponseWriter, r *http.Request) {
 	var req AddNoteRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		badRequest(w, "Invalid JSON body")
+		return
+	}
+
+	content := req.Content
+	if content == "" {
+		content = req.Input
+	}
+	if strings.TrimSpace(content) == "" {
+		badRequest(w, "Note content is required")
+		return
+	}
+	policy, err := service.ParseDedupePolicy(req.OnDuplicate)
+	if err != nil {
+		badRequest(w, err.Error())
 		return
 	}
 
-	if err := noteService.CreateNote(r.Context(), req.Content); err != nil {
-		slog.Error("Create failed", "error", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	note := database.Note{
+		Content:   content,
+		SourceURL: req.URL,
+		Title:     req.Title,
+		SiteName:  req.SiteName,
+		Author:    req.Author,
+		Metadata:  string(req.Metadata),
+		Tags:      req.Tags,
+	}
+
+	id, merged, err := noteService.CreateNoteWithPolicy(r.Context(), note, policy)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if merged {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"status": "Note merged into an existing note", "id": id, "merged": true})
 		return
 	}
 
+	// A pending note is saved but not yet searchable by meaning, which
+	// 202 Accepted tells the client.
+	saved, err := noteService.GetByID(id)
+	if err == nil && saved == nil {
+		err = fmt.Errorf("created note %d disappeared", id)
+	}
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	status := http.StatusCreated
+	if saved.EmbeddingStatus == database.EMBEDDING_PENDING {
+		status = http.StatusAccepted
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "Note saved successfully"})
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"status": "Note saved successfully", "id": id, "embedding_status": saved.EmbeddingStatus})
 }
 
 func handleGetAllNotes(w http.ResponseWriter, r *http.Request) {
-	notes, err := noteService.GetAll()
+	query := r.URL.Query()
+
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil {
+			badRequest(w, "limit must be an integer")
+			return
+		}
+	}
+
+	filter := database.NoteFilter{Tags: queryTags(r)}
+	notes, nextCursor, err := noteService.ListNotes(filter, query.Get("cursor"), limit)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(notes)
+	json.NewEncoder(w).Encode(NotePageResponse{Data: toNoteResponses(notes), NextCursor: nextCursor})
 }
 
 func handleGetNoteById(w http.ResponseWriter, r *http.Request) {
@@ -81,34 +377,114 @@ func handleGetNoteById(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "ID must be an integer", http.StatusBadRequest)
+		badRequest(w, "ID must be an integer")
 		return
 	}
 
 	note, err := noteService.GetByID(id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, r, err)
 		return
 	}
 	if note == nil {
-		http.Error(w, "Note not found", http.StatusNotFound)
+		writeServiceError(w, r, fmt.Errorf("%w: %d", service.ErrNoteNotFound, id))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toNoteResponse(*note))
+}
+
+// handleRelatedNotes lists the notes closest to a note. ?k sets how many,
+// ?tag restricts them by tag and ?since and ?until by creation date.
+func handleRelatedNotes(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		badRequest(w, "ID must be an integer")
+		return
+	}
+
+	query := r.URL.Query()
+	k := 0
+	if kStr := query.Get("k"); kStr != "" {
+		if k, err = strconv.Atoi(kStr); err != nil || k < 0 || k > service.MAX_SEARCH_LIMIT {
+			badRequest(w, fmt.Sprintf("k must be an integer between 1 and %d", service.MAX_SEARCH_LIMIT))
+			return
+		}
+	}
+
+	filter := database.NoteFilter{Tags: queryTags(r)}
+	if filter.Since, err = parseDateBound(query.Get("since"), false); err != nil {
+		badRequest(w, "since must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+		return
+	}
+	if filter.Until, err = parseDateBound(query.Get("until"), true); err != nil {
+		badRequest(w, "until must be a date (YYYY-MM-DD) or RFC 3339 timestamp")
+		return
+	}
+
+	notes, err := noteService.Related(id, k, filter)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toNoteResponses(notes))
+}
+
+// handleUpdateNote serves both PUT and PATCH. PUT must include the content,
+// PATCH may change any subset of fields.
+func handleUpdateNote(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		badRequest(w, "ID must be an integer")
+		return
+	}
+
+	var req UpdateNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "Invalid JSON body")
+		return
+	}
+	if r.Method == http.MethodPut && req.Content == nil {
+		badRequest(w, "Note content is required")
+		return
+	}
+
+	update := service.NoteUpdate{
+		Content:   req.Content,
+		SourceURL: req.URL,
+		Title:     req.Title,
+		SiteName:  req.SiteName,
+		Author:    req.Author,
+	}
+	if req.Metadata != nil {
+		metadata := string(req.Metadata)
+		update.Metadata = &metadata
+	}
+
+	note, err := noteService.UpdateNote(r.Context(), id, update)
+	if err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(note)
+	json.NewEncoder(w).Encode(toNoteResponse(*note))
 }
 
 func handleDeleteNoteById(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "ID must be an integer", http.StatusBadRequest)
+		badRequest(w, "ID must be an integer")
 		return
 	}
 
 	if err := noteService.Delete(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeServiceError(w, r, err)
 		return
 	}
 
@@ -121,16 +497,308 @@ func handleSemanticSearch(w http.ResponseWriter, r *http.Request) {
 	var req SemanticSearchRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		badRequest(w, "Invalid JSON body")
 		return
 	}
 
-	notes, err := noteService.SemanticSearch(r.Context(), req.Content)
+	mode, err := service.ParseSearchMode(req.Mode)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		badRequest(w, err.Error())
 		return
 	}
 
+	opts := database.SearchOptions{
+		NoteFilter:  database.NoteFilter{Tags: req.Tags},
+		Limit:       req.Limit,
+		Offset:      req.Offset,
+		MaxDistance: req.MaxDistance,
+	}
+
+	notes, err := noteService.Search(r.Context(), req.Content, mode, opts)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toNoteResponses(notes))
+}
+
+func handleListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := noteService.ListTags()
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := make([]TagResponse, 0, len(tags))
+	for _, tag := range tags {
+		response = append(response, TagResponse{Name: tag.Name, NoteCount: tag.NoteCount})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// exportFlushInterval is how many notes are written between flushes of a
+// streamed export, so clients see progress on large archives.
+const exportFlushInterval = 100
+
+// handleExport streams notes in the format given by ?format= (jsonl by
+// default), optionally with ?embeddings=true and tag filters.
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	formatName := query.Get("format")
+	if formatName == "" {
+		formatName = string(exporter.FormatJSONL)
+	}
+	format, err := exporter.ParseFormat(formatName)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	includeEmbeddings := false
+	if value := query.Get("embeddings"); value != "" {
+		if includeEmbeddings, err = strconv.ParseBool(value); err != nil {
+			badRequest(w, "embeddings must be true or false")
+			return
+		}
+	}
+
+	writer, err := exporter.NewWriter(format, w, exporter.Options{IncludeEmbeddings: includeEmbeddings})
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="synapse-export`+format.Extension()+`"`)
+
+	controller := http.NewResponseController(w)
+	count := 0
+	filter := database.NoteFilter{Tags: queryTags(r)}
+	err = noteService.ExportNotes(filter, includeEmbeddings, func(note database.Note) error {
+		if err := writer.Write(note); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushInterval == 0 {
+			controller.Flush()
+		}
+		return r.Context().Err()
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// Headers are already sent, so the client only sees a truncated body.
+		requestLogger(r).Error("Export failed", "exported", count, "error", err)
+	}
+}
+
+func handleAsk(w http.ResponseWriter, r *http.Request) {
+	var req AskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "Invalid JSON body")
+		return
+	}
+	if strings.TrimSpace(req.Question) == "" {
+		badRequest(w, "Question is required")
+		return
+	}
+	if req.Notes < 0 || req.Notes > service.MAX_SEARCH_LIMIT {
+		badRequest(w, fmt.Sprintf("notes must be between 1 and %d", service.MAX_SEARCH_LIMIT))
+		return
+	}
+	if req.Notes == 0 {
+		req.Notes = cfg.Chat.ContextNotes
+	}
+
+	opts := service.AskOptions{
+		NoteFilter:  database.NoteFilter{Tags: req.Tags},
+		Notes:       req.Notes,
+		MaxDistance: req.MaxDistance,
+	}
+
+	if !req.Stream {
+		answer, err := noteService.Ask(r.Context(), req.Question, opts, nil)
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(toAskResponse(answer))
+		return
+	}
+
+	startSSE(w)
+	answer, err := noteService.Ask(r.Context(), req.Question, opts, func(delta string) error {
+		return writeSSE(w, "delta", map[string]string{"text": delta})
+	})
+	if err != nil {
+		// The status line is already sent, so the error becomes an event.
+		status, response := errorResponse(err)
+		if status == http.StatusInternalServerError {
+			requestLogger(r).Error("Ask failed", "error", err)
+		}
+		writeSSE(w, "error", response)
+		return
+	}
+	writeSSE(w, "done", toAskResponse(answer))
+}
+
+// maxImportBodySize bounds the body of POST /api/import.
+const maxImportBodySize = 64 << 20
+
+// handleImport imports the notes in the body, JSON or JSONL as given by
+// ?format= (jsonl by default), in the background with any ?tag= attached.
+// Notes that cannot be parsed are counted in the response; the import's
+// progress is published to GET /api/events.
+func handleImport(w http.ResponseWriter, r *http.Request) {
+	format, err := importer.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	if format == importer.FormatAuto {
+		format = importer.FormatJSONL
+	}
+	if format != importer.FormatJSON && format != importer.FormatJSONL {
+		badRequest(w, "The API imports json or jsonl; use 'synapse import' for other formats")
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, CodeInvalidRequest,
+				fmt.Sprintf("Import body exceeds %d bytes", tooLarge.Limit), nil)
+			return
+		}
+		badRequest(w, "Failed to read the request body")
+		return
+	}
+
+	tags := queryTags(r)
+	var notes []database.Note
+	failed := 0
+	importer.Read("body", data, format, func(record importer.Record) error {
+		if record.Err != nil {
+			failed++
+			requestLogger(r).Warn("Skipping unreadable note", "source", record.Source, "error", record.Err)
+			return nil
+		}
+		record.Note.Tags = append(record.Note.Tags, tags...)
+		notes = append(notes, record.Note)
+		return nil
+	})
+	if len(notes) == 0 {
+		badRequest(w, "The request body holds no notes")
+		return
+	}
+
+	started := serveJobs.start(service.JobImport, func(ctx context.Context) error {
+		summary, err := noteService.ImportNotes(ctx, notes, 0, nil)
+		slog.Info("Import finished", "imported", summary.Imported, "skipped", summary.Skipped, "failed", summary.Failed)
+		return err
+	})
+	if !started {
+		writeError(w, http.StatusConflict, CodeConflict, "An import is already running", nil)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"status": "Import started", "job": service.JobImport, "total": len(notes), "failed": failed})
+}
+
+// handleReindex re-embeds, in the background, every note embedded with a
+// model other than the configured one. Its progress is published to
+// GET /api/events.
+func handleReindex(w http.ResponseWriter, r *http.Request) {
+	started := serveJobs.start(service.JobReindex, func(ctx context.Context) error {
+		result, err := noteService.Reindex(ctx, 0, nil)
+		slog.Info("Reindex finished", "embedded", result.Embedded, "skipped", result.Skipped, "failed", result.Failed)
+		return err
+	})
+	if !started {
+		writeError(w, http.StatusConflict, CodeConflict, "A reindex is already running", nil)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(notes)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"status": "Reindex started", "job": service.JobReindex})
+}
+
+// handleEvents streams changes to the notes and the progress of jobs run by
+// this server as server-sent events, named after service.EventType. Jobs run
+// by other synapse processes, such as the import and reindex commands, are
+// not seen; POST /api/import and /api/reindex run them here instead. A client
+// that falls behind is disconnected; EventSource then reconnects, and a
+// client should reload its note list whenever the stream (re)opens, since
+// events published while it was away are not replayed.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	events, unsubscribe := noteService.Events.Subscribe(service.DEFAULT_EVENT_BUFFER)
+	defer unsubscribe()
+
+	startSSE(w)
+	if err := writeSSEComment(w, "connected"); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			err = writeSSEComment(w, "keep-alive")
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			err = writeSSE(w, string(event.Type), toEventResponse(event))
+		}
+		if err != nil {
+			requestLogger(r).Debug("Event stream closed", "error", err)
+			return
+		}
+	}
+}
+
+func toEventResponse(event service.Event) any {
+	if event.Job == nil {
+		return NoteEventResponse{ID: event.NoteId}
+	}
+	return JobProgressResponse{
+		Job:      string(event.Job.Job),
+		Total:    event.Job.Total,
+		Done:     event.Job.Done,
+		Skipped:  event.Job.Skipped,
+		Failed:   event.Job.Failed,
+		Finished: event.Job.Finished,
+	}
+}
+
+func toAskResponse(answer service.Answer) AskResponse {
+	return AskResponse{
+		Answer:    answer.Text,
+		Citations: answer.Citations,
+		Sources:   toNoteResponses(answer.Sources),
+	}
+}
+
+// queryTags reads tag filters given as ?tags=a,b and/or repeated ?tag=a.
+func queryTags(r *http.Request) []string {
+	query := r.URL.Query()
+	tags := query["tag"]
+	for _, value := range query["tags"] {
+		tags = append(tags, strings.Split(value, ",")...)
+	}
+	return tags
 }
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"synapse/database"
+	"synapse/service"
+	"sync"
+	"testing"
+)
+
+var registerDriver sync.Once
+
+// stubEmbedder embeds every input as [1, 0].
+type stubEmbedder struct{}
+
+func (stubEmbedder) Model() string { return "m" }
+
+func (stubEmbedder) GenerateEmbedding(ctx context.Context, input string) ([]float64, error) {
+	return []float64{1, 0}, nil
+}
+
+// useTestService points the handlers at a service over a migrated database
+// in a temporary directory for the rest of the test.
+func useTestService(t *testing.T) *service.NoteService {
+	t.Helper()
+	registerDriver.Do(database.RegisterCustomDriver)
+
+	dir := t.TempDir()
+	manager, err := database.Initialize(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	t.Cleanup(func() { manager.DB.Close() })
+
+	previous := noteService
+	noteService = service.NewNoteService(manager, stubEmbedder{}, filepath.Join(dir, "test.db.hnsw"))
+	t.Cleanup(func() { noteService = previous })
+	return noteService
+}
+
+// serve runs handler on r, decodes the JSON response into response if it is
+// not nil, and returns the status code.
+func serve(t *testing.T, handler http.HandlerFunc, r *http.Request, response any) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if response != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), response); err != nil {
+			t.Fatalf("%s %s: invalid JSON response %q: %v", r.Method, r.URL, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func TestAddNoteFromExtension(t *testing.T) {
+	s := useTestService(t)
+	s.DuplicateThreshold = 0
+
+	body := `{"url": "https://example.com/a", "title": "A page", "site_name": "Example",
+		"content": "Selected text.", "metadata": {"lang": "en"}, "tags": ["web"]}`
+	var created struct {
+		ID int `json:"id"`
+	}
+	if status := serve(t, handleAddNote, httptest.NewRequest("POST", "/api/notes", strings.NewReader(body)), &created); status != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", status, http.StatusCreated)
+	}
+
+	r := httptest.NewRequest("GET", "/api/notes/"+strconv.Itoa(created.ID), nil)
+	r.SetPathValue("id", strconv.Itoa(created.ID))
+	var note NoteResponse
+	if status := serve(t, handleGetNoteById, r, &note); status != http.StatusOK {
+		t.Fatalf("GET status = %d, want %d", status, http.StatusOK)
+	}
+	if note.Content != "Selected text." || note.URL != "https://example.com/a" || note.Title != "A page" ||
+		note.SiteName != "Example" || string(note.Metadata) != `{"lang":"en"}` || fmt.Sprint(note.Tags) != "[web]" {
+		t.Errorf("note = %+v, want the captured fields", note)
+	}
+
+	// Older clients send the text as "input".
+	if status := serve(t, handleAddNote, httptest.NewRequest("POST", "/api/notes", strings.NewReader(`{"input": "Legacy text."}`)), nil); status != http.StatusCreated {
+		t.Errorf("legacy input: status = %d, want %d", status, http.StatusCreated)
+	}
+
+	for _, body := range []string{`not json`, `{"title": "no content"}`, `{"content": "x", "metadata": [1]}`} {
+		var response ErrorResponse
+		status := serve(t, handleAddNote, httptest.NewRequest("POST", "/api/notes", strings.NewReader(body)), &response)
+		if status != http.StatusBadRequest || response.Code != CodeInvalidRequest {
+			t.Errorf("body %s: %d %+v, want 400 %s", body, status, response, CodeInvalidRequest)
+		}
+	}
+}
+
+func TestTagEndpoints(t *testing.T) {
+	s := useTestService(t)
+	s.DuplicateThreshold = 0
+	ctx := context.Background()
+	tagged, err := s.CreateNote(ctx, database.Note{Content: "Pods.", Tags: []string{"kubernetes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateNote(ctx, database.Note{Content: "Bread."}); err != nil {
+		t.Fatal(err)
+	}
+
+	var tags []TagResponse
+	if code := serve(t, handleListTags, httptest.NewRequest("GET", "/api/tags", nil), &tags); code != http.StatusOK {
+		t.Fatalf("GET /api/tags status = %d", code)
+	}
+	if len(tags) != 1 || tags[0] != (TagResponse{Name: "kubernetes", NoteCount: 1}) {
+		t.Errorf("tags = %+v, want kubernetes on one note", tags)
+	}
+
+	for _, url := range []string{"/api/notes?tag=Kubernetes", "/api/notes?tags=kubernetes,"} {
+		var page NotePageResponse
+		if code := serve(t, handleGetAllNotes, httptest.NewRequest("GET", url, nil), &page); code != http.StatusOK {
+			t.Fatalf("GET %s status = %d", url, code)
+		}
+		if len(page.Data) != 1 || page.Data[0].ID != tagged {
+			t.Errorf("GET %s = %+v, want only note %d", url, page.Data, tagged)
+		}
+	}
+}
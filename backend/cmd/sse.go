@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// startSSE prepares w for a server-sent event stream.
+func startSSE(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop reverse proxies such as nginx from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeSSE sends one event with data encoded as JSON and flushes it to the
+// client straight away.
+func writeSSE(w http.ResponseWriter, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+// writeSSEComment sends a comment line, which clients ignore, to keep an
+// idle stream open.
+func writeSSEComment(w http.ResponseWriter, comment string) error {
+	if _, err := fmt.Fprintf(w, ": %s\n\n", comment); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Manage note tags.",
+	Long: `Attach, detach and list tags. Tags are case-insensitive and can be used to
+restrict searches with 'synapse search --tag'.
+
+Examples:
+  synapse tag add 42 kubernetes networking
+  synapse tag remove 42 networking
+  synapse tag list
+  synapse tag list 42`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add <note-id> <tag>...",
+	Short: "Attach tags to a note.",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		noteID, err := parseNoteID(args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := noteService.AddTags(noteID, args[1:]); err != nil {
+			return err
+		}
+		fmt.Println("Success: Tags added.")
+		return nil
+	},
+}
+
+var tagRemoveCmd = &cobra.Command{
+	Use:   "remove <note-id> <tag>...",
+	Short: "Detach tags from a note.",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		noteID, err := parseNoteID(args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := noteService.RemoveTags(noteID, args[1:]); err != nil {
+			return err
+		}
+		fmt.Println("Success: Tags removed.")
+		return nil
+	},
+}
+
+var tagListCmd = &cobra.Command{
+	Use:   "list [note-id]",
+	Short: "List all tags, or the tags of one note.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			noteID, err := parseNoteID(args[0])
+			if err != nil {
+				return err
+			}
+
+			note, err := noteService.GetByID(noteID)
+			if err != nil {
+				return err
+			}
+			if note == nil {
+				return fmt.Errorf("note with ID %d not found", noteID)
+			}
+			if len(note.Tags) == 0 {
+				fmt.Printf("Note %d has no tags.\n", noteID)
+				return nil
+			}
+			fmt.Println(strings.Join(note.Tags, "\n"))
+			return nil
+		}
+
+		tags, err := noteService.ListTags()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "TAG\tNOTES")
+		fmt.Fprintln(w, "---\t-----")
+		for _, tag := range tags {
+			fmt.Fprintf(w, "%s\t%d\n", tag.Name, tag.NoteCount)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+func parseNoteID(id string) (int, error) {
+	noteID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID format: %s is not a valid integer. Please provide a numeric ID", id)
+	}
+	return noteID, nil
+}
+
+func init() {
+	tagCmd.AddCommand(tagAddCmd, tagRemoveCmd, tagListCmd)
+	rootCmd.AddCommand(tagCmd)
+}
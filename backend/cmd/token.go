@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"synapse/service"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var tokenScope string
+var tokenName string
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage API tokens for the HTTP server.",
+	Long: `Every /api route of 'synapse serve' requires a bearer token, sent as an
+"Authorization: Bearer <token>" header. Read tokens may list, search and ask;
+write tokens may also create, change and delete notes. Only a hash of each
+token is stored, so a token is shown once, when it is created.
+
+Set server.auth to false in the config file to turn authentication off.
+
+Examples:
+  synapse token create --scope write --name extension
+  synapse token create --scope read
+  synapse token list
+  synapse token revoke 3`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an API token and print it.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scope, err := service.ParseTokenScope(tokenScope)
+		if err != nil {
+			return err
+		}
+
+		token, stored, err := noteService.CreateToken(tokenName, scope)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Success: Created %s token %d. Copy it now, it will not be shown again:\n\n", stored.Scope, stored.Id)
+		fmt.Println(token)
+		return nil
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API tokens.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tokens, err := noteService.ListTokens()
+		if err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			fmt.Println("No API tokens found.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tTOKEN\tSCOPE\tCREATED\tLAST USED")
+		fmt.Fprintln(w, "--\t----\t-----\t-----\t-------\t---------")
+		for _, token := range tokens {
+			lastUsed := "never"
+			if !token.LastUsedAt.IsZero() {
+				lastUsed = token.LastUsedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s…\t%s\t%s\t%s\n", token.Id, token.Name, token.Prefix, token.Scope,
+				token.CreatedAt.Local().Format(time.DateTime), lastUsed)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <token-id>",
+	Short: "Revoke an API token.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid token ID %q: must be an integer", args[0])
+		}
+
+		if err := noteService.RevokeToken(id); err != nil {
+			return err
+		}
+		fmt.Println("Success: Token revoked.")
+		return nil
+	},
+}
+
+func init() {
+	tokenCreateCmd.Flags().StringVar(&tokenScope, "scope", string(service.ScopeRead), "What the token may do: read or write.")
+	tokenCreateCmd.Flags().StringVar(&tokenName, "name", "", "A label to recognise the token by, e.g. extension.")
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenListCmd)
+	tokenCmd.AddCommand(tokenRevokeCmd)
+	rootCmd.AddCommand(tokenCmd)
+}
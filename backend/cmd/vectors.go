@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"synapse/database"
+
+	"github.com/spf13/cobra"
+)
+
+var vectorsConvertFormat string
+var vectorsConvertVacuum bool
+
+var vectorsCmd = &cobra.Command{
+	Use:   "vectors",
+	Short: "Manage how embedding vectors are stored.",
+	Long: `Rewrite the encoding of stored embedding vectors.
+
+Vectors can be stored as float64 (the original format), float32, int8 (scalar
+quantization, about 8x smaller than float64) or binary (signs only, about 64x
+smaller but noticeably less accurate). New notes use database.vector_format
+from the config file.
+
+Examples:
+  synapse vectors convert --format float32
+  synapse vectors convert --format int8`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var vectorsConvertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Rewrite every stored vector in another format.",
+	Long: `Re-encode the embeddings of all notes and chunks in the given format, then
+vacuum the database to return the freed space. Conversion runs in batches and
+can be interrupted and run again.
+
+Converting to int8 or binary is lossy: converting back to float32 afterwards
+does not restore the original precision.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := database.ParseVectorFormat(vectorsConvertFormat)
+		if err != nil {
+			return err
+		}
+
+		sizeBefore := fileSize(cfg.Database.Path)
+		fmt.Printf("Converting vectors to %s...\n", format)
+		converted, err := dbManager.ConvertVectors(format, func(converted int) {
+			fmt.Printf("Progress: %d vectors converted\n", converted)
+		})
+		if err != nil {
+			return err
+		}
+
+		if vectorsConvertVacuum && converted > 0 {
+			if err := dbManager.Vacuum(); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Success: %d vectors converted to %s.\n", converted, format)
+		if sizeBefore > 0 {
+			fmt.Printf("Database size: %d KB -> %d KB\n", sizeBefore/1024, fileSize(cfg.Database.Path)/1024)
+		}
+		if format != noteService.VectorFormat {
+			fmt.Printf("Note: new notes are still stored as %s; set database.vector_format to %s to match.\n",
+				noteService.VectorFormat, format)
+		}
+		return nil
+	},
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func init() {
+	vectorsConvertCmd.Flags().StringVarP(&vectorsConvertFormat, "format", "f", database.DEFAULT_VECTOR_FORMAT.String(), "Target format: float64, float32, int8 or binary.")
+	vectorsConvertCmd.Flags().BoolVar(&vectorsConvertVacuum, "vacuum", true, "Vacuum the database afterwards to shrink the file.")
+	vectorsCmd.AddCommand(vectorsConvertCmd)
+	rootCmd.AddCommand(vectorsCmd)
+}
@@ -0,0 +1,332 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DEFAULT_DB_PATH     = "synapse.db"
+	DEFAULT_SERVER_ADDR = ":8080"
+	// DEFAULT_VECTOR_FORMAT matches database.DEFAULT_VECTOR_FORMAT.
+	DEFAULT_VECTOR_FORMAT = "float32"
+	ENV_PREFIX            = "SYNAPSE_"
+)
+
+// Config holds every runtime setting. Values are layered, each overriding the
+// last: built-in defaults, the YAML config file, SYNAPSE_<SECTION>_<KEY>
+// environment variables and finally command-line flags. Validate checks the
+// result once every layer is applied.
+type Config struct {
+	Database DatabaseConfig `yaml:"database"`
+	Server   ServerConfig   `yaml:"server"`
+	Embedder EmbedderConfig `yaml:"embedder"`
+	Dedupe   DedupeConfig   `yaml:"dedupe"`
+	Chat     ChatConfig     `yaml:"chat"`
+}
+
+// DatabaseConfig holds storage settings. VectorFormat is how new embeddings
+// are encoded: float64, float32, int8 or binary.
+type DatabaseConfig struct {
+	Path         string `yaml:"path"`
+	VectorFormat string `yaml:"vector_format"`
+}
+
+// DedupeConfig controls duplicate detection when notes are created. Policy is
+// reject, merge or allow; Threshold is the embedding distance under which a
+// note counts as a near-duplicate, with 0 matching exact duplicates only.
+type DedupeConfig struct {
+	Policy    string  `yaml:"policy"`
+	Threshold float64 `yaml:"threshold"`
+}
+
+// ChatConfig mirrors client.ChatConfig and sets how many notes `synapse ask`
+// retrieves. An empty URL uses LM Studio; an empty Model lets the server pick.
+type ChatConfig struct {
+	URL          string        `yaml:"url"`
+	Model        string        `yaml:"model"`
+	APIKey       string        `yaml:"api_key"`
+	Timeout      time.Duration `yaml:"timeout"`
+	Temperature  float64       `yaml:"temperature"`
+	ContextNotes int           `yaml:"context_notes"`
+}
+
+// ServerConfig holds the API server settings. Auth requires a bearer token
+// created with `synapse token create` on every /api route. CORSOrigins lists
+// the browser origins, such as chrome-extension://<id>, allowed to call the
+// API from a web page or extension.
+type ServerConfig struct {
+	Addr        string   `yaml:"addr"`
+	Auth        bool     `yaml:"auth"`
+	CORSOrigins []string `yaml:"cors_origins"`
+}
+
+// EmbedderConfig mirrors client.EmbedderConfig. Empty URL and Model fall back
+// to the provider defaults.
+type EmbedderConfig struct {
+	Provider      string        `yaml:"provider"`
+	URL           string        `yaml:"url"`
+	Model         string        `yaml:"model"`
+	APIKey        string        `yaml:"api_key"`
+	Timeout       time.Duration `yaml:"timeout"`
+	BatchSize     int           `yaml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	MaxInFlight   int           `yaml:"max_in_flight"`
+
+	MaxRetries       int           `yaml:"max_retries"`
+	RetryBackoff     time.Duration `yaml:"retry_backoff"`
+	RetryMaxBackoff  time.Duration `yaml:"retry_max_backoff"`
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown"`
+
+	// CacheSize is how many embeddings the cache keeps; 0 disables it.
+	CacheSize int `yaml:"cache_size"`
+}
+
+func Default() Config {
+	return Config{
+		Database: DatabaseConfig{Path: DEFAULT_DB_PATH, VectorFormat: DEFAULT_VECTOR_FORMAT},
+		Server:   ServerConfig{Addr: DEFAULT_SERVER_ADDR, Auth: true},
+		Embedder: EmbedderConfig{
+			Provider:      "lmstudio",
+			Timeout:       30 * time.Second,
+			BatchSize:     32,
+			FlushInterval: 10 * time.Millisecond,
+			MaxInFlight:   4,
+
+			MaxRetries:       3,
+			RetryBackoff:     250 * time.Millisecond,
+			RetryMaxBackoff:  5 * time.Second,
+			BreakerThreshold: 5,
+			BreakerCooldown:  30 * time.Second,
+
+			CacheSize: 10000,
+		},
+		Dedupe: DedupeConfig{Policy: "reject", Threshold: 0.05},
+		Chat: ChatConfig{
+			Timeout:      5 * time.Minute,
+			Temperature:  0.2,
+			ContextNotes: 5,
+		},
+	}
+}
+
+// DefaultPath is config.yaml inside the synapse directory of the user's
+// config dir, i.e. $XDG_CONFIG_HOME/synapse/config.yaml on Linux.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "synapse", "config.yaml"), nil
+}
+
+// Load builds the configuration from defaults, the config file and the
+// environment. path names the config file; when empty, SYNAPSE_CONFIG and
+// then DefaultPath are tried, and a missing default file is not an error.
+// Values are not range checked, since flags may still override them; call
+// Validate once they have been applied.
+func Load(path string) (Config, string, error) {
+	cfg := Default()
+
+	explicit := path != ""
+	if !explicit {
+		path = os.Getenv(ENV_PREFIX + "CONFIG")
+		explicit = path != ""
+	}
+	if !explicit {
+		defaultPath, err := DefaultPath()
+		if err == nil {
+			path = defaultPath
+		}
+	}
+
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			if explicit || !errors.Is(err, os.ErrNotExist) {
+				return cfg, path, err
+			}
+			path = ""
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, path, err
+	}
+	return cfg, path, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	// An empty file is reported as io.EOF and simply changes nothing.
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	stringVars := map[string]*string{
+		"DATABASE_PATH":          &cfg.Database.Path,
+		"DATABASE_VECTOR_FORMAT": &cfg.Database.VectorFormat,
+		"SERVER_ADDR":            &cfg.Server.Addr,
+		"EMBEDDER_PROVIDER":      &cfg.Embedder.Provider,
+		"EMBEDDER_URL":           &cfg.Embedder.URL,
+		"EMBEDDER_MODEL":         &cfg.Embedder.Model,
+		"EMBEDDER_API_KEY":       &cfg.Embedder.APIKey,
+		"DEDUPE_POLICY":          &cfg.Dedupe.Policy,
+		"CHAT_URL":               &cfg.Chat.URL,
+		"CHAT_MODEL":             &cfg.Chat.Model,
+		"CHAT_API_KEY":           &cfg.Chat.APIKey,
+	}
+	for name, field := range stringVars {
+		if value, ok := os.LookupEnv(ENV_PREFIX + name); ok {
+			*field = value
+		}
+	}
+
+	durationVars := map[string]*time.Duration{
+		"EMBEDDER_TIMEOUT":           &cfg.Embedder.Timeout,
+		"EMBEDDER_FLUSH_INTERVAL":    &cfg.Embedder.FlushInterval,
+		"EMBEDDER_RETRY_BACKOFF":     &cfg.Embedder.RetryBackoff,
+		"EMBEDDER_BREAKER_COOLDOWN":  &cfg.Embedder.BreakerCooldown,
+		"EMBEDDER_RETRY_MAX_BACKOFF": &cfg.Embedder.RetryMaxBackoff,
+		"CHAT_TIMEOUT":               &cfg.Chat.Timeout,
+	}
+	for name, field := range durationVars {
+		if value, ok := os.LookupEnv(ENV_PREFIX + name); ok {
+			duration, err := parseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s%s: %w", ENV_PREFIX, name, err)
+			}
+			*field = duration
+		}
+	}
+
+	intVars := map[string]*int{
+		"EMBEDDER_BATCH_SIZE":        &cfg.Embedder.BatchSize,
+		"EMBEDDER_MAX_IN_FLIGHT":     &cfg.Embedder.MaxInFlight,
+		"EMBEDDER_MAX_RETRIES":       &cfg.Embedder.MaxRetries,
+		"EMBEDDER_BREAKER_THRESHOLD": &cfg.Embedder.BreakerThreshold,
+		"EMBEDDER_CACHE_SIZE":        &cfg.Embedder.CacheSize,
+		"CHAT_CONTEXT_NOTES":         &cfg.Chat.ContextNotes,
+	}
+	for name, field := range intVars {
+		if value, ok := os.LookupEnv(ENV_PREFIX + name); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s%s: must be an integer", ENV_PREFIX, name)
+			}
+			*field = n
+		}
+	}
+
+	// Lists are comma-separated.
+	if value, ok := os.LookupEnv(ENV_PREFIX + "SERVER_CORS_ORIGINS"); ok {
+		cfg.Server.CORSOrigins = splitList(value)
+	}
+
+	if value, ok := os.LookupEnv(ENV_PREFIX + "SERVER_AUTH"); ok {
+		auth, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %sSERVER_AUTH: must be true or false", ENV_PREFIX)
+		}
+		cfg.Server.Auth = auth
+	}
+
+	if value, ok := os.LookupEnv(ENV_PREFIX + "DEDUPE_THRESHOLD"); ok {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %sDEDUPE_THRESHOLD: must be a number", ENV_PREFIX)
+		}
+		cfg.Dedupe.Threshold = threshold
+	}
+	return nil
+}
+
+// Validate reports the first setting that is out of range, named as in the
+// config file. Batches and answers need at least one input; retries, the
+// circuit breaker and the cache may be disabled with zero, and zero durations
+// fall back to the defaults.
+func (cfg Config) Validate() error {
+	if cfg.Database.Path == "" {
+		return errors.New("invalid database.path: must not be empty")
+	}
+
+	minimums := []struct {
+		name  string
+		value int
+		min   int
+	}{
+		{"embedder.batch_size", cfg.Embedder.BatchSize, 1},
+		{"embedder.max_in_flight", cfg.Embedder.MaxInFlight, 1},
+		{"embedder.max_retries", cfg.Embedder.MaxRetries, 0},
+		{"embedder.breaker_threshold", cfg.Embedder.BreakerThreshold, 0},
+		{"embedder.cache_size", cfg.Embedder.CacheSize, 0},
+		{"chat.context_notes", cfg.Chat.ContextNotes, 1},
+	}
+	for _, m := range minimums {
+		if m.value < m.min {
+			return fmt.Errorf("invalid %s: must be at least %d, got %d", m.name, m.min, m.value)
+		}
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"embedder.timeout", cfg.Embedder.Timeout},
+		{"embedder.flush_interval", cfg.Embedder.FlushInterval},
+		{"embedder.retry_backoff", cfg.Embedder.RetryBackoff},
+		{"embedder.retry_max_backoff", cfg.Embedder.RetryMaxBackoff},
+		{"embedder.breaker_cooldown", cfg.Embedder.BreakerCooldown},
+		{"chat.timeout", cfg.Chat.Timeout},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			return fmt.Errorf("invalid %s: must not be negative, got %s", d.name, d.value)
+		}
+	}
+
+	// Cosine distances range from 0 to 2.
+	if cfg.Dedupe.Threshold < 0 || cfg.Dedupe.Threshold > 2 {
+		return fmt.Errorf("invalid dedupe.threshold: must be between 0 and 2, got %g", cfg.Dedupe.Threshold)
+	}
+	if cfg.Chat.Temperature < 0 || cfg.Chat.Temperature > 2 {
+		return fmt.Errorf("invalid chat.temperature: must be between 0 and 2, got %g", cfg.Chat.Temperature)
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseDuration accepts Go durations ("45s") or a bare number of seconds.
+func parseDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes yaml to a config file and returns its path. It also
+// unsets the caller's SYNAPSE_* variables so only the test's own apply.
+func writeConfig(t *testing.T, yaml string) string {
+	t.Helper()
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(name, ENV_PREFIX) {
+			t.Setenv(name, "")
+			os.Unsetenv(name)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default().Validate() = %v", err)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	path := writeConfig(t, `
+database:
+  path: file.db
+  vector_format: int8
+embedder:
+  batch_size: 8
+  timeout: 10s
+`)
+	t.Setenv("SYNAPSE_DATABASE_PATH", "env.db")
+	t.Setenv("SYNAPSE_EMBEDDER_TIMEOUT", "45")
+	t.Setenv("SYNAPSE_SERVER_CORS_ORIGINS", "chrome-extension://a, http://localhost:3000,")
+
+	cfg, loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != path {
+		t.Errorf("loaded path = %q, want %q", loaded, path)
+	}
+
+	if cfg.Database.Path != "env.db" {
+		t.Errorf("database.path = %q, want the environment's env.db", cfg.Database.Path)
+	}
+	if cfg.Database.VectorFormat != "int8" || cfg.Embedder.BatchSize != 8 {
+		t.Errorf("file values not applied: %+v", cfg)
+	}
+	if cfg.Embedder.Timeout != 45*time.Second {
+		t.Errorf("embedder.timeout = %s, want 45s", cfg.Embedder.Timeout)
+	}
+	if got := strings.Join(cfg.Server.CORSOrigins, " "); got != "chrome-extension://a http://localhost:3000" {
+		t.Errorf("server.cors_origins = %q", got)
+	}
+	if cfg.Embedder.MaxRetries != Default().Embedder.MaxRetries {
+		t.Errorf("unset embedder.max_retries = %d, want the default", cfg.Embedder.MaxRetries)
+	}
+}
+
+func TestLoadEnvNames(t *testing.T) {
+	path := writeConfig(t, "")
+	t.Setenv("SYNAPSE_CONFIG", path)
+	t.Setenv("SYNAPSE_DATABASE_VECTOR_FORMAT", "binary")
+	t.Setenv("SYNAPSE_SERVER_AUTH", "false")
+	t.Setenv("SYNAPSE_DEDUPE_THRESHOLD", "0.2")
+	t.Setenv("SYNAPSE_CHAT_CONTEXT_NOTES", "8")
+
+	cfg, _, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.VectorFormat != "binary" || cfg.Server.Auth || cfg.Dedupe.Threshold != 0.2 || cfg.Chat.ContextNotes != 8 {
+		t.Errorf("environment not applied: %+v", cfg)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		want string
+	}{
+		{"unknown key", "embedder:\n  batch: 3\n", nil, "field batch not found"},
+		{"bad integer", "", map[string]string{"SYNAPSE_EMBEDDER_BATCH_SIZE": "many"}, "SYNAPSE_EMBEDDER_BATCH_SIZE"},
+		{"bad duration", "", map[string]string{"SYNAPSE_CHAT_TIMEOUT": "soon"}, "SYNAPSE_CHAT_TIMEOUT"},
+		{"bad bool", "", map[string]string{"SYNAPSE_SERVER_AUTH": "maybe"}, "SYNAPSE_SERVER_AUTH"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.yaml)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			_, _, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load of a missing explicit config file succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		want string
+	}{
+		{"zero batch size", "embedder:\n  batch_size: 0\n", nil, "embedder.batch_size"},
+		{"no batches in flight", "embedder:\n  max_in_flight: 0\n", nil, "embedder.max_in_flight"},
+		{"negative retries", "embedder:\n  max_retries: -1\n", nil, "embedder.max_retries"},
+		{"negative dedupe threshold", "dedupe:\n  threshold: -0.1\n", nil, "dedupe.threshold"},
+		{"zero context notes", "chat:\n  context_notes: 0\n", nil, "chat.context_notes"},
+		{"negative timeout", "chat:\n  timeout: -1s\n", nil, "chat.timeout"},
+		{"temperature too high", "chat:\n  temperature: 3\n", nil, "chat.temperature"},
+		{"empty database path", "database:\n  path: \"\"\n", nil, "database.path"},
+		{"from the environment", "", map[string]string{"SYNAPSE_EMBEDDER_CACHE_SIZE": "-5"}, "embedder.cache_size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.yaml)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, _, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate = %v, want an error about %s", err, tt.want)
+			}
+		})
+	}
+
+	// Zero disables retries, the breaker and the cache.
+	cfg := Default()
+	cfg.Embedder.MaxRetries, cfg.Embedder.BreakerThreshold, cfg.Embedder.CacheSize = 0, 0, 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate with features disabled = %v", err)
+	}
+}
@@ -0,0 +1,371 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DEFAULT_CACHE_SIZE is the number of embeddings kept by the embedding cache
+// before the least recently used ones are evicted.
+const DEFAULT_CACHE_SIZE = 10000
+
+// EmbeddingCache persists embeddings keyed by model and ContentHash of the
+// embedded text. It satisfies client.EmbeddingCache. Once it holds more than
+// MaxEntries embeddings the least recently used are evicted; a MaxEntries of
+// zero or less never evicts.
+//
+// Get only reads the database. When entries were last used and the hit and
+// miss counts are kept in memory and written by Flush, which Put and Stats
+// call first so eviction and statistics see them. Call Flush before closing
+// the database to keep the rest.
+type EmbeddingCache struct {
+	manager    *SQLiteManager
+	MaxEntries int
+
+	mu    sync.Mutex
+	usage map[string]*cacheUsage
+}
+
+// cacheUsage is the use of one model's cache entries not yet written to the
+// database. lastUsed maps text hashes to Unix milliseconds.
+type cacheUsage struct {
+	lastUsed map[string]int64
+	hits     int
+	misses   int
+}
+
+// CacheStats summarises the embedding cache for one model.
+type CacheStats struct {
+	Model   string
+	Entries int
+	Bytes   int
+	Hits    int
+	Misses  int
+}
+
+// EmbeddingCache returns the embedding cache stored in this database.
+func (manager *SQLiteManager) EmbeddingCache(maxEntries int) *EmbeddingCache {
+	return &EmbeddingCache{manager: manager, MaxEntries: maxEntries}
+}
+
+// Get returns the cached embedding for each of texts, nil where there is none,
+// and records the hits and misses.
+func (cache *EmbeddingCache) Get(model string, texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, len(texts))
+	if len(texts) == 0 {
+		return embeddings, nil
+	}
+
+	hashes := make([]string, len(texts))
+	args := make([]any, 0, len(texts)+1)
+	args = append(args, model)
+	for i, text := range texts {
+		hashes[i] = ContentHash(text)
+		args = append(args, hashes[i])
+	}
+
+	query := `
+	SELECT text_hash, embedding_vector FROM embedding_cache
+	WHERE model = ? AND text_hash IN (` + strings.Repeat("?, ", len(texts)-1) + `?)`
+	rows, err := cache.manager.DB.Query(query, args...)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for cached embeddings", "error", err)
+		return nil, err
+	}
+
+	cached := make(map[string][]float64, len(texts))
+	for rows.Next() {
+		var hash string
+		var vector []byte
+		if err := rows.Scan(&hash, &vector); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if cached[hash], err = DecodeVector(vector); err != nil {
+			rows.Close()
+			return nil, err
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hits := 0
+	for i, hash := range hashes {
+		if embedding, ok := cached[hash]; ok {
+			embeddings[i] = embedding
+			hits++
+		}
+	}
+
+	cache.recordUse(model, cached, hits, len(texts)-hits)
+	logger.Debug("Database: Looked up cached embeddings", "model", model, "hits", hits, "misses", len(texts)-hits)
+	return embeddings, nil
+}
+
+// recordUse notes in memory that the entries in cached were used now, and
+// adds to the model's hit and miss counters.
+func (cache *EmbeddingCache) recordUse(model string, cached map[string][]float64, hits int, misses int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.usage == nil {
+		cache.usage = make(map[string]*cacheUsage)
+	}
+	usage := cache.usage[model]
+	if usage == nil {
+		usage = &cacheUsage{lastUsed: make(map[string]int64)}
+		cache.usage[model] = usage
+	}
+
+	now := time.Now().UnixMilli()
+	for hash := range cached {
+		usage.lastUsed[hash] = now
+	}
+	usage.hits += hits
+	usage.misses += misses
+}
+
+// takeUsage returns the usage recorded since the last call and forgets it.
+func (cache *EmbeddingCache) takeUsage() map[string]*cacheUsage {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	usage := cache.usage
+	cache.usage = nil
+	return usage
+}
+
+// restoreUsage puts back usage that could not be written, merging it with
+// anything recorded in the meantime.
+func (cache *EmbeddingCache) restoreUsage(usage map[string]*cacheUsage) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.usage == nil {
+		cache.usage = usage
+		return
+	}
+	for model, pending := range usage {
+		if current := cache.usage[model]; current != nil {
+			for hash, lastUsed := range current.lastUsed {
+				pending.lastUsed[hash] = max(pending.lastUsed[hash], lastUsed)
+			}
+			pending.hits += current.hits
+			pending.misses += current.misses
+		}
+		cache.usage[model] = pending
+	}
+}
+
+// Flush writes the recorded use of cache entries and the hit and miss counts
+// to the database.
+func (cache *EmbeddingCache) Flush() error {
+	usage := cache.takeUsage()
+	if len(usage) == 0 {
+		return nil
+	}
+
+	tx, err := cache.manager.DB.Begin()
+	if err != nil {
+		logger.Error("Database: Failed to begin transaction for cache statistics", "error", err)
+		cache.restoreUsage(usage)
+		return fmt.Errorf("failed to begin transaction for cache statistics: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := writeUsage(tx, usage); err != nil {
+		cache.restoreUsage(usage)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Database: Failed to commit cache statistics", "error", err)
+		cache.restoreUsage(usage)
+		return fmt.Errorf("failed to commit cache statistics: %w", err)
+	}
+	return nil
+}
+
+func writeUsage(tx *sql.Tx, usage map[string]*cacheUsage) error {
+	for model, pending := range usage {
+		for hash, lastUsed := range pending.lastUsed {
+			_, err := tx.Exec(`UPDATE embedding_cache SET last_used = max(last_used, ?) WHERE model = ? AND text_hash = ?`,
+				lastUsed, model, hash)
+			if err != nil {
+				logger.Error("Database: Failed to update cache entry", "error", err)
+				return fmt.Errorf("failed to update cache entry: %w", err)
+			}
+		}
+
+		_, err := tx.Exec(`
+		INSERT INTO embedding_cache_stats (model, hits, misses) VALUES (?, ?, ?)
+		ON CONFLICT (model) DO UPDATE SET hits = hits + excluded.hits, misses = misses + excluded.misses`,
+			model, pending.hits, pending.misses)
+		if err != nil {
+			logger.Error("Database: Failed to update cache statistics", "error", err)
+			return fmt.Errorf("failed to update cache statistics: %w", err)
+		}
+	}
+	return nil
+}
+
+// Put stores embeddings[i] as the embedding of texts[i] and evicts the least
+// recently used entries beyond MaxEntries. Embeddings are kept as float32 so
+// cached queries lose no accuracy to the notes' storage format. Recorded use
+// is written in the same transaction, before eviction picks its victims.
+func (cache *EmbeddingCache) Put(model string, texts []string, embeddings [][]float64) (err error) {
+	usage := cache.takeUsage()
+	defer func() {
+		if err != nil {
+			cache.restoreUsage(usage)
+		}
+	}()
+
+	tx, err := cache.manager.DB.Begin()
+	if err != nil {
+		logger.Error("Database: Failed to begin transaction for cache insertion", "error", err)
+		return fmt.Errorf("failed to begin transaction for cache insertion: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := writeUsage(tx, usage); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`
+	INSERT INTO embedding_cache (model, text_hash, embedding_vector, last_used) VALUES (?, ?, ?, ?)
+	ON CONFLICT (model, text_hash) DO UPDATE SET
+	    embedding_vector = excluded.embedding_vector,
+	    last_used = excluded.last_used`)
+	if err != nil {
+		logger.Error("Database: Failed to prepare cache insertion", "error", err)
+		return fmt.Errorf("failed to prepare cache insertion: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UnixMilli()
+	for i, text := range texts {
+		vector, err := EncodeVector(embeddings[i], VectorFloat32)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(model, ContentHash(text), vector, now); err != nil {
+			logger.Error("Database: Failed to insert cache entry", "error", err)
+			return fmt.Errorf("failed to insert cache entry: %w", err)
+		}
+	}
+
+	if err := cache.evict(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Database: Failed to commit cache insertion", "error", err)
+		return fmt.Errorf("failed to commit cache insertion: %w", err)
+	}
+	return nil
+}
+
+func (cache *EmbeddingCache) evict(tx *sql.Tx) error {
+	if cache.MaxEntries <= 0 {
+		return nil
+	}
+
+	result, err := tx.Exec(`
+	DELETE FROM embedding_cache WHERE rowid IN (
+	    SELECT rowid FROM embedding_cache
+	    ORDER BY last_used ASC
+	    LIMIT max(0, (SELECT COUNT(*) FROM embedding_cache) - ?)
+	)`, cache.MaxEntries)
+	if err != nil {
+		logger.Error("Database: Failed to evict cache entries", "error", err)
+		return fmt.Errorf("failed to evict cache entries: %w", err)
+	}
+	if evicted, err := result.RowsAffected(); err == nil && evicted > 0 {
+		logger.Debug("Database: Evicted least recently used cache entries", "count", evicted)
+	}
+	return nil
+}
+
+// Stats returns the size and hit rate of the cache per model, in model order.
+func (cache *EmbeddingCache) Stats() ([]CacheStats, error) {
+	if err := cache.Flush(); err != nil {
+		return nil, err
+	}
+
+	rows, err := cache.manager.DB.Query(`
+	SELECT model, SUM(entries), SUM(bytes), SUM(hits), SUM(misses)
+	FROM (
+	    SELECT model, COUNT(*) AS entries, SUM(length(embedding_vector)) AS bytes, 0 AS hits, 0 AS misses
+	    FROM embedding_cache GROUP BY model
+	    UNION ALL
+	    SELECT model, 0, 0, hits, misses FROM embedding_cache_stats
+	)
+	GROUP BY model
+	ORDER BY model`)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for cache statistics", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make([]CacheStats, 0)
+	for rows.Next() {
+		var s CacheStats
+		if err := rows.Scan(&s.Model, &s.Entries, &s.Bytes, &s.Hits, &s.Misses); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// Clear removes the cached embeddings and statistics of model, or of every
+// model if model is empty, and returns the number of embeddings removed.
+func (cache *EmbeddingCache) Clear(model string) (int, error) {
+	cache.mu.Lock()
+	if model == "" {
+		cache.usage = nil
+	} else {
+		delete(cache.usage, model)
+	}
+	cache.mu.Unlock()
+
+	tx, err := cache.manager.DB.Begin()
+	if err != nil {
+		logger.Error("Database: Failed to begin transaction for cache clearing", "error", err)
+		return 0, fmt.Errorf("failed to begin transaction for cache clearing: %w", err)
+	}
+	defer tx.Rollback()
+
+	condition, args := "", []any{}
+	if model != "" {
+		condition, args = ` WHERE model = ?`, []any{model}
+	}
+
+	result, err := tx.Exec(`DELETE FROM embedding_cache`+condition, args...)
+	if err != nil {
+		logger.Error("Database: Failed to clear embedding cache", "error", err)
+		return 0, fmt.Errorf("failed to clear embedding cache: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM embedding_cache_stats`+condition, args...); err != nil {
+		logger.Error("Database: Failed to clear cache statistics", "error", err)
+		return 0, fmt.Errorf("failed to clear cache statistics: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Database: Failed to commit cache clearing", "error", err)
+		return 0, fmt.Errorf("failed to commit cache clearing: %w", err)
+	}
+
+	cleared, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	logger.Debug("Database: Cleared embedding cache", "model", model, "count", cleared)
+	return int(cleared), nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func cacheLastUsed(t *testing.T, manager *SQLiteManager, text string) int64 {
+	t.Helper()
+	var lastUsed int64
+	err := manager.DB.QueryRow(`SELECT last_used FROM embedding_cache WHERE text_hash = ?`, ContentHash(text)).Scan(&lastUsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lastUsed
+}
+
+func TestEmbeddingCacheGetDoesNotWrite(t *testing.T) {
+	manager := newTestManager(t)
+	cache := manager.EmbeddingCache(0)
+
+	if err := cache.Put("m", []string{"a", "b"}, [][]float64{{1, 0}, {0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	before := cacheLastUsed(t, manager, "a")
+	time.Sleep(2 * time.Millisecond)
+
+	embeddings, err := cache.Get("m", []string{"a", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if embeddings[0] == nil || embeddings[1] != nil {
+		t.Fatalf("Get = %v, want a hit then a miss", embeddings)
+	}
+
+	if after := cacheLastUsed(t, manager, "a"); after != before {
+		t.Error("Get wrote last_used")
+	}
+	var statsRows int
+	manager.DB.QueryRow(`SELECT COUNT(*) FROM embedding_cache_stats`).Scan(&statsRows)
+	if statsRows != 0 {
+		t.Error("Get wrote the hit and miss counters")
+	}
+
+	if err := cache.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if after := cacheLastUsed(t, manager, "a"); after <= before {
+		t.Errorf("Flush did not write last_used: %d, was %d", after, before)
+	}
+	stats, err := cache.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Hits != 1 || stats[0].Misses != 1 || stats[0].Entries != 2 {
+		t.Errorf("Stats = %+v, want 2 entries, 1 hit and 1 miss", stats)
+	}
+
+	// Flushing again adds nothing.
+	if stats, _ := cache.Stats(); stats[0].Hits != 1 || stats[0].Misses != 1 {
+		t.Errorf("Stats after a second flush = %+v", stats)
+	}
+}
+
+func TestEmbeddingCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	manager := newTestManager(t)
+	cache := manager.EmbeddingCache(2)
+
+	if err := cache.Put("m", []string{"old"}, [][]float64{{1}}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if err := cache.Put("m", []string{"newer"}, [][]float64{{2}}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	// A hit recorded only in memory must still protect "old" from eviction.
+	if _, err := cache.Get("m", []string{"old"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Put("m", []string{"newest"}, [][]float64{{3}}); err != nil {
+		t.Fatal(err)
+	}
+
+	embeddings, err := cache.Get("m", []string{"old", "newer", "newest"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if embeddings[0] == nil || embeddings[1] != nil || embeddings[2] == nil {
+		t.Errorf("cached after eviction: old %v, newer %v, newest %v; want newer evicted",
+			embeddings[0], embeddings[1], embeddings[2])
+	}
+}
+
+func TestEmbeddingCacheClearDropsPendingStats(t *testing.T) {
+	manager := newTestManager(t)
+	cache := manager.EmbeddingCache(0)
+
+	cache.Put("m", []string{"a"}, [][]float64{{1}})
+	cache.Get("m", []string{"a", "b"})
+	if _, err := cache.Clear("m"); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := cache.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 0 {
+		t.Errorf("Stats after Clear = %+v, want none", stats)
+	}
+}
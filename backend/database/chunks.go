@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// NoteChunk is a passage of a note with its own embedding. Every note has at
+// least one chunk; semantic search ranks chunks and reports their parent note.
+type NoteChunk struct {
+	Id              int
+	NoteId          int
+	ChunkIndex      int
+	Content         string
+	EmbeddingVector []byte
+}
+
+func insertChunks(tx *sql.Tx, noteId int, chunks []NoteChunk) error {
+	stmt, err := tx.Prepare(`INSERT INTO note_chunks (note_id, chunk_index, content, embedding_vector) VALUES (?, ?, ?, ?);`)
+	if err != nil {
+		logger.Error("Database: Failed to prepare chunk insertion", "error", err)
+		return fmt.Errorf("failed to prepare chunk insertion: %w", err)
+	}
+	defer stmt.Close()
+
+	for i := range chunks {
+		chunk := &chunks[i]
+		result, err := stmt.Exec(noteId, chunk.ChunkIndex, chunk.Content, chunk.EmbeddingVector)
+		if err != nil {
+			logger.Error("Database: Failed to insert note chunk", "note_id", noteId, "chunk_index", chunk.ChunkIndex, "error", err)
+			return fmt.Errorf("failed to insert note chunk: %w", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to read inserted chunk id: %w", err)
+		}
+		chunk.Id = int(id)
+		chunk.NoteId = noteId
+	}
+	return nil
+}
+
+// GetChunkIdsForNote returns the ids of every chunk belonging to a note.
+func (manager *SQLiteManager) GetChunkIdsForNote(noteId int) ([]int, error) {
+	rows, err := manager.DB.Query(`SELECT id FROM note_chunks WHERE note_id = ? ORDER BY chunk_index`, noteId)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for chunk ids", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetChunksByIds returns chunks without their embeddings, keyed by chunk id.
+func (manager *SQLiteManager) GetChunksByIds(ids []int) (map[int]NoteChunk, error) {
+	chunks := make(map[int]NoteChunk, len(ids))
+	if len(ids) == 0 {
+		return chunks, nil
+	}
+
+	placeholders := strings.Repeat("?, ", len(ids)-1) + "?"
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	query := `SELECT id, note_id, chunk_index, content FROM note_chunks WHERE id IN (` + placeholders + `)`
+	rows, err := manager.DB.Query(query, args...)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for chunks by ids", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chunk NoteChunk
+		if err := rows.Scan(&chunk.Id, &chunk.NoteId, &chunk.ChunkIndex, &chunk.Content); err != nil {
+			logger.Error("Database: Failed to scan chunk row", "error", err)
+			return nil, err
+		}
+		chunks[chunk.Id] = chunk
+	}
+	return chunks, rows.Err()
+}
+
+// ForEachChunkEmbedding streams the id and raw embedding of every chunk whose
+// note was embedded with model to fn without materialising the whole table.
+// fn must not query the database itself, as the single connection is held
+// until iteration finishes.
+func (manager *SQLiteManager) ForEachChunkEmbedding(model string, fn func(id int, embedding []byte) error) error {
+	rows, err := manager.DB.Query(`
+	SELECT note_chunks.id, note_chunks.embedding_vector
+	FROM note_chunks JOIN notes ON notes.id = note_chunks.note_id
+	WHERE notes.embedding_model = ?`, model)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for chunk embeddings", "error", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var embedding []byte
+		if err := rows.Scan(&id, &embedding); err != nil {
+			logger.Error("Database: Failed to scan embedding row", "error", err)
+			return err
+		}
+		if err := fn(id, embedding); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ChunkStats returns the number of chunks embedded with model and the highest
+// of their ids, which together change whenever such chunks are added or
+// removed.
+func (manager *SQLiteManager) ChunkStats(model string) (count int, maxID int, err error) {
+	err = manager.DB.QueryRow(`
+	SELECT COUNT(*), COALESCE(MAX(note_chunks.id), 0)
+	FROM note_chunks JOIN notes ON notes.id = note_chunks.note_id
+	WHERE notes.embedding_model = ?`, model).Scan(&count, &maxID)
+	if err != nil {
+		logger.Error("Database: Failed to read chunk stats", "error", err)
+	}
+	return count, maxID, err
+}
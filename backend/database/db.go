@@ -2,31 +2,91 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type SQLiteManager struct {
-	DB *sql.DB
-	saveNoteStmt *sql.Stmt
+	DB             *sql.DB
+	saveNoteStmt   *sql.Stmt
 	deleteNoteStmt *sql.Stmt
 }
 
 type Note struct {
 	Id              int
 	Content         string
+	SourceURL       string
+	Title           string
+	SiteName        string
+	Author          string
+	Metadata        string
 	EmbeddingVector []byte
+	EmbeddingModel  string
+	EmbeddingDim    int
+	EmbeddingStatus string
 	CreatedAt       time.Time
+	UpdatedAt       time.Time
 	Distance        float64
+	Score           float64
+	Snippet         string
+	Tags            []string
+}
+
+// noteColumns is the column list scanned by scanNote, in order.
+// Columns are qualified so the list can be used in joins with note_chunks.
+const noteColumns = `notes.id, notes.content, notes.source_url, notes.title, notes.site_name,
+	notes.author, notes.metadata, notes.embedding_vector, notes.embedding_model, notes.embedding_dim,
+	notes.embedding_status, notes.created_at, notes.updated_at`
+
+// noteSummaryColumns matches noteColumns but skips loading the embedding,
+// which listings and searches never need.
+const noteSummaryColumns = `notes.id, notes.content, notes.source_url, notes.title, notes.site_name,
+	notes.author, notes.metadata, NULL, notes.embedding_model, notes.embedding_dim,
+	notes.embedding_status, notes.created_at, notes.updated_at`
+
+// Embedding statuses of a note. Pending notes have no embedding or chunks
+// yet, so only keyword search and listings can find them.
+const (
+	EMBEDDING_READY   = "ready"
+	EMBEDDING_PENDING = "pending"
+)
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanNote(row rowScanner, extra ...any) (Note, error) {
+	var note Note
+	dest := []any{
+		&note.Id,
+		&note.Content,
+		&note.SourceURL,
+		&note.Title,
+		&note.SiteName,
+		&note.Author,
+		&note.Metadata,
+		&note.EmbeddingVector,
+		&note.EmbeddingModel,
+		&note.EmbeddingDim,
+		&note.EmbeddingStatus,
+		&note.CreatedAt,
+		&note.UpdatedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	return note, err
 }
 
 var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
-func Initialize(filepath string) (*SQLiteManager, error) {
+// Open connects to the database without touching its schema. Most callers
+// want Initialize instead.
+func Open(filepath string) (*SQLiteManager, error) {
 
 	db, err := sql.Open("sqlite_extended", filepath)
 
@@ -44,40 +104,38 @@ func Initialize(filepath string) (*SQLiteManager, error) {
 	logger.Debug("Database: Connection established successfully", "filepath", filepath)
 	db.SetMaxOpenConns(1)
 
-	manager := &SQLiteManager{DB: db}
-	if err := manager.prepareStatements(); err != nil {
-		db.Close()
-		return nil, err
-	}
-
-	return manager, nil
+	return &SQLiteManager{DB: db}, nil
 }
 
-func (manager *SQLiteManager) SetupSchema() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS notes (
-	    id INTEGER PRIMARY KEY AUTOINCREMENT,
-	    content TEXT NOT NULL,
-	    embedding_vector BLOB NOT NULL,
-	    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-
-	logger.Debug("Database: Setting up table schema...")
-	_, err := manager.DB.Exec(schema)
+// Initialize opens the database, applies any pending schema migrations and
+// prepares the cached statements.
+func Initialize(filepath string) (*SQLiteManager, error) {
+	manager, err := Open(filepath)
+	if err != nil {
+		return nil, err
+	}
 
+	logger.Debug("Database: Applying pending migrations...")
+	applied, err := manager.MigrateUp()
 	if err != nil {
-		logger.Error("Database: Failed to execute schema creation", "error", err)
-		return err
+		manager.DB.Close()
+		return nil, err
 	}
+	logger.Debug("Database: Schema up to date.", "applied", applied)
 
-	logger.Debug("Database: Schema created/verified successfully.")
+	if err := manager.prepareStatements(); err != nil {
+		manager.DB.Close()
+		return nil, err
+	}
 
-	return nil
+	return manager, nil
 }
 
 func (manager *SQLiteManager) prepareStatements() error {
-	saveNoteQuery := `INSERT INTO notes (content, embedding_vector) VALUES(?, ?);`
+	saveNoteQuery := `
+	INSERT INTO notes (content, source_url, title, site_name, author, metadata, embedding_vector,
+	    embedding_model, embedding_dim, embedding_status, content_hash, updated_at)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP);`
 	stmt, err := manager.DB.Prepare(saveNoteQuery)
 	if err != nil {
 		logger.Error("Database: Failed to prepare save note statement", "error", err)
@@ -98,15 +156,177 @@ func (manager *SQLiteManager) prepareStatements() error {
 	return nil
 }
 
-func (manager *SQLiteManager) SaveNote(note Note) error {
-	_, err := manager.saveNoteStmt.Exec(note.Content, note.EmbeddingVector)
+// SaveNote inserts a note and its chunks in one transaction and returns the
+// new note ID. The Id and NoteId fields of each element of chunks are filled
+// in on success.
+func (manager *SQLiteManager) SaveNote(note Note, chunks []NoteChunk) (int, error) {
+	ids, err := manager.SaveNotes([]Note{note}, [][]NoteChunk{chunks})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// SaveNotes inserts several notes, chunks[i] belonging to notes[i], in a
+// single transaction and returns their IDs in order. Either every note is
+// saved or none is.
+func (manager *SQLiteManager) SaveNotes(notes []Note, chunks [][]NoteChunk) ([]int, error) {
+	tx, err := manager.DB.Begin()
+	if err != nil {
+		logger.Error("Database: Failed to begin transaction for note insertion", "error", err)
+		return nil, fmt.Errorf("failed to begin transaction for note insertion: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt := tx.Stmt(manager.saveNoteStmt)
+	ids := make([]int, len(notes))
+	for i, note := range notes {
+		if ids[i], err = saveNote(tx, stmt, note, chunks[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Database: Failed to commit note insertion", "error", err)
+		return nil, fmt.Errorf("failed to commit note insertion: %w", err)
+	}
+
+	logger.Debug("Database: Successfully saved new notes", "count", len(notes))
+	return ids, nil
+}
+
+// SaveNoteUnlessDuplicate is SaveNote for a note whose content must not
+// already be stored. If a note with the same content hash exists, nothing is
+// saved and that note's ID is returned as existingId. The lookup and the
+// insert share a transaction, so concurrent saves of the same content store
+// it once.
+func (manager *SQLiteManager) SaveNoteUnlessDuplicate(note Note, chunks []NoteChunk) (id int, existingId int, err error) {
+	tx, err := manager.DB.Begin()
+	if err != nil {
+		logger.Error("Database: Failed to begin transaction for note insertion", "error", err)
+		return 0, 0, fmt.Errorf("failed to begin transaction for note insertion: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`SELECT id FROM notes WHERE content_hash = ? ORDER BY id LIMIT 1`, ContentHash(note.Content)).Scan(&existingId)
+	if err == nil {
+		logger.Debug("Database: Note duplicates a stored note, not saving it", "existing_id", existingId)
+		return 0, existingId, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		logger.Error("Database: Failed to look up note by content hash", "error", err)
+		return 0, 0, err
+	}
+
+	if id, err = saveNote(tx, tx.Stmt(manager.saveNoteStmt), note, chunks); err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Database: Failed to commit note insertion", "error", err)
+		return 0, 0, fmt.Errorf("failed to commit note insertion: %w", err)
+	}
+
+	logger.Debug("Database: Successfully saved new note", "id", id)
+	return id, 0, nil
+}
+
+func saveNote(tx *sql.Tx, stmt *sql.Stmt, note Note, chunks []NoteChunk) (int, error) {
+	if note.Metadata == "" {
+		note.Metadata = "{}"
+	}
+	if note.EmbeddingStatus == "" {
+		note.EmbeddingStatus = EMBEDDING_READY
+	}
+	if note.EmbeddingVector == nil {
+		// A nil slice binds as NULL, which the column rejects.
+		note.EmbeddingVector = []byte{}
+	}
+
+	result, err := stmt.Exec(
+		note.Content,
+		note.SourceURL,
+		note.Title,
+		note.SiteName,
+		note.Author,
+		note.Metadata,
+		note.EmbeddingVector,
+		note.EmbeddingModel,
+		note.EmbeddingDim,
+		note.EmbeddingStatus,
+		ContentHash(note.Content),
+	)
 	if err != nil {
 		logger.Error("Database: Failed to EXECUTE statement for note insertion", "error", err)
-		return fmt.Errorf("failed to execute statement for note insertion: %w", err)
+		return 0, fmt.Errorf("failed to execute statement for note insertion: %w", err)
 	}
 
-	logger.Debug("Database: Successfully saved a new note", "content_length", len(note.Content))
+	id, err := result.LastInsertId()
+	if err != nil {
+		logger.Error("Database: Failed to read inserted note id", "error", err)
+		return 0, fmt.Errorf("failed to read inserted note id: %w", err)
+	}
+
+	if err := insertChunks(tx, int(id), chunks); err != nil {
+		return 0, err
+	}
+	if err := addTags(tx, int(id), note.Tags); err != nil {
+		return 0, err
+	}
+
+	logger.Debug("Database: Successfully saved a new note", "id", id, "content_length", len(note.Content), "chunks", len(chunks))
+
+	return int(id), nil
+}
+
+// UpdateNote overwrites the stored fields of note.Id and bumps updated_at.
+// When chunks is non-nil the note's embedding and chunks are replaced too;
+// otherwise they are left untouched. The Id and NoteId fields of each element
+// of chunks are filled in on success.
+func (manager *SQLiteManager) UpdateNote(note Note, chunks []NoteChunk) error {
+	if note.Metadata == "" {
+		note.Metadata = "{}"
+	}
 
+	tx, err := manager.DB.Begin()
+	if err != nil {
+		logger.Error("Database: Failed to begin transaction for note update", "error", err)
+		return fmt.Errorf("failed to begin transaction for note update: %w", err)
+	}
+	defer tx.Rollback()
+
+	updateNoteQuery := `
+	UPDATE notes
+	SET content = ?, content_hash = ?, source_url = ?, title = ?, site_name = ?, author = ?, metadata = ?,
+	    updated_at = CURRENT_TIMESTAMP
+	WHERE id = ?;`
+
+	_, err = tx.Exec(updateNoteQuery,
+		note.Content,
+		ContentHash(note.Content),
+		note.SourceURL,
+		note.Title,
+		note.SiteName,
+		note.Author,
+		note.Metadata,
+		note.Id,
+	)
+	if err != nil {
+		logger.Error("Database: Failed to execute note update", "id", note.Id, "error", err)
+		return fmt.Errorf("failed to execute note update: %w", err)
+	}
+
+	if chunks != nil {
+		if _, err := replaceEmbedding(tx, note, chunks); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Database: Failed to commit note update", "error", err)
+		return fmt.Errorf("failed to commit note update: %w", err)
+	}
+
+	logger.Debug("Database: Successfully updated note", "id", note.Id, "reembedded", chunks != nil)
 	return nil
 }
 
@@ -123,7 +343,7 @@ func (manager *SQLiteManager) DeleteNote(id int) error {
 }
 
 func (manager *SQLiteManager) GetNoteById(id int) (*Note, error) {
-	getNoteByIdQuery := `SELECT id, content, embedding_vector, created_at FROM notes WHERE id = ?`
+	getNoteByIdQuery := `SELECT ` + noteColumns + ` FROM notes WHERE id = ?`
 
 	rows, err := manager.DB.Query(getNoteByIdQuery, id)
 	if err != nil {
@@ -140,14 +360,7 @@ func (manager *SQLiteManager) GetNoteById(id int) (*Note, error) {
 		return nil, nil
 	}
 
-	var note Note
-
-	err = rows.Scan(
-		&note.Id,
-		&note.Content,
-		&note.EmbeddingVector,
-		&note.CreatedAt,
-	)
+	note, err := scanNote(rows)
 
 	if err != nil {
 		logger.Error("Database: Failed to scan row data into Note struct", "error", err)
@@ -157,14 +370,21 @@ func (manager *SQLiteManager) GetNoteById(id int) (*Note, error) {
 	if rows.Next() {
 		logger.Warn("Database: Query returned more than one note for a unique Id.", "id", note.Id)
 	}
+	rows.Close()
+
+	notes := []Note{note}
+	if err := manager.attachTags(notes); err != nil {
+		return nil, err
+	}
 
-	return &note, nil
+	return &notes[0], nil
 }
 
-func (manager *SQLiteManager) GetAllNotes() ([]Note, error) {
-	getAllNotesQuery := `SELECT id, content, embedding_vector, created_at FROM notes`
+func (manager *SQLiteManager) GetAllNotes(filter NoteFilter) ([]Note, error) {
+	filterClause, filterArgs := filter.whereClause()
+	getAllNotesQuery := `SELECT ` + noteSummaryColumns + ` FROM notes WHERE 1 = 1 ` + filterClause + ` ORDER BY notes.id`
 
-	rows, err := manager.DB.Query(getAllNotesQuery)
+	rows, err := manager.DB.Query(getAllNotesQuery, filterArgs...)
 	if err != nil {
 		logger.Error("Database: Failed to execute SELECT query for all notes", "error", err)
 		return nil, err
@@ -174,13 +394,7 @@ func (manager *SQLiteManager) GetAllNotes() ([]Note, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var note Note
-		err := rows.Scan(
-			&note.Id,
-			&note.Content,
-			&note.EmbeddingVector,
-			&note.CreatedAt,
-		)
+		note, err := scanNote(rows)
 
 		if err != nil {
 			logger.Error("Database: Failed to scan row data into Note struct", "error", err)
@@ -194,24 +408,102 @@ func (manager *SQLiteManager) GetAllNotes() ([]Note, error) {
 		return nil, err
 	}
 
+	if err := manager.attachTags(notes); err != nil {
+		return nil, err
+	}
+
 	logger.Debug("Database: Successfully retrieved all notes", "count", len(notes))
 	return notes, nil
 }
 
-func (manager *SQLiteManager) SearchNotes(queryVector []byte) ([]Note, error) {
+// ListNotes returns up to limit notes with an id greater than afterID, in id
+// order, so callers can page through the table with a keyset cursor.
+func (manager *SQLiteManager) ListNotes(filter NoteFilter, afterID int, limit int) ([]Note, error) {
+	return manager.listNotes(noteSummaryColumns, filter, afterID, limit)
+}
+
+// ListNotesWithEmbeddings is ListNotes with each note's embedding loaded.
+func (manager *SQLiteManager) ListNotesWithEmbeddings(filter NoteFilter, afterID int, limit int) ([]Note, error) {
+	return manager.listNotes(noteColumns, filter, afterID, limit)
+}
+
+func (manager *SQLiteManager) listNotes(columns string, filter NoteFilter, afterID int, limit int) ([]Note, error) {
+	filterClause, filterArgs := filter.whereClause()
+	listNotesQuery := `
+	SELECT ` + columns + `
+	FROM notes
+	WHERE notes.id > ? ` + filterClause + `
+	ORDER BY notes.id
+	LIMIT ?`
+
+	args := append(append([]any{afterID}, filterArgs...), limit)
+	rows, err := manager.DB.Query(listNotesQuery, args...)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for note page", "error", err)
+		return nil, err
+	}
+
+	notes := make([]Note, 0, limit)
+	defer rows.Close()
+
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			logger.Error("Database: Failed to scan row data into Note struct", "error", err)
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Database: Error occurred during row iteration", "error", err)
+		return nil, err
+	}
+
+	if err := manager.attachTags(notes); err != nil {
+		return nil, err
+	}
+
+	logger.Debug("Database: Successfully retrieved note page", "after_id", afterID, "count", len(notes))
+	return notes, nil
+}
+
+// SearchNotes ranks notes by their closest chunk to queryVector. The text of
+// that chunk is returned as the note's Snippet. Only notes embedded in space,
+// the vector space of queryVector, are compared.
+func (manager *SQLiteManager) SearchNotes(queryVector []byte, space VectorSpace, opts SearchOptions) ([]Note, error) {
+	filterClause, filterArgs := opts.whereClause()
+	filterArgs = append([]any{space.Model, space.Dim}, filterArgs...)
+	havingClause := ""
+	if opts.MaxDistance > 0 {
+		havingClause = `HAVING distance <= ?`
+		filterArgs = append(filterArgs, opts.MaxDistance)
+	}
+
+	// SQLite fills bare columns in an aggregate query from the row that
+	// produced MIN(), so note_chunks.content is the best matching passage.
 	searchNotesQuery := `
 	SELECT
-	    id, content, embedding_vector, created_at,
-	    vector_distance(embedding_vector, ?) AS distance
+	    ` + noteSummaryColumns + `,
+	    note_chunks.content,
+	    MIN(vector_distance(note_chunks.embedding_vector, ?)) AS distance
 	FROM
-	    notes
+	    note_chunks
+	JOIN
+	    notes ON notes.id = note_chunks.note_id
+	WHERE
+	    notes.embedding_model = ? AND notes.embedding_dim = ? ` + filterClause + `
+	GROUP BY
+	    note_chunks.note_id
+	` + havingClause + `
 	ORDER BY
 	    distance ASC
 	LIMIT
-	    10
+	    ? OFFSET ?
 	`
 
-	rows, err := manager.DB.Query(searchNotesQuery, queryVector)
+	args := append(append([]any{queryVector}, filterArgs...), opts.Limit, opts.Offset)
+	rows, err := manager.DB.Query(searchNotesQuery, args...)
 	if err != nil {
 		logger.Error("Database: Failed to execute SELECT query for search notes", "error", err)
 		return nil, err
@@ -221,15 +513,10 @@ func (manager *SQLiteManager) SearchNotes(queryVector []byte) ([]Note, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var note Note
+		var snippet string
 		var distance sql.NullFloat64
-		err := rows.Scan(
-			&note.Id,
-			&note.Content,
-			&note.EmbeddingVector,
-			&note.CreatedAt,
-			&distance,
-		)
+		note, err := scanNote(rows, &snippet, &distance)
+		note.Snippet = snippet
 
 		if distance.Valid {
 			note.Distance = distance.Float64
@@ -250,6 +537,136 @@ func (manager *SQLiteManager) SearchNotes(queryVector []byte) ([]Note, error) {
 		return nil, err
 	}
 
+	if err := manager.attachTags(notes); err != nil {
+		return nil, err
+	}
+
 	logger.Debug("Database: Successfully found notes", "count", len(notes))
 	return notes, nil
 }
+
+// KeywordSearchNotes runs a full-text query against note content and titles,
+// ordered by descending BM25 score. Every whitespace-separated word of query
+// is matched literally, so FTS syntax characters need no escaping.
+func (manager *SQLiteManager) KeywordSearchNotes(query string, opts SearchOptions) ([]Note, error) {
+	match := ftsMatchExpression(query)
+	if match == "" {
+		return []Note{}, nil
+	}
+	filterClause, filterArgs := opts.whereClause()
+
+	keywordSearchQuery := `
+	SELECT
+	    ` + noteSummaryColumns + `,
+	    matches.score
+	FROM
+	    notes
+	JOIN (
+	    SELECT docid, bm25(matchinfo(notes_fts, 'pcnalx')) AS score
+	    FROM notes_fts
+	    WHERE notes_fts MATCH ?
+	) AS matches ON notes.id = matches.docid
+	WHERE
+	    1 = 1 ` + filterClause + `
+	ORDER BY
+	    matches.score DESC
+	LIMIT
+	    ? OFFSET ?
+	`
+
+	args := append(append([]any{match}, filterArgs...), opts.Limit, opts.Offset)
+	rows, err := manager.DB.Query(keywordSearchQuery, args...)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for keyword search", "error", err)
+		return nil, err
+	}
+
+	notes := make([]Note, 0)
+	defer rows.Close()
+
+	for rows.Next() {
+		var score float64
+		note, err := scanNote(rows, &score)
+		if err != nil {
+			logger.Error("Database: Failed to scan row data into Note struct", "error", err)
+			return nil, err
+		}
+		note.Score = score
+		notes = append(notes, note)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Database: Error occurred during row iteration", "error", err)
+		return nil, err
+	}
+
+	if err := manager.attachTags(notes); err != nil {
+		return nil, err
+	}
+
+	logger.Debug("Database: Successfully found notes by keyword", "count", len(notes))
+	return notes, nil
+}
+
+// ftsMatchExpression quotes each word of query as an FTS phrase and ORs them
+// together, leaving BM25 to rank notes that match more words higher.
+func ftsMatchExpression(query string) string {
+	words := strings.Fields(query)
+	phrases := make([]string, 0, len(words))
+	for _, word := range words {
+		// The tokenizer discards quotes anyway, so dropping them is lossless.
+		word = strings.TrimSpace(strings.ReplaceAll(word, `"`, " "))
+		if word != "" {
+			phrases = append(phrases, `"`+word+`"`)
+		}
+	}
+	return strings.Join(phrases, " OR ")
+}
+
+// GetNotesByIds returns the notes with the given ids in the same order as ids.
+// Ids that no longer exist are skipped.
+func (manager *SQLiteManager) GetNotesByIds(ids []int) ([]Note, error) {
+	if len(ids) == 0 {
+		return []Note{}, nil
+	}
+
+	placeholders := strings.Repeat("?, ", len(ids)-1) + "?"
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	getNotesByIdsQuery := `SELECT ` + noteSummaryColumns + ` FROM notes WHERE id IN (` + placeholders + `)`
+
+	rows, err := manager.DB.Query(getNotesByIdsQuery, args...)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for notes by ids", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	byId := make(map[int]Note, len(ids))
+	for rows.Next() {
+		note, err := scanNote(rows)
+		if err != nil {
+			logger.Error("Database: Failed to scan row data into Note struct", "error", err)
+			return nil, err
+		}
+		byId[note.Id] = note
+	}
+	if err := rows.Err(); err != nil {
+		logger.Error("Database: Error occurred during row iteration", "error", err)
+		return nil, err
+	}
+
+	notes := make([]Note, 0, len(byId))
+	for _, id := range ids {
+		if note, ok := byId[id]; ok {
+			notes = append(notes, note)
+		}
+	}
+	if err := manager.attachTags(notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
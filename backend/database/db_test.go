@@ -0,0 +1,22 @@
+package database
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+var registerDriver sync.Once
+
+// newTestManager returns a migrated database in a temporary directory.
+func newTestManager(t *testing.T) *SQLiteManager {
+	t.Helper()
+	registerDriver.Do(RegisterCustomDriver)
+
+	manager, err := Initialize(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	t.Cleanup(func() { manager.DB.Close() })
+	return manager
+}
@@ -0,0 +1,285 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// VectorSpace identifies the model and dimension a vector was produced with.
+// Only vectors from the same space can be compared.
+type VectorSpace struct {
+	Model string
+	Dim   int
+}
+
+// replaceEmbedding swaps the embedding and chunks of note.Id for the ones in
+// note and chunks, recording the model and dimension they came from. The
+// note is no longer pending afterwards. Nothing is written, and false is
+// returned, unless the stored content still equals note.Content, so an
+// embedding computed from text that has since been edited or deleted is
+// never attached to the note.
+func replaceEmbedding(tx *sql.Tx, note Note, chunks []NoteChunk) (bool, error) {
+	result, err := tx.Exec(`
+	UPDATE notes SET embedding_vector = ?, embedding_model = ?, embedding_dim = ?, embedding_status = ?
+	WHERE id = ? AND content = ?`,
+		note.EmbeddingVector, note.EmbeddingModel, note.EmbeddingDim, EMBEDDING_READY, note.Id, note.Content)
+	if err != nil {
+		logger.Error("Database: Failed to update note embedding", "id", note.Id, "error", err)
+		return false, fmt.Errorf("failed to update note embedding: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if updated == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(`DELETE FROM note_chunks WHERE note_id = ?`, note.Id); err != nil {
+		logger.Error("Database: Failed to delete old note chunks", "id", note.Id, "error", err)
+		return false, fmt.Errorf("failed to delete old note chunks: %w", err)
+	}
+	return true, insertChunks(tx, note.Id, chunks)
+}
+
+// ReplaceEmbedding re-embeds a note in place without touching its content or
+// updated_at. It returns false without changing anything if the note was
+// edited or deleted since note was read. The Id and NoteId fields of each
+// element of chunks are filled in on success.
+func (manager *SQLiteManager) ReplaceEmbedding(note Note, chunks []NoteChunk) (bool, error) {
+	tx, err := manager.DB.Begin()
+	if err != nil {
+		logger.Error("Database: Failed to begin transaction for embedding replacement", "error", err)
+		return false, fmt.Errorf("failed to begin transaction for embedding replacement: %w", err)
+	}
+	defer tx.Rollback()
+
+	replaced, err := replaceEmbedding(tx, note, chunks)
+	if err != nil || !replaced {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Database: Failed to commit embedding replacement", "error", err)
+		return false, fmt.Errorf("failed to commit embedding replacement: %w", err)
+	}
+
+	logger.Debug("Database: Successfully replaced note embedding", "id", note.Id, "model", note.EmbeddingModel, "chunks", len(chunks))
+	return true, nil
+}
+
+// CountNotesNotEmbeddedWith returns how many notes have an embedding from a
+// model other than model. Pending notes are left to EmbedPending.
+func (manager *SQLiteManager) CountNotesNotEmbeddedWith(model string) (int, error) {
+	var count int
+	err := manager.DB.QueryRow(`SELECT COUNT(*) FROM notes WHERE embedding_model != ? AND embedding_status = ?`,
+		model, EMBEDDING_READY).Scan(&count)
+	if err != nil {
+		logger.Error("Database: Failed to count notes needing re-embedding", "error", err)
+	}
+	return count, err
+}
+
+// NoteIdsNotEmbeddedWith returns up to limit ids greater than afterID, in id
+// order, of notes with an embedding from a model other than model.
+func (manager *SQLiteManager) NoteIdsNotEmbeddedWith(model string, afterID int, limit int) ([]int, error) {
+	rows, err := manager.DB.Query(`
+	SELECT id FROM notes
+	WHERE embedding_model != ? AND embedding_status = ? AND id > ?
+	ORDER BY id
+	LIMIT ?`, model, EMBEDDING_READY, afterID, limit)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for notes needing re-embedding", "error", err)
+		return nil, err
+	}
+	return scanIds(rows, limit)
+}
+
+// CountPendingNotes returns how many notes are still waiting for an
+// embedding.
+func (manager *SQLiteManager) CountPendingNotes() (int, error) {
+	var count int
+	err := manager.DB.QueryRow(`SELECT COUNT(*) FROM notes WHERE embedding_status = ?`, EMBEDDING_PENDING).Scan(&count)
+	if err != nil {
+		logger.Error("Database: Failed to count pending notes", "error", err)
+	}
+	return count, err
+}
+
+// PendingNoteIds returns up to limit ids greater than afterID, in id order, of
+// notes still waiting for an embedding.
+func (manager *SQLiteManager) PendingNoteIds(afterID int, limit int) ([]int, error) {
+	rows, err := manager.DB.Query(`
+	SELECT id FROM notes
+	WHERE embedding_status = ? AND id > ?
+	ORDER BY id
+	LIMIT ?`, EMBEDDING_PENDING, afterID, limit)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for pending notes", "error", err)
+		return nil, err
+	}
+	return scanIds(rows, limit)
+}
+
+func scanIds(rows *sql.Rows, limit int) ([]int, error) {
+	defer rows.Close()
+
+	ids := make([]int, 0, limit)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+const vectorConversionBatchSize = 500
+
+// ConvertVectors re-encodes every note and chunk embedding in format and
+// returns how many were rewritten. Each batch is committed on its own, and
+// vectors already in format are skipped, so an interrupted conversion can
+// simply be run again. progress, if set, is called after every batch.
+func (manager *SQLiteManager) ConvertVectors(format VectorFormat, progress func(converted int)) (int, error) {
+	if _, ok := vectorFormatNames[format]; !ok {
+		return 0, fmt.Errorf("%w: unknown format %d", ErrInvalidVector, format)
+	}
+
+	converted := 0
+	for _, table := range []string{"notes", "note_chunks"} {
+		afterID := 0
+		for {
+			n, lastID, err := manager.convertVectorBatch(table, format, afterID)
+			if err != nil {
+				return converted, err
+			}
+			if lastID == 0 {
+				break
+			}
+			afterID = lastID
+			converted += n
+			if progress != nil {
+				progress(converted)
+			}
+		}
+	}
+
+	logger.Debug("Database: Converted vectors", "format", format.String(), "converted", converted)
+	return converted, nil
+}
+
+// convertVectorBatch converts the next batch of rows of table after afterID.
+// It returns the number of rows rewritten and the last id seen, which is zero
+// once the table is exhausted.
+func (manager *SQLiteManager) convertVectorBatch(table string, format VectorFormat, afterID int) (int, int, error) {
+	tx, err := manager.DB.Begin()
+	if err != nil {
+		logger.Error("Database: Failed to begin transaction for vector conversion", "error", err)
+		return 0, 0, fmt.Errorf("failed to begin transaction for vector conversion: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, embedding_vector FROM `+table+` WHERE id > ? ORDER BY id LIMIT ?`,
+		afterID, vectorConversionBatchSize)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for vector conversion", "table", table, "error", err)
+		return 0, 0, err
+	}
+
+	type row struct {
+		id     int
+		vector []byte
+	}
+	batch := make([]row, 0, vectorConversionBatchSize)
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.vector); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	if len(batch) == 0 {
+		return 0, 0, nil
+	}
+
+	stmt, err := tx.Prepare(`UPDATE ` + table + ` SET embedding_vector = ? WHERE id = ?`)
+	if err != nil {
+		logger.Error("Database: Failed to prepare vector conversion", "table", table, "error", err)
+		return 0, 0, fmt.Errorf("failed to prepare vector conversion: %w", err)
+	}
+	defer stmt.Close()
+
+	converted := 0
+	for _, r := range batch {
+		if len(r.vector) == 0 {
+			// Pending notes have no embedding yet.
+			continue
+		}
+		current, _, err := VectorFormatOf(r.vector)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s row %d: %w", table, r.id, err)
+		}
+		if current == format {
+			continue
+		}
+
+		vector, err := DecodeVector(r.vector)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%s row %d: %w", table, r.id, err)
+		}
+		encoded, err := EncodeVector(vector, format)
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, err := stmt.Exec(encoded, r.id); err != nil {
+			logger.Error("Database: Failed to rewrite vector", "table", table, "id", r.id, "error", err)
+			return 0, 0, fmt.Errorf("failed to rewrite vector: %w", err)
+		}
+		converted++
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Database: Failed to commit vector conversion", "error", err)
+		return 0, 0, fmt.Errorf("failed to commit vector conversion: %w", err)
+	}
+	return converted, batch[len(batch)-1].id, nil
+}
+
+// Vacuum rebuilds the database file to return space freed by deleted or
+// shrunk rows to the file system.
+func (manager *SQLiteManager) Vacuum() error {
+	if _, err := manager.DB.Exec(`VACUUM`); err != nil {
+		logger.Error("Database: Failed to vacuum", "error", err)
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
+
+// FindNearestNote returns the id of the note whose note-level embedding is
+// closest to queryVector, among notes embedded in space, and its distance.
+// The id is zero when there is no such note.
+func (manager *SQLiteManager) FindNearestNote(queryVector []byte, space VectorSpace) (int, float64, error) {
+	var id int
+	var distance float64
+	err := manager.DB.QueryRow(`
+	SELECT id, vector_distance(embedding_vector, ?) AS distance
+	FROM notes
+	WHERE embedding_model = ? AND embedding_dim = ? AND embedding_status = ?
+	ORDER BY distance ASC
+	LIMIT 1`, queryVector, space.Model, space.Dim, EMBEDDING_READY).Scan(&id, &distance)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		logger.Error("Database: Failed to look up nearest note", "error", err)
+		return 0, 0, err
+	}
+	return id, distance, nil
+}
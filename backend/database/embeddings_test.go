@@ -0,0 +1,126 @@
+package database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestReplaceEmbeddingSkipsChangedNotes(t *testing.T) {
+	manager := newTestManager(t)
+
+	vector, err := EncodeVector([]float64{1, 0}, VectorFloat32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := manager.SaveNote(Note{Content: "original", EmbeddingVector: vector, EmbeddingModel: "a", EmbeddingDim: 2},
+		[]NoteChunk{{Content: "original", EmbeddingVector: vector}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := manager.GetNoteById(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := *stored
+	stale.EmbeddingModel = "b"
+
+	tests := []struct {
+		name   string
+		change func() error
+	}{
+		{"edited", func() error {
+			edited := *stored
+			edited.Content = "edited"
+			return manager.UpdateNote(edited, []NoteChunk{{Content: "edited", EmbeddingVector: vector}})
+		}},
+		{"deleted", func() error { return manager.DeleteNote(id) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); err != nil {
+				t.Fatal(err)
+			}
+			chunksBefore, err := manager.GetChunkIdsForNote(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replaced, err := manager.ReplaceEmbedding(stale, []NoteChunk{{Content: "original", EmbeddingVector: vector}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if replaced {
+				t.Fatal("ReplaceEmbedding replaced the embedding of a changed note")
+			}
+
+			var model string
+			manager.DB.QueryRow(`SELECT embedding_model FROM notes WHERE id = ?`, id).Scan(&model)
+			if model == "b" {
+				t.Error("stale embedding model was written")
+			}
+			chunksAfter, err := manager.GetChunkIdsForNote(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(chunksBefore, chunksAfter) {
+				t.Errorf("chunks changed from %v to %v", chunksBefore, chunksAfter)
+			}
+		})
+	}
+}
+
+func TestReplaceEmbeddingUpdatesUnchangedNote(t *testing.T) {
+	manager := newTestManager(t)
+
+	id, err := manager.SaveNote(Note{Content: "pending", EmbeddingStatus: EMBEDDING_PENDING}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	note, err := manager.GetNoteById(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vector, _ := EncodeVector([]float64{0, 1}, VectorFloat32)
+	note.EmbeddingVector, note.EmbeddingModel, note.EmbeddingDim = vector, "m", 2
+
+	chunks := []NoteChunk{{Content: "pending", EmbeddingVector: vector}}
+	replaced, err := manager.ReplaceEmbedding(*note, chunks)
+	if err != nil || !replaced {
+		t.Fatalf("ReplaceEmbedding = %v, %v; want true, nil", replaced, err)
+	}
+	if chunks[0].Id == 0 || chunks[0].NoteId != id {
+		t.Errorf("chunk ids not filled in: %+v", chunks[0])
+	}
+
+	stored, _ := manager.GetNoteById(id)
+	if stored.EmbeddingStatus != EMBEDDING_READY || stored.EmbeddingModel != "m" {
+		t.Errorf("note not embedded: status %q, model %q", stored.EmbeddingStatus, stored.EmbeddingModel)
+	}
+}
+
+func TestNotesNotEmbeddedWithSkipsPendingNotes(t *testing.T) {
+	manager := newTestManager(t)
+
+	vector, _ := EncodeVector([]float64{1, 0}, VectorFloat32)
+	notes := []Note{
+		{Content: "current", EmbeddingVector: vector, EmbeddingModel: "new", EmbeddingDim: 2},
+		{Content: "stale", EmbeddingVector: vector, EmbeddingModel: "old", EmbeddingDim: 2},
+		{Content: "pending", EmbeddingStatus: EMBEDDING_PENDING},
+	}
+	var ids []int
+	for _, note := range notes {
+		id, err := manager.SaveNote(note, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	count, err := manager.CountNotesNotEmbeddedWith("new")
+	if err != nil || count != 1 {
+		t.Errorf("CountNotesNotEmbeddedWith = %d, %v; want 1", count, err)
+	}
+	got, err := manager.NoteIdsNotEmbeddedWith("new", 0, 10)
+	if err != nil || !slices.Equal(got, ids[1:2]) {
+		t.Errorf("NoteIdsNotEmbeddedWith = %v, %v; want %v", got, err, ids[1:2])
+	}
+}
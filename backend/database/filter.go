@@ -0,0 +1,70 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// NoteFilter restricts which notes a listing or search may return. A note
+// must carry every tag in Tags to match. Since and Until, when set, bound its
+// creation time; Since is inclusive and Until exclusive.
+type NoteFilter struct {
+	Tags  []string
+	Since time.Time
+	Until time.Time
+}
+
+// sqliteTimeFormat matches how CURRENT_TIMESTAMP stores created_at, so that
+// bounds compare correctly as text.
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// whereClause returns an SQL condition on notes, prefixed with AND, and its
+// arguments. It is empty when the filter matches every note.
+func (filter NoteFilter) whereClause() (string, []any) {
+	var clause string
+	var args []any
+
+	if !filter.Since.IsZero() {
+		clause += `
+	AND notes.created_at >= ?`
+		args = append(args, filter.Since.UTC().Format(sqliteTimeFormat))
+	}
+	if !filter.Until.IsZero() {
+		clause += `
+	AND notes.created_at < ?`
+		args = append(args, filter.Until.UTC().Format(sqliteTimeFormat))
+	}
+
+	tags := normalizeTags(filter.Tags)
+	if len(tags) == 0 {
+		return clause, args
+	}
+
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+	args = append(args, len(tags))
+
+	clause += `
+	AND notes.id IN (
+	    SELECT note_tags.note_id
+	    FROM note_tags JOIN tags ON tags.id = note_tags.tag_id
+	    WHERE tags.name IN (` + strings.Repeat("?, ", len(tags)-1) + `?)
+	    GROUP BY note_tags.note_id
+	    HAVING COUNT(*) = ?
+	)`
+	return clause, args
+}
+
+func (filter NoteFilter) IsEmpty() bool {
+	return len(normalizeTags(filter.Tags)) == 0 && filter.Since.IsZero() && filter.Until.IsZero()
+}
+
+// SearchOptions controls the window and cut-off of a ranked search. A zero
+// MaxDistance disables the distance threshold.
+type SearchOptions struct {
+	NoteFilter
+	Limit       int
+	Offset      int
+	MaxDistance float64
+}
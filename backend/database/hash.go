@@ -0,0 +1,31 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ContentHash is the hex SHA-256 of content with runs of whitespace collapsed
+// and the ends trimmed, so reformatted copies of a note hash the same.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(content), " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// FindNoteIdByContentHash returns the id of the oldest note with the given
+// content hash, or 0 if there is none.
+func (manager *SQLiteManager) FindNoteIdByContentHash(hash string) (int, error) {
+	var id int
+	err := manager.DB.QueryRow(`SELECT id FROM notes WHERE content_hash = ? ORDER BY id LIMIT 1`, hash).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		logger.Error("Database: Failed to look up note by content hash", "error", err)
+		return 0, err
+	}
+	return id, nil
+}
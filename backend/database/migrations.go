@@ -0,0 +1,377 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Migration is a single, ordered schema change. Versions must be unique and
+// increasing; Up and Down are executed inside a transaction.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a known migration has been applied.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_notes",
+		Up: `
+		CREATE TABLE IF NOT EXISTS notes (
+		    id INTEGER PRIMARY KEY AUTOINCREMENT,
+		    content TEXT NOT NULL,
+		    embedding_vector BLOB NOT NULL,
+		    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		`,
+		Down: `DROP TABLE IF EXISTS notes;`,
+	},
+	{
+		Version: 2,
+		Name:    "add_note_source_metadata",
+		Up: `
+		ALTER TABLE notes ADD COLUMN source_url TEXT NOT NULL DEFAULT '';
+		ALTER TABLE notes ADD COLUMN title TEXT NOT NULL DEFAULT '';
+		ALTER TABLE notes ADD COLUMN site_name TEXT NOT NULL DEFAULT '';
+		ALTER TABLE notes ADD COLUMN author TEXT NOT NULL DEFAULT '';
+		ALTER TABLE notes ADD COLUMN metadata TEXT NOT NULL DEFAULT '{}';
+		`,
+		Down: `
+		ALTER TABLE notes DROP COLUMN metadata;
+		ALTER TABLE notes DROP COLUMN author;
+		ALTER TABLE notes DROP COLUMN site_name;
+		ALTER TABLE notes DROP COLUMN title;
+		ALTER TABLE notes DROP COLUMN source_url;
+		`,
+	},
+	{
+		// FTS4 rather than FTS5: go-sqlite3 only compiles FTS5 in behind the
+		// sqlite_fts5 build tag, and a binary or test run built without it
+		// would fail this migration with "no such module: fts5". Ranking
+		// uses the bm25 function from registration.go over matchinfo.
+		Version: 3,
+		Name:    "create_notes_fts",
+		Up: `
+		CREATE VIRTUAL TABLE notes_fts USING fts4(content="notes", content, title, tokenize=unicode61);
+
+		CREATE TRIGGER notes_fts_before_update BEFORE UPDATE ON notes BEGIN
+		    DELETE FROM notes_fts WHERE docid = old.id;
+		END;
+		CREATE TRIGGER notes_fts_before_delete BEFORE DELETE ON notes BEGIN
+		    DELETE FROM notes_fts WHERE docid = old.id;
+		END;
+		CREATE TRIGGER notes_fts_after_update AFTER UPDATE ON notes BEGIN
+		    INSERT INTO notes_fts (docid, content, title) VALUES (new.id, new.content, new.title);
+		END;
+		CREATE TRIGGER notes_fts_after_insert AFTER INSERT ON notes BEGIN
+		    INSERT INTO notes_fts (docid, content, title) VALUES (new.id, new.content, new.title);
+		END;
+
+		INSERT INTO notes_fts (notes_fts) VALUES ('rebuild');
+		`,
+		Down: `
+		DROP TRIGGER IF EXISTS notes_fts_after_insert;
+		DROP TRIGGER IF EXISTS notes_fts_after_update;
+		DROP TRIGGER IF EXISTS notes_fts_before_delete;
+		DROP TRIGGER IF EXISTS notes_fts_before_update;
+		DROP TABLE IF EXISTS notes_fts;
+		`,
+	},
+	{
+		// Existing notes become a single chunk carrying the note's own
+		// embedding, so they stay searchable without re-embedding.
+		Version: 4,
+		Name:    "create_note_chunks",
+		Up: `
+		CREATE TABLE note_chunks (
+		    id INTEGER PRIMARY KEY AUTOINCREMENT,
+		    note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+		    chunk_index INTEGER NOT NULL,
+		    content TEXT NOT NULL,
+		    embedding_vector BLOB NOT NULL,
+		    UNIQUE (note_id, chunk_index)
+		);
+
+		CREATE TRIGGER note_chunks_after_note_delete AFTER DELETE ON notes BEGIN
+		    DELETE FROM note_chunks WHERE note_id = old.id;
+		END;
+
+		INSERT INTO note_chunks (note_id, chunk_index, content, embedding_vector)
+		SELECT id, 0, content, embedding_vector FROM notes ORDER BY id;
+		`,
+		Down: `
+		DROP TRIGGER IF EXISTS note_chunks_after_note_delete;
+		DROP TABLE IF EXISTS note_chunks;
+		`,
+	},
+	{
+		// ALTER TABLE cannot add a column with a CURRENT_TIMESTAMP default,
+		// so inserts set updated_at explicitly.
+		Version: 5,
+		Name:    "add_note_updated_at",
+		Up: `
+		ALTER TABLE notes ADD COLUMN updated_at DATETIME;
+		UPDATE notes SET updated_at = created_at;
+		`,
+		Down: `ALTER TABLE notes DROP COLUMN updated_at;`,
+	},
+	{
+		Version: 6,
+		Name:    "create_tags",
+		Up: `
+		CREATE TABLE tags (
+		    id INTEGER PRIMARY KEY AUTOINCREMENT,
+		    name TEXT NOT NULL UNIQUE
+		);
+
+		CREATE TABLE note_tags (
+		    note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+		    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		    PRIMARY KEY (note_id, tag_id)
+		);
+		CREATE INDEX note_tags_tag_id ON note_tags (tag_id);
+
+		CREATE TRIGGER note_tags_after_note_delete AFTER DELETE ON notes BEGIN
+		    DELETE FROM note_tags WHERE note_id = old.id;
+		END;
+		`,
+		Down: `
+		DROP TRIGGER IF EXISTS note_tags_after_note_delete;
+		DROP TABLE IF EXISTS note_tags;
+		DROP TABLE IF EXISTS tags;
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "add_note_embedding_model",
+		// Existing vectors are float64 and, until embedders became pluggable,
+		// always came from LM Studio's default model.
+		Up: `
+		ALTER TABLE notes ADD COLUMN embedding_model TEXT NOT NULL DEFAULT '';
+		ALTER TABLE notes ADD COLUMN embedding_dim INTEGER NOT NULL DEFAULT 0;
+		UPDATE notes
+		SET embedding_model = 'text-embedding-nomic-embed-text-v1.5',
+		    embedding_dim = length(embedding_vector) / 8;
+		CREATE INDEX notes_embedding_model ON notes (embedding_model);
+		`,
+		Down: `
+		DROP INDEX IF EXISTS notes_embedding_model;
+		ALTER TABLE notes DROP COLUMN embedding_dim;
+		ALTER TABLE notes DROP COLUMN embedding_model;
+		`,
+	},
+	{
+		Version: 8,
+		Name:    "add_note_content_hash",
+		// content_hash is the Go UDF registered by RegisterCustomDriver.
+		Up: `
+		ALTER TABLE notes ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
+		UPDATE notes SET content_hash = content_hash(content);
+		CREATE INDEX notes_content_hash ON notes (content_hash);
+		`,
+		Down: `
+		DROP INDEX IF EXISTS notes_content_hash;
+		ALTER TABLE notes DROP COLUMN content_hash;
+		`,
+	},
+	{
+		// Notes captured while the embedding server is down are stored with
+		// an empty embedding and no chunks until the backlog is embedded.
+		Version: 9,
+		Name:    "add_note_embedding_status",
+		Up: `
+		ALTER TABLE notes ADD COLUMN embedding_status TEXT NOT NULL DEFAULT 'ready';
+		CREATE INDEX notes_embedding_status ON notes (embedding_status);
+		`,
+		Down: `
+		DROP INDEX IF EXISTS notes_embedding_status;
+		ALTER TABLE notes DROP COLUMN embedding_status;
+		`,
+	},
+	{
+		// last_used holds Unix milliseconds rather than a DATETIME so that
+		// eviction order survives several uses within one second.
+		Version: 10,
+		Name:    "create_embedding_cache",
+		Up: `
+		CREATE TABLE embedding_cache (
+		    model TEXT NOT NULL,
+		    text_hash TEXT NOT NULL,
+		    embedding_vector BLOB NOT NULL,
+		    last_used INTEGER NOT NULL,
+		    PRIMARY KEY (model, text_hash)
+		);
+		CREATE INDEX embedding_cache_last_used ON embedding_cache (last_used);
+
+		CREATE TABLE embedding_cache_stats (
+		    model TEXT PRIMARY KEY,
+		    hits INTEGER NOT NULL DEFAULT 0,
+		    misses INTEGER NOT NULL DEFAULT 0
+		);
+		`,
+		Down: `
+		DROP TABLE IF EXISTS embedding_cache_stats;
+		DROP INDEX IF EXISTS embedding_cache_last_used;
+		DROP TABLE IF EXISTS embedding_cache;
+		`,
+	},
+	{
+		// Only the SHA-256 of each API token is stored; prefix is kept in
+		// the clear so a token can be recognised in listings.
+		Version: 11,
+		Name:    "create_api_tokens",
+		Up: `
+		CREATE TABLE api_tokens (
+		    id INTEGER PRIMARY KEY AUTOINCREMENT,
+		    name TEXT NOT NULL DEFAULT '',
+		    prefix TEXT NOT NULL,
+		    token_hash TEXT NOT NULL UNIQUE,
+		    scope TEXT NOT NULL,
+		    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		    last_used_at DATETIME
+		);
+		`,
+		Down: `
+		DROP TABLE IF EXISTS api_tokens;
+		`,
+	},
+}
+
+func (manager *SQLiteManager) ensureMigrationsTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+	    version INTEGER PRIMARY KEY,
+	    name TEXT NOT NULL,
+	    applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if _, err := manager.DB.Exec(query); err != nil {
+		logger.Error("Database: Failed to create schema_migrations table", "error", err)
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func (manager *SQLiteManager) appliedMigrations() (map[int]time.Time, error) {
+	if err := manager.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := manager.DB.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		logger.Error("Database: Failed to read applied migrations", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatuses lists every known migration in order along with whether
+// it has been applied to this database.
+func (manager *SQLiteManager) MigrationStatuses() ([]MigrationStatus, error) {
+	applied, err := manager.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		statuses = append(statuses, MigrationStatus{Migration: m, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies all pending migrations in version order and returns the
+// number that were applied.
+func (manager *SQLiteManager) MigrateUp() (int, error) {
+	applied, err := manager.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		err := manager.runMigration(m.Up, func(tx *sql.Tx) error {
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name)
+			return err
+		})
+		if err != nil {
+			logger.Error("Database: Migration failed", "version", m.Version, "name", m.Name, "error", err)
+			return count, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+
+		logger.Debug("Database: Applied migration", "version", m.Version, "name", m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown rolls back the most recently applied migration. It returns nil
+// if no migration has been applied.
+func (manager *SQLiteManager) MigrateDown() (*Migration, error) {
+	applied, err := manager.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		err := manager.runMigration(m.Down, func(tx *sql.Tx) error {
+			_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+			return err
+		})
+		if err != nil {
+			logger.Error("Database: Rollback failed", "version", m.Version, "name", m.Name, "error", err)
+			return nil, fmt.Errorf("rollback of migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+
+		logger.Debug("Database: Rolled back migration", "version", m.Version, "name", m.Name)
+		return &m, nil
+	}
+	return nil, nil
+}
+
+func (manager *SQLiteManager) runMigration(script string, record func(tx *sql.Tx) error) error {
+	tx, err := manager.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
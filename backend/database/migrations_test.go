@@ -0,0 +1,112 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// openTestDB returns an unmigrated database in a temporary directory.
+func openTestDB(t *testing.T) *SQLiteManager {
+	t.Helper()
+	registerDriver.Do(RegisterCustomDriver)
+
+	manager, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { manager.DB.Close() })
+	return manager
+}
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	for i, m := range migrations {
+		if m.Name == "" || m.Up == "" || m.Down == "" {
+			t.Errorf("migration %d is missing its name, Up or Down", m.Version)
+		}
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			t.Errorf("migration %d follows %d", m.Version, migrations[i-1].Version)
+		}
+	}
+}
+
+func TestMigrateUpAndDown(t *testing.T) {
+	manager := openTestDB(t)
+
+	applied, err := manager.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Fatalf("MigrateUp applied %d migrations, want %d", applied, len(migrations))
+	}
+	if applied, err := manager.MigrateUp(); err != nil || applied != 0 {
+		t.Fatalf("second MigrateUp = %d, %v, want nothing to do", applied, err)
+	}
+	statuses, err := manager.MigrationStatuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt.IsZero() {
+			t.Errorf("migration %d: applied %v at %v, want applied with a time", status.Version, status.Applied, status.AppliedAt)
+		}
+	}
+
+	// Every migration rolls back cleanly, newest first.
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m, err := manager.MigrateDown()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m == nil || m.Version != migrations[i].Version {
+			t.Fatalf("MigrateDown rolled back %v, want migration %d", m, migrations[i].Version)
+		}
+	}
+	if m, err := manager.MigrateDown(); err != nil || m != nil {
+		t.Fatalf("MigrateDown on an empty schema = %v, %v, want nothing to do", m, err)
+	}
+	var tables int
+	if err := manager.DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("%d tables left after rolling everything back", tables)
+	}
+
+	if applied, err := manager.MigrateUp(); err != nil || applied != len(migrations) {
+		t.Fatalf("MigrateUp after rolling back = %d, %v, want %d", applied, err, len(migrations))
+	}
+}
+
+// TestMigrateExistingDatabase upgrades a notes table created before
+// migrations existed, which the first migration must leave in place.
+func TestMigrateExistingDatabase(t *testing.T) {
+	manager := openTestDB(t)
+	vector, _ := EncodeVector([]float64{1, 0}, VectorFloat64)
+	_, err := manager.DB.Exec(`
+	CREATE TABLE notes (
+	    id INTEGER PRIMARY KEY AUTOINCREMENT,
+	    content TEXT NOT NULL,
+	    embedding_vector BLOB NOT NULL,
+	    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO notes (content, embedding_vector) VALUES ('An old  note.', ?);
+	`, vector)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := manager.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	note, err := manager.GetNoteById(1)
+	if err != nil || note == nil {
+		t.Fatalf("GetNoteById = %v, %v", note, err)
+	}
+	if note.Content != "An old  note." || note.Metadata != "{}" {
+		t.Errorf("note = %+v, want the old content with empty metadata", note)
+	}
+	if id, err := manager.FindNoteIdByContentHash(ContentHash("An old note.")); err != nil || id != 1 {
+		t.Errorf("FindNoteIdByContentHash = %d, %v, want the old note's hash filled in", id, err)
+	}
+}
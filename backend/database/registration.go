@@ -1,67 +1,69 @@
 package database
 
 import (
-	"bytes"
 	"database/sql"
 	"encoding/binary"
+	"math"
 
 	"github.com/mattn/go-sqlite3"
-	"gonum.org/v1/gonum/floats"
 )
 
 func RegisterCustomDriver() {
 	sql.Register("sqlite_extended",
 		&sqlite3.SQLiteDriver{
 			ConnectHook: func(sc *sqlite3.SQLiteConn) error {
-				return sc.RegisterFunc("vector_distance", vectorDistance, true)
+				if err := sc.RegisterFunc("vector_distance", newVectorDistance(), true); err != nil {
+					return err
+				}
+				if err := sc.RegisterFunc("content_hash", ContentHash, true); err != nil {
+					return err
+				}
+				return sc.RegisterFunc("bm25", bm25, true)
 			},
 		})
 }
 
-func vectorDistance(a, b []byte) float64 {
-	if len(a) == 0 || len(b) == 0 {
-		return 999.0
-	}
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
 
-	fa, err := bytesToFloatSlice(a)
-	if err != nil {
-		return 999.0
+// bm25 scores a full-text match from the blob returned by
+// matchinfo(fts_table, 'pcnalx'), summing Okapi BM25 over every phrase and
+// column. Higher is more relevant. A blob shorter than its header claims
+// scores 0.
+func bm25(matchinfo []byte) float64 {
+	info := make([]uint32, len(matchinfo)/4)
+	for i := range info {
+		info[i] = binary.NativeEndian.Uint32(matchinfo[i*4:])
 	}
-
-	fb, err := bytesToFloatSlice(b)
-	if err != nil {
-		return 999.0
+	if len(info) < 3 {
+		return 0
 	}
 
-	normA := floats.Norm(fa, 2)
-	normB := floats.Norm(fb, 2)
-
-	if normA == 0.0 || normB == 0.0 {
-		return 999.0
+	phrases, columns, totalDocs := int(info[0]), int(info[1]), float64(info[2])
+	// Bounding the counts first keeps the length below from overflowing.
+	if phrases > len(info) || columns > len(info) || len(info) < 3+2*columns+3*columns*phrases {
+		return 0
 	}
+	avgLengths := info[3 : 3+columns]
+	rowLengths := info[3+columns : 3+2*columns]
+	hits := info[3+2*columns:]
 
-	distance := 1.0 - floats.Dot(fa, fb)/(normA*normB)
-	return distance
-}
-
-func bytesToFloatSlice(b []byte) ([]float64, error) {
-	floatCount := len(b) / 8
-	floats := make([]float64, floatCount)
-	err := binary.Read(bytes.NewReader(b), binary.LittleEndian, &floats)
-
-	if err != nil {
-		logger.Error("Binary conversion failed", "error", err)
-		return nil, err
-	}
-	return floats, nil
-}
+	score := 0.0
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < columns; c++ {
+			offset := 3 * (c + p*columns)
+			termFreq := float64(hits[offset])
+			docsWithTerm := float64(hits[offset+2])
+			if termFreq == 0 || avgLengths[c] == 0 {
+				continue
+			}
 
-func FloatSliceToBytes(floats []float64) ([]byte, error) {
-	var buf bytes.Buffer
-	err := binary.Write(&buf, binary.LittleEndian, floats)
-	if err != nil {
-		logger.Error("Binary write failed during conversion", "error", err)
-		return nil, err
+			idf := math.Log(1 + (totalDocs-docsWithTerm+0.5)/(docsWithTerm+0.5))
+			lengthNorm := 1 - bm25B + bm25B*float64(rowLengths[c])/float64(avgLengths[c])
+			score += idf * termFreq * (bm25K1 + 1) / (termFreq + bm25K1*lengthNorm)
+		}
 	}
-	return buf.Bytes(), nil
+	return score
 }
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func matchinfoBlob(values ...uint32) []byte {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.NativeEndian.PutUint32(b[i*4:], v)
+	}
+	return b
+}
+
+func bm25Term(tf, df, docs, rowLength, avgLength float64) float64 {
+	idf := math.Log(1 + (docs-df+0.5)/(df+0.5))
+	norm := 1 - bm25B + bm25B*rowLength/avgLength
+	return idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+}
+
+func TestBM25(t *testing.T) {
+	tests := []struct {
+		name      string
+		matchinfo []byte
+		want      float64
+	}{
+		{
+			name: "one phrase, one column",
+			// p c n a l, then hits-this-row hits-all-rows docs-with-hits.
+			matchinfo: matchinfoBlob(1, 1, 10, 5, 5, 2, 3, 4),
+			want:      bm25Term(2, 4, 10, 5, 5),
+		},
+		{
+			name:      "longer row than average",
+			matchinfo: matchinfoBlob(1, 1, 10, 5, 20, 2, 3, 4),
+			want:      bm25Term(2, 4, 10, 20, 5),
+		},
+		{
+			name: "two phrases, two columns",
+			matchinfo: matchinfoBlob(2, 2, 100, 50, 4, 40, 2,
+				3, 9, 20, 1, 1, 1, // phrase 0: content, title
+				0, 5, 5, 2, 2, 2, // phrase 1: only in the title
+			),
+			want: bm25Term(3, 20, 100, 40, 50) + bm25Term(1, 1, 100, 2, 4) + bm25Term(2, 2, 100, 2, 4),
+		},
+		{
+			name:      "no hits",
+			matchinfo: matchinfoBlob(1, 1, 10, 5, 5, 0, 3, 4),
+			want:      0,
+		},
+		{
+			name:      "empty column",
+			matchinfo: matchinfoBlob(1, 1, 10, 0, 0, 1, 1, 1),
+			want:      0,
+		},
+		{
+			name:      "truncated hits",
+			matchinfo: matchinfoBlob(2, 1, 10, 5, 5, 2, 3, 4),
+			want:      0,
+		},
+		{
+			name:      "too short",
+			matchinfo: matchinfoBlob(1, 1),
+			want:      0,
+		},
+		{
+			name:      "more columns than the blob holds",
+			matchinfo: matchinfoBlob(1, 50, 10, 5, 5, 2, 3, 4),
+			want:      0,
+		},
+		{
+			name:      "counts large enough to overflow",
+			matchinfo: matchinfoBlob(1<<31, 1<<31, 10, 5, 5, 2, 3, 4),
+			want:      0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bm25(tt.matchinfo); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("bm25 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestBM25Matchinfo checks the matchinfo layout bm25 expects against the one
+// SQLite produces for the notes_fts table.
+func TestBM25Matchinfo(t *testing.T) {
+	manager := newTestManager(t)
+	for _, content := range []string{
+		"apple banana apple",
+		"banana cherry",
+		"cherry date elderberry fig",
+	} {
+		if _, err := manager.SaveNote(Note{Content: content}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var info []byte
+	var score float64
+	err := manager.DB.QueryRow(`
+	SELECT matchinfo(notes_fts, 'pcnalx'), bm25(matchinfo(notes_fts, 'pcnalx'))
+	FROM notes_fts WHERE notes_fts MATCH 'apple' AND docid = 1`).Scan(&info, &score)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One phrase over the content and title columns of three notes, with
+	// no titles; apple appears twice in the first note only.
+	want := matchinfoBlob(1, 2, 3, 3, 0, 3, 0, 2, 2, 1, 0, 0, 0)
+	if string(info) != string(want) {
+		t.Fatalf("matchinfo = %v, want %v", decodeMatchinfo(info), decodeMatchinfo(want))
+	}
+	if expected := bm25Term(2, 1, 3, 3, 3); math.Abs(score-expected) > 1e-12 {
+		t.Errorf("bm25 = %v, want %v", score, expected)
+	}
+
+	notes, err := manager.KeywordSearchNotes("banana", SearchOptions{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 2 || notes[0].Id != 2 || notes[0].Score <= notes[1].Score {
+		t.Errorf("KeywordSearchNotes(banana) should rank the shorter note first, got %+v", notes)
+	}
+}
+
+func decodeMatchinfo(b []byte) []uint32 {
+	values := make([]uint32, len(b)/4)
+	for i := range values {
+		values[i] = binary.NativeEndian.Uint32(b[i*4:])
+	}
+	return values
+}
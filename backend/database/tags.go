@@ -0,0 +1,169 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type Tag struct {
+	Name      string
+	NoteCount int
+}
+
+// NormalizeTag lower-cases and trims a tag name so "Kubernetes " and
+// "kubernetes" are the same tag.
+func NormalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func normalizeTags(names []string) []string {
+	seen := make(map[string]struct{}, len(names))
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		tag := NormalizeTag(name)
+		if _, ok := seen[tag]; ok || tag == "" {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func addTags(tx *sql.Tx, noteId int, names []string) error {
+	for _, tag := range normalizeTags(names) {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO tags (name) VALUES (?)`, tag); err != nil {
+			logger.Error("Database: Failed to insert tag", "tag", tag, "error", err)
+			return fmt.Errorf("failed to insert tag %q: %w", tag, err)
+		}
+		_, err := tx.Exec(`
+		INSERT OR IGNORE INTO note_tags (note_id, tag_id)
+		SELECT ?, id FROM tags WHERE name = ?`, noteId, tag)
+		if err != nil {
+			logger.Error("Database: Failed to tag note", "note_id", noteId, "tag", tag, "error", err)
+			return fmt.Errorf("failed to tag note: %w", err)
+		}
+	}
+	return nil
+}
+
+// AddTags attaches tags to a note, creating any tags that don't exist yet.
+func (manager *SQLiteManager) AddTags(noteId int, names []string) error {
+	tx, err := manager.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for tagging: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := addTags(tx, noteId, names); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveTags detaches tags from a note. Tags left without notes are deleted.
+func (manager *SQLiteManager) RemoveTags(noteId int, names []string) error {
+	tags := normalizeTags(names)
+	if len(tags) == 0 {
+		return nil
+	}
+
+	args := []any{noteId}
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+
+	tx, err := manager.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for untagging: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+	DELETE FROM note_tags
+	WHERE note_id = ? AND tag_id IN (SELECT id FROM tags WHERE name IN (`+strings.Repeat("?, ", len(tags)-1)+`?))`, args...)
+	if err != nil {
+		logger.Error("Database: Failed to remove tags", "note_id", noteId, "error", err)
+		return fmt.Errorf("failed to remove tags: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM note_tags)`); err != nil {
+		logger.Error("Database: Failed to delete unused tags", "error", err)
+		return fmt.Errorf("failed to delete unused tags: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ListTags returns every tag with the number of notes carrying it.
+func (manager *SQLiteManager) ListTags() ([]Tag, error) {
+	rows, err := manager.DB.Query(`
+	SELECT tags.name, COUNT(note_tags.note_id)
+	FROM tags LEFT JOIN note_tags ON note_tags.tag_id = tags.id
+	GROUP BY tags.id
+	ORDER BY tags.name`)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for tags", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]Tag, 0)
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.Name, &tag.NoteCount); err != nil {
+			logger.Error("Database: Failed to scan tag row", "error", err)
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// attachTagsBatchSize keeps the IN list of attachTags well below SQLite's
+// limit on bound parameters.
+const attachTagsBatchSize = 500
+
+// attachTags fills in the Tags field of every note. It must be called after
+// the query that produced notes has been fully read.
+func (manager *SQLiteManager) attachTags(notes []Note) error {
+	for start := 0; start < len(notes); start += attachTagsBatchSize {
+		if err := manager.attachTagsBatch(notes[start:min(start+attachTagsBatchSize, len(notes))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (manager *SQLiteManager) attachTagsBatch(notes []Note) error {
+	byId := make(map[int]*Note, len(notes))
+	args := make([]any, 0, len(notes))
+	for i := range notes {
+		notes[i].Tags = []string{}
+		byId[notes[i].Id] = &notes[i]
+		args = append(args, notes[i].Id)
+	}
+
+	rows, err := manager.DB.Query(`
+	SELECT note_tags.note_id, tags.name
+	FROM note_tags JOIN tags ON tags.id = note_tags.tag_id
+	WHERE note_tags.note_id IN (`+strings.Repeat("?, ", len(args)-1)+`?)
+	ORDER BY tags.name`, args...)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for note tags", "error", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var noteId int
+		var name string
+		if err := rows.Scan(&noteId, &name); err != nil {
+			logger.Error("Database: Failed to scan note tag row", "error", err)
+			return err
+		}
+		if note, ok := byId[noteId]; ok {
+			note.Tags = append(note.Tags, name)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// APIToken is a stored API token. The token itself is never stored, only
+// its hash; Prefix is its first few characters.
+type APIToken struct {
+	Id         int
+	Name       string
+	Prefix     string
+	Scope      string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+const apiTokenColumns = `id, name, prefix, scope, created_at, last_used_at`
+
+// apiTokenUseResolution is how far behind last_used_at may fall. Every API
+// request looks its token up, so recording each use would turn every read
+// into a write.
+const apiTokenUseResolution = time.Minute
+
+func scanAPIToken(row rowScanner) (APIToken, error) {
+	var token APIToken
+	var lastUsed sql.NullTime
+	err := row.Scan(&token.Id, &token.Name, &token.Prefix, &token.Scope, &token.CreatedAt, &lastUsed)
+	token.LastUsedAt = lastUsed.Time
+	return token, err
+}
+
+// SaveAPIToken stores a token by its hash and returns the stored record.
+func (manager *SQLiteManager) SaveAPIToken(name, prefix, tokenHash, scope string) (APIToken, error) {
+	row := manager.DB.QueryRow(`
+	INSERT INTO api_tokens (name, prefix, token_hash, scope) VALUES (?, ?, ?, ?)
+	RETURNING `+apiTokenColumns, name, prefix, tokenHash, scope)
+	token, err := scanAPIToken(row)
+	if err != nil {
+		logger.Error("Database: Failed to insert API token", "error", err)
+		return APIToken{}, fmt.Errorf("failed to insert API token: %w", err)
+	}
+
+	logger.Debug("Database: Successfully saved API token", "id", token.Id, "scope", scope)
+	return token, nil
+}
+
+// FindAPITokenByHash returns the token with the given hash, or nil if there
+// is none, and records that it was used unless that was recorded less than
+// apiTokenUseResolution ago.
+func (manager *SQLiteManager) FindAPITokenByHash(tokenHash string) (*APIToken, error) {
+	row := manager.DB.QueryRow(`SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash = ?`, tokenHash)
+	token, err := scanAPIToken(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("Database: Failed to look up API token", "error", err)
+		return nil, fmt.Errorf("failed to look up API token: %w", err)
+	}
+
+	if time.Since(token.LastUsedAt) >= apiTokenUseResolution {
+		// A stale last_used_at is not worth refusing the request over.
+		if _, err := manager.DB.Exec(`UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?`, token.Id); err != nil {
+			logger.Warn("Database: Failed to record API token use", "id", token.Id, "error", err)
+		} else {
+			token.LastUsedAt = time.Now().UTC().Truncate(time.Second)
+		}
+	}
+	return &token, nil
+}
+
+// ListAPITokens returns every stored token in creation order.
+func (manager *SQLiteManager) ListAPITokens() ([]APIToken, error) {
+	rows, err := manager.DB.Query(`SELECT ` + apiTokenColumns + ` FROM api_tokens ORDER BY id`)
+	if err != nil {
+		logger.Error("Database: Failed to execute SELECT query for API tokens", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]APIToken, 0)
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			logger.Error("Database: Failed to scan API token row", "error", err)
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+	return tokens, nil
+}
+
+// DeleteAPIToken removes a token, reporting whether it existed.
+func (manager *SQLiteManager) DeleteAPIToken(id int) (bool, error) {
+	result, err := manager.DB.Exec(`DELETE FROM api_tokens WHERE id = ?`, id)
+	if err != nil {
+		logger.Error("Database: Failed to delete API token", "id", id, "error", err)
+		return false, fmt.Errorf("failed to delete API token: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	logger.Debug("Database: Deleted API token", "id", id, "deleted", deleted > 0)
+	return deleted > 0, nil
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindAPITokenByHash(t *testing.T) {
+	manager := newTestManager(t)
+	saved, err := manager.SaveAPIToken("laptop", "syn_abcdef", "hash", "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.LastUsedAt.IsZero() {
+		t.Errorf("new token LastUsedAt = %v, want unset", saved.LastUsedAt)
+	}
+
+	if token, err := manager.FindAPITokenByHash("other"); err != nil || token != nil {
+		t.Fatalf("unknown hash = %v, %v, want nil", token, err)
+	}
+
+	token, err := manager.FindAPITokenByHash("hash")
+	if err != nil || token == nil {
+		t.Fatalf("FindAPITokenByHash = %v, %v", token, err)
+	}
+	if token.Id != saved.Id || token.Scope != "read" || time.Since(token.LastUsedAt) > time.Minute {
+		t.Errorf("token = %+v, want token %d with its first use recorded", token, saved.Id)
+	}
+
+	lastUsed := func() string {
+		t.Helper()
+		var value string
+		if err := manager.DB.QueryRow(`SELECT last_used_at FROM api_tokens WHERE id = ?`, saved.Id).Scan(&value); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	setLastUsed := func(modifier string) string {
+		t.Helper()
+		if _, err := manager.DB.Exec(`UPDATE api_tokens SET last_used_at = datetime('now', ?) WHERE id = ?`, modifier, saved.Id); err != nil {
+			t.Fatal(err)
+		}
+		return lastUsed()
+	}
+
+	// A recent use is not written again.
+	recent := setLastUsed("-10 seconds")
+	if _, err := manager.FindAPITokenByHash("hash"); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(); got != recent {
+		t.Errorf("last_used_at = %s after a lookup, want it left at %s", got, recent)
+	}
+
+	stale := setLastUsed("-2 minutes")
+	token, err = manager.FindAPITokenByHash("hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(); got == stale {
+		t.Errorf("last_used_at = %s was not updated after two minutes", got)
+	}
+	if time.Since(token.LastUsedAt) > 10*time.Second {
+		t.Errorf("returned LastUsedAt = %v, want now", token.LastUsedAt)
+	}
+}
@@ -0,0 +1,294 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+// VectorFormat identifies how an embedding is encoded in a BLOB.
+//
+// Float64 is the original headerless layout: little-endian float64 values and
+// nothing else. Every other format starts with a header of one format byte
+// and the vector dimension as a little-endian uint32, followed by the payload.
+// A zero byte is appended when that would otherwise leave the BLOB a multiple
+// of 8 bytes long, so headered BLOBs are never mistaken for float64 ones.
+type VectorFormat byte
+
+const (
+	VectorFloat64 VectorFormat = iota
+	VectorFloat32
+	VectorInt8
+	VectorBinary
+)
+
+const DEFAULT_VECTOR_FORMAT = VectorFloat32
+
+const vectorHeaderSize = 5
+
+var vectorFormatNames = map[VectorFormat]string{
+	VectorFloat64: "float64",
+	VectorFloat32: "float32",
+	VectorInt8:    "int8",
+	VectorBinary:  "binary",
+}
+
+var ErrInvalidVector = errors.New("invalid vector encoding")
+
+func (format VectorFormat) String() string {
+	if name, ok := vectorFormatNames[format]; ok {
+		return name
+	}
+	return fmt.Sprintf("VectorFormat(%d)", byte(format))
+}
+
+// ParseVectorFormat accepts float64, float32, int8 or binary.
+func ParseVectorFormat(name string) (VectorFormat, error) {
+	for format, formatName := range vectorFormatNames {
+		if strings.EqualFold(name, formatName) {
+			return format, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown vector format %q: expected float64, float32, int8 or binary", name)
+}
+
+// payloadSize is the number of payload bytes for a vector of dim values.
+func (format VectorFormat) payloadSize(dim int) int {
+	switch format {
+	case VectorFloat64:
+		return dim * 8
+	case VectorFloat32:
+		return dim * 4
+	case VectorInt8:
+		// A float32 scale followed by one signed byte per value.
+		return 4 + dim
+	case VectorBinary:
+		return (dim + 7) / 8
+	}
+	return 0
+}
+
+// EncodeVector serialises vector in the given format. Int8 stores each value
+// scaled against the largest magnitude; binary keeps only the signs, which is
+// enough to approximate cosine distance for high-dimensional embeddings.
+func EncodeVector(vector []float64, format VectorFormat) ([]byte, error) {
+	if format == VectorFloat64 {
+		var buf bytes.Buffer
+		if err := binary.Write(&buf, binary.LittleEndian, vector); err != nil {
+			logger.Error("Binary write failed during conversion", "error", err)
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	if _, ok := vectorFormatNames[format]; !ok {
+		return nil, fmt.Errorf("%w: unknown format %d", ErrInvalidVector, format)
+	}
+
+	size := vectorHeaderSize + format.payloadSize(len(vector))
+	if size%8 == 0 {
+		size++
+	}
+	b := make([]byte, size)
+	b[0] = byte(format)
+	binary.LittleEndian.PutUint32(b[1:], uint32(len(vector)))
+	payload := b[vectorHeaderSize:]
+
+	switch format {
+	case VectorFloat32:
+		for i, value := range vector {
+			binary.LittleEndian.PutUint32(payload[i*4:], math.Float32bits(float32(value)))
+		}
+	case VectorInt8:
+		scale := 0.0
+		for _, value := range vector {
+			scale = math.Max(scale, math.Abs(value))
+		}
+		binary.LittleEndian.PutUint32(payload, math.Float32bits(float32(scale)))
+		if scale > 0 {
+			for i, value := range vector {
+				payload[4+i] = byte(int8(math.Round(value / scale * 127)))
+			}
+		}
+	case VectorBinary:
+		for i, value := range vector {
+			if value > 0 {
+				payload[i/8] |= 1 << (i % 8)
+			}
+		}
+	}
+	return b, nil
+}
+
+// VectorFormatOf reports the format of an encoded vector and its dimension.
+func VectorFormatOf(b []byte) (VectorFormat, int, error) {
+	if len(b)%8 == 0 {
+		return VectorFloat64, len(b) / 8, nil
+	}
+	if len(b) < vectorHeaderSize {
+		return 0, 0, fmt.Errorf("%w: %d bytes is too short", ErrInvalidVector, len(b))
+	}
+
+	format := VectorFormat(b[0])
+	if _, ok := vectorFormatNames[format]; !ok || format == VectorFloat64 {
+		return 0, 0, fmt.Errorf("%w: unknown format %d", ErrInvalidVector, b[0])
+	}
+	dim := int(binary.LittleEndian.Uint32(b[1:]))
+	if len(b)-vectorHeaderSize < format.payloadSize(dim) {
+		return 0, 0, fmt.Errorf("%w: truncated %s vector", ErrInvalidVector, format)
+	}
+	return format, dim, nil
+}
+
+// DecodeVector reads a vector in any supported format. Binary vectors decode
+// to +1 and -1 values.
+func DecodeVector(b []byte) ([]float64, error) {
+	format, dim, err := VectorFormatOf(b)
+	if err != nil {
+		logger.Error("Binary conversion failed", "error", err)
+		return nil, err
+	}
+	vector := make([]float64, dim)
+	reader := newVectorReader(format, b)
+	for i := range vector {
+		vector[i] = reader.at(i)
+	}
+	return vector, nil
+}
+
+// vectorReader gives indexed access to an encoded vector without decoding it
+// into a new slice, which keeps vector_distance allocation free per row.
+type vectorReader struct {
+	format  VectorFormat
+	payload []byte
+	scale   float64
+}
+
+func newVectorReader(format VectorFormat, b []byte) vectorReader {
+	if format == VectorFloat64 {
+		return vectorReader{format: format, payload: b}
+	}
+	reader := vectorReader{format: format, payload: b[vectorHeaderSize:]}
+	if format == VectorInt8 {
+		reader.scale = float64(math.Float32frombits(binary.LittleEndian.Uint32(reader.payload))) / 127
+		reader.payload = reader.payload[4:]
+	}
+	return reader
+}
+
+func (r vectorReader) at(i int) float64 {
+	switch r.format {
+	case VectorFloat64:
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.payload[i*8:]))
+	case VectorFloat32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(r.payload[i*4:])))
+	case VectorInt8:
+		return float64(int8(r.payload[i])) * r.scale
+	case VectorBinary:
+		if r.payload[i/8]&(1<<(i%8)) != 0 {
+			return 1
+		}
+		return -1
+	}
+	return 0
+}
+
+// queryCacheSize is how many query vectors a queryCache keeps decoded.
+const queryCacheSize = 8
+
+// queryCache keeps decoded second arguments of vector_distance, which is the
+// same query vector for every row of a search. Each connection has its own,
+// so searches on different connections never contend for it, and statements
+// interleaved on one connection each find their query among the entries.
+type queryCache struct {
+	mu      sync.Mutex
+	entries map[string]decodedQuery
+	// keys holds the cached blobs oldest first, for eviction.
+	keys []string
+}
+
+type decodedQuery struct {
+	vector []float64
+	norm   float64
+}
+
+func newQueryCache() *queryCache {
+	return &queryCache{entries: make(map[string]decodedQuery, queryCacheSize)}
+}
+
+func (c *queryCache) decode(b []byte) (decodedQuery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if query, ok := c.entries[string(b)]; ok {
+		return query, nil
+	}
+	vector, err := DecodeVector(b)
+	if err != nil {
+		return decodedQuery{}, err
+	}
+	norm := 0.0
+	for _, value := range vector {
+		norm += value * value
+	}
+	query := decodedQuery{vector: vector, norm: math.Sqrt(norm)}
+
+	if len(c.keys) == queryCacheSize {
+		delete(c.entries, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	key := string(b)
+	c.entries[key] = query
+	c.keys = append(c.keys, key)
+	return query, nil
+}
+
+// newVectorDistance returns the vector_distance function for one connection,
+// with its own queryCache.
+func newVectorDistance() func(a, b []byte) float64 {
+	cache := newQueryCache()
+	return func(a, b []byte) float64 {
+		return vectorDistance(cache, a, b)
+	}
+}
+
+// vectorDistance is the cosine distance between two encoded vectors, or 999
+// when they can't be compared. The query vector b is decoded through cache.
+func vectorDistance(cache *queryCache, a, b []byte) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 999.0
+	}
+
+	format, dim, err := VectorFormatOf(a)
+	if err != nil {
+		return 999.0
+	}
+
+	decoded, err := cache.decode(b)
+	if err != nil {
+		return 999.0
+	}
+	query, normB := decoded.vector, decoded.norm
+
+	// Vectors from different models can't be compared.
+	if dim != len(query) {
+		return 999.0
+	}
+
+	reader := newVectorReader(format, a)
+	dot, normA := 0.0, 0.0
+	for i, q := range query {
+		value := reader.at(i)
+		dot += value * q
+		normA += value * value
+	}
+
+	if normA == 0.0 || normB == 0.0 {
+		return 999.0
+	}
+
+	return 1.0 - dot/(math.Sqrt(normA)*normB)
+}
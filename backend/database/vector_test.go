@@ -0,0 +1,195 @@
+package database
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestVectorRoundTrip(t *testing.T) {
+	vector := []float64{0.5, -0.25, 1, 0, -1, 0.125, 0.75, -0.5, 0.3}
+
+	tests := []struct {
+		format    VectorFormat
+		tolerance float64
+	}{
+		{VectorFloat64, 0},
+		{VectorFloat32, 1e-7},
+		{VectorInt8, 1.0 / 127},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			encoded, err := EncodeVector(vector, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			format, dim, err := VectorFormatOf(encoded)
+			if err != nil || format != tt.format || dim != len(vector) {
+				t.Fatalf("VectorFormatOf = %v, %d, %v; want %v, %d", format, dim, err, tt.format, len(vector))
+			}
+			decoded, err := DecodeVector(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(decoded) != len(vector) {
+				t.Fatalf("decoded %d values, want %d", len(decoded), len(vector))
+			}
+			for i := range vector {
+				if math.Abs(decoded[i]-vector[i]) > tt.tolerance {
+					t.Errorf("value %d = %v, want %v", i, decoded[i], vector[i])
+				}
+			}
+		})
+	}
+
+	t.Run("binary", func(t *testing.T) {
+		encoded, err := EncodeVector(vector, VectorBinary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := DecodeVector(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, value := range vector {
+			want := -1.0
+			if value > 0 {
+				want = 1
+			}
+			if decoded[i] != want {
+				t.Errorf("value %d = %v, want %v", i, decoded[i], want)
+			}
+		}
+	})
+}
+
+// TestLegacyFloat64 decodes a headerless BLOB as written before vector
+// formats existed.
+func TestLegacyFloat64(t *testing.T) {
+	vector := []float64{3, -1.5, 0.25}
+	legacy := make([]byte, 8*len(vector))
+	for i, value := range vector {
+		binary.LittleEndian.PutUint64(legacy[i*8:], math.Float64bits(value))
+	}
+
+	format, dim, err := VectorFormatOf(legacy)
+	if err != nil || format != VectorFloat64 || dim != len(vector) {
+		t.Fatalf("VectorFormatOf = %v, %d, %v; want float64, %d", format, dim, err, len(vector))
+	}
+	decoded, err := DecodeVector(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range vector {
+		if decoded[i] != vector[i] {
+			t.Errorf("value %d = %v, want %v", i, decoded[i], vector[i])
+		}
+	}
+}
+
+// TestVectorPadding covers dimensions whose headered encoding would be a
+// multiple of 8 bytes long, and so read as float64, without the pad byte.
+func TestVectorPadding(t *testing.T) {
+	tests := []struct {
+		format VectorFormat
+		dim    int
+	}{
+		{VectorInt8, 7},
+		{VectorBinary, 17},
+		{VectorBinary, 24},
+	}
+	for _, tt := range tests {
+		vector := make([]float64, tt.dim)
+		for i := range vector {
+			vector[i] = float64(i) - 3
+		}
+		encoded, err := EncodeVector(vector, tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unpadded := vectorHeaderSize + tt.format.payloadSize(tt.dim); unpadded%8 != 0 {
+			t.Fatalf("%v with %d dimensions does not need padding", tt.format, tt.dim)
+		}
+		if len(encoded)%8 == 0 {
+			t.Errorf("%v with %d dimensions encoded to %d bytes", tt.format, tt.dim, len(encoded))
+		}
+		format, dim, err := VectorFormatOf(encoded)
+		if err != nil || format != tt.format || dim != tt.dim {
+			t.Errorf("VectorFormatOf = %v, %d, %v; want %v, %d", format, dim, err, tt.format, tt.dim)
+		}
+	}
+}
+
+func TestInvalidVectors(t *testing.T) {
+	float32Vector, _ := EncodeVector([]float64{1, 2, 3}, VectorFloat32)
+
+	tests := []struct {
+		name    string
+		encoded []byte
+	}{
+		{"too short", []byte{1, 2, 3}},
+		{"unknown format", []byte{9, 1, 0, 0, 0, 0, 0, 0, 0}},
+		{"float64 tag", []byte{0, 1, 0, 0, 0, 0, 0, 0, 0}},
+		{"truncated", float32Vector[:len(float32Vector)-2]},
+	}
+	for _, tt := range tests {
+		if _, err := DecodeVector(tt.encoded); !errors.Is(err, ErrInvalidVector) {
+			t.Errorf("%s: error = %v, want ErrInvalidVector", tt.name, err)
+		}
+	}
+
+	if _, err := EncodeVector([]float64{1}, VectorFormat(9)); !errors.Is(err, ErrInvalidVector) {
+		t.Errorf("EncodeVector with unknown format: error = %v, want ErrInvalidVector", err)
+	}
+}
+
+func TestVectorDistanceAcrossFormats(t *testing.T) {
+	a := []float64{1, 2, 3, -1}
+	b := []float64{2, 1, 0, 1}
+	query, _ := EncodeVector(b, VectorFloat64)
+	want := 1 - (2+2+0-1)/(math.Sqrt(15)*math.Sqrt(6))
+
+	cache := newQueryCache()
+	for _, format := range []VectorFormat{VectorFloat64, VectorFloat32, VectorInt8} {
+		stored, _ := EncodeVector(a, format)
+		if got := vectorDistance(cache, stored, query); math.Abs(got-want) > 0.02 {
+			t.Errorf("%v: distance = %v, want %v", format, got, want)
+		}
+	}
+
+	other, _ := EncodeVector([]float64{1, 2}, VectorFloat32)
+	if got := vectorDistance(cache, other, query); got != 999 {
+		t.Errorf("distance across dimensions = %v, want 999", got)
+	}
+}
+
+func TestVectorDistanceInterleavedQueries(t *testing.T) {
+	stored, _ := EncodeVector([]float64{1, 0}, VectorFloat32)
+	// More queries than the cache holds, so entries are evicted as they
+	// are used in turn.
+	queries := make([][]byte, 2*queryCacheSize)
+	want := make([]float64, len(queries))
+	for i := range queries {
+		angle := float64(i) * math.Pi / float64(len(queries))
+		queries[i], _ = EncodeVector([]float64{math.Cos(angle), math.Sin(angle)}, VectorFloat32)
+		want[i] = 1 - math.Cos(angle)
+	}
+
+	distance := newVectorDistance()
+	var wg sync.WaitGroup
+	for i := range queries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if got := distance(stored, queries[i]); math.Abs(got-want[i]) > 1e-6 {
+					t.Errorf("query %d: distance = %v, want %v", i, got, want[i])
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,218 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"synapse/database"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatJSONL    Format = "jsonl"
+	FormatMarkdown Format = "markdown"
+	FormatCSV      Format = "csv"
+	FormatHTML     Format = "html"
+)
+
+// ParseFormat accepts jsonl, markdown, csv or html.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatJSONL, FormatMarkdown, FormatCSV, FormatHTML:
+		return format, nil
+	case "md":
+		return FormatMarkdown, nil
+	}
+	return "", fmt.Errorf("unknown export format %q: expected jsonl, markdown, csv or html", name)
+}
+
+// ContentType is the MIME type of an export in this format.
+func (format Format) ContentType() string {
+	switch format {
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// Extension is the usual file extension, with its dot.
+func (format Format) Extension() string {
+	if format == FormatMarkdown {
+		return ".md"
+	}
+	return "." + string(format)
+}
+
+// SupportsEmbeddings reports whether the format can carry embedding vectors.
+func (format Format) SupportsEmbeddings() bool {
+	return format == FormatJSONL || format == FormatCSV
+}
+
+// Writer writes notes one at a time. Close must be called after the last note
+// to complete the output; it does not close the underlying io.Writer.
+type Writer interface {
+	Write(note database.Note) error
+	Close() error
+}
+
+// Options controls what an export contains.
+type Options struct {
+	// IncludeEmbeddings adds each note's embedding as an array of floats.
+	// Notes must then be loaded with their embeddings.
+	IncludeEmbeddings bool
+}
+
+// NewWriter returns a Writer producing format on w.
+func NewWriter(format Format, w io.Writer, opts Options) (Writer, error) {
+	if opts.IncludeEmbeddings && !format.SupportsEmbeddings() {
+		return nil, fmt.Errorf("embeddings can only be exported as jsonl or csv, not %s", format)
+	}
+
+	switch format {
+	case FormatJSONL:
+		return &jsonlWriter{encoder: json.NewEncoder(w), opts: opts}, nil
+	case FormatCSV:
+		return newCSVWriter(w, opts), nil
+	case FormatMarkdown:
+		return &markdownWriter{w: w}, nil
+	case FormatHTML:
+		return &htmlWriter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+// noteRecord is the JSON form of an exported note. Field names match the API
+// responses so exports can be read back with 'synapse import'.
+type noteRecord struct {
+	ID             int             `json:"id"`
+	Content        string          `json:"content"`
+	URL            string          `json:"url,omitempty"`
+	Title          string          `json:"title,omitempty"`
+	SiteName       string          `json:"site_name,omitempty"`
+	Author         string          `json:"author,omitempty"`
+	Metadata       json.RawMessage `json:"metadata,omitempty"`
+	Tags           []string        `json:"tags"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	EmbeddingModel string          `json:"embedding_model,omitempty"`
+	Embedding      []float64       `json:"embedding,omitempty"`
+}
+
+func toRecord(note database.Note, opts Options) (noteRecord, error) {
+	record := noteRecord{
+		ID:        note.Id,
+		Content:   note.Content,
+		URL:       note.SourceURL,
+		Title:     note.Title,
+		SiteName:  note.SiteName,
+		Author:    note.Author,
+		Tags:      note.Tags,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+	}
+	if record.Tags == nil {
+		record.Tags = []string{}
+	}
+	if note.Metadata != "" && note.Metadata != "{}" {
+		record.Metadata = json.RawMessage(note.Metadata)
+	}
+	if opts.IncludeEmbeddings && len(note.EmbeddingVector) > 0 {
+		embedding, err := database.DecodeVector(note.EmbeddingVector)
+		if err != nil {
+			return record, fmt.Errorf("note %d: %w", note.Id, err)
+		}
+		record.EmbeddingModel = note.EmbeddingModel
+		record.Embedding = embedding
+	}
+	return record, nil
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+	opts    Options
+}
+
+func (jw *jsonlWriter) Write(note database.Note) error {
+	record, err := toRecord(note, jw.opts)
+	if err != nil {
+		return err
+	}
+	return jw.encoder.Encode(record)
+}
+
+func (jw *jsonlWriter) Close() error {
+	return nil
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	opts          Options
+	headerWritten bool
+}
+
+func newCSVWriter(w io.Writer, opts Options) *csvWriter {
+	return &csvWriter{writer: csv.NewWriter(w), opts: opts}
+}
+
+func (cw *csvWriter) writeHeader() error {
+	if cw.headerWritten {
+		return nil
+	}
+	cw.headerWritten = true
+
+	header := []string{"id", "title", "url", "site_name", "author", "tags", "created_at", "updated_at", "metadata", "content"}
+	if cw.opts.IncludeEmbeddings {
+		header = append(header, "embedding_model", "embedding")
+	}
+	return cw.writer.Write(header)
+}
+
+func (cw *csvWriter) Write(note database.Note) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+
+	record, err := toRecord(note, cw.opts)
+	if err != nil {
+		return err
+	}
+	row := []string{
+		strconv.Itoa(record.ID),
+		record.Title,
+		record.URL,
+		record.SiteName,
+		record.Author,
+		strings.Join(record.Tags, ","),
+		record.CreatedAt.Format(time.RFC3339),
+		record.UpdatedAt.Format(time.RFC3339),
+		string(record.Metadata),
+		record.Content,
+	}
+	if cw.opts.IncludeEmbeddings {
+		embedding, err := json.Marshal(record.Embedding)
+		if err != nil {
+			return err
+		}
+		row = append(row, record.EmbeddingModel, string(embedding))
+	}
+	return cw.writer.Write(row)
+}
+
+func (cw *csvWriter) Close() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
@@ -0,0 +1,214 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"synapse/database"
+	"synapse/importer"
+	"testing"
+	"time"
+)
+
+var created = time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+
+// testNotes returns one note using every field and one using none.
+func testNotes(t *testing.T) []database.Note {
+	t.Helper()
+	vector, err := database.EncodeVector([]float64{0.5, -1}, database.VectorFloat32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []database.Note{
+		{
+			Id:              1,
+			Content:         "Pods group <containers>.\n",
+			SourceURL:       "https://example.com/pods",
+			Title:           "Pod basics",
+			SiteName:        "Example",
+			Author:          "Ada",
+			Metadata:        `{"rating":4,"source":"web"}`,
+			Tags:            []string{"kubernetes", "ops"},
+			CreatedAt:       created,
+			UpdatedAt:       created,
+			EmbeddingVector: vector,
+			EmbeddingModel:  "m",
+		},
+		{Id: 2, Content: "Untitled thought.", CreatedAt: created, UpdatedAt: created},
+	}
+}
+
+// export writes notes in format and returns the output.
+func export(t *testing.T, format Format, opts Options, notes []database.Note) string {
+	t.Helper()
+	var out bytes.Buffer
+	writer, err := NewWriter(format, &out, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, note := range notes {
+		if err := writer.Write(note); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+// sameNote reports how an imported note differs from the exported one.
+func sameNote(imported, exported database.Note) error {
+	got := fmt.Sprintf("%q %q %q %q %q %q", strings.TrimSpace(imported.Content), imported.Title, imported.SourceURL, imported.SiteName, imported.Author, imported.Tags)
+	want := fmt.Sprintf("%q %q %q %q %q %q", strings.TrimSpace(exported.Content), exported.Title, exported.SourceURL, exported.SiteName, exported.Author, exported.Tags)
+	if got != want {
+		return fmt.Errorf("imported %s, want %s", got, want)
+	}
+	return nil
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{"jsonl": FormatJSONL, "MD": FormatMarkdown, "csv": FormatCSV, "html": FormatHTML} {
+		if got, err := ParseFormat(name); got != want || err != nil {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("pdf"); err == nil {
+		t.Error("ParseFormat accepted pdf")
+	}
+}
+
+func TestEmbeddingsOnlyInDataFormats(t *testing.T) {
+	for _, format := range []Format{FormatJSONL, FormatCSV, FormatMarkdown, FormatHTML} {
+		_, err := NewWriter(format, &bytes.Buffer{}, Options{IncludeEmbeddings: true})
+		if (err == nil) != format.SupportsEmbeddings() {
+			t.Errorf("%s with embeddings: error = %v", format, err)
+		}
+	}
+}
+
+func TestJSONLRoundTrip(t *testing.T) {
+	notes := testNotes(t)
+	out := export(t, FormatJSONL, Options{IncludeEmbeddings: true}, notes)
+	if !strings.Contains(out, `"embedding":[0.5,-1]`) || !strings.Contains(out, `"embedding_model":"m"`) {
+		t.Errorf("export lacks the embedding:\n%s", out)
+	}
+
+	var imported []database.Note
+	err := importer.Read("export.jsonl", []byte(out), importer.FormatJSONL, func(record importer.Record) error {
+		if record.Err != nil {
+			return record.Err
+		}
+		imported = append(imported, record.Note)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != len(notes) {
+		t.Fatalf("imported %d notes, want %d", len(imported), len(notes))
+	}
+	for i := range notes {
+		if err := sameNote(imported[i], notes[i]); err != nil {
+			t.Errorf("note %d: %v", notes[i].Id, err)
+		}
+	}
+	// Exported bookkeeping such as ids and embeddings is not carried over as
+	// metadata.
+	if imported[0].Metadata != notes[0].Metadata || imported[1].Metadata != "" {
+		t.Errorf("imported metadata = %q, %q; want %q and none", imported[0].Metadata, imported[1].Metadata, notes[0].Metadata)
+	}
+}
+
+func TestDirectoryRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	notes := testNotes(t)
+	writer, err := NewDirectoryWriter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, note := range notes {
+		if err := writer.Write(note); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	imported := map[string]database.Note{}
+	err = importer.Walk(dir, importer.FormatMarkdown, func(record importer.Record) error {
+		if record.Err != nil {
+			return record.Err
+		}
+		imported[record.Source[len(dir)+1:]] = record.Note
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, ok := imported["pod-basics-1.md"]
+	if !ok {
+		t.Fatalf("imported files = %v, want pod-basics-1.md", imported)
+	}
+	if err := sameNote(first, notes[0]); err != nil {
+		t.Error(err)
+	}
+	// A metadata key the importer reads as a note field is dropped so it
+	// cannot replace the URL.
+	if first.Metadata != `{"created":"2024-03-01T09:30:00Z","rating":4}` {
+		t.Errorf("metadata = %s, want rating and the creation date", first.Metadata)
+	}
+
+	// Without a title the file is named after the id, and the title after
+	// the file when read back.
+	second, ok := imported["note-2.md"]
+	if !ok {
+		t.Fatalf("imported files = %v, want note-2.md", imported)
+	}
+	if second.Content != notes[1].Content || second.Title != "note-2" {
+		t.Errorf("untitled note imported as %+v", second)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	rows, err := csv.NewReader(strings.NewReader(export(t, FormatCSV, Options{IncludeEmbeddings: true}, testNotes(t)))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want a header and two notes", len(rows))
+	}
+	record := map[string]string{}
+	for i, column := range rows[0] {
+		record[column] = rows[1][i]
+	}
+	if record["tags"] != "kubernetes,ops" || record["content"] != "Pods group <containers>.\n" || record["embedding"] != "[0.5,-1]" || record["created_at"] != "2024-03-01T09:30:00Z" {
+		t.Errorf("first row = %v", record)
+	}
+
+	// An empty export still has its header.
+	if out := export(t, FormatCSV, Options{}, nil); !strings.HasPrefix(out, "id,title,") {
+		t.Errorf("empty CSV export = %q, want a header", out)
+	}
+}
+
+func TestReadableFormats(t *testing.T) {
+	notes := testNotes(t)
+
+	markdown := export(t, FormatMarkdown, Options{}, notes)
+	for _, want := range []string{"# Pod basics\n", "- URL: <https://example.com/pods>\n", "- Tags: kubernetes, ops\n", "# Note 2\n"} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("markdown export lacks %q:\n%s", want, markdown)
+		}
+	}
+
+	html := export(t, FormatHTML, Options{}, notes)
+	for _, want := range []string{"<!DOCTYPE html>", `<article id="note-1">`, "Pods group &lt;containers&gt;.", `<span class="tag">ops</span>`, "<h2>Note 2</h2>", "</html>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML export lacks %q:\n%s", want, html)
+		}
+	}
+	if empty := export(t, FormatHTML, Options{}, nil); !strings.HasPrefix(empty, "<!DOCTYPE html>") || !strings.HasSuffix(empty, "</html>\n") {
+		t.Errorf("empty HTML export = %q, want a complete page", empty)
+	}
+}
@@ -0,0 +1,72 @@
+package exporter
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"synapse/database"
+)
+
+var htmlHeader = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Synapse notes</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 50rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; }
+article { border-bottom: 1px solid #ddd; padding: 1rem 0; }
+.meta { color: #666; font-size: 0.9rem; }
+.tag { background: #eef; border-radius: 0.25rem; padding: 0 0.3rem; margin-right: 0.3rem; }
+.content { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Synapse notes</h1>
+`
+
+var htmlFooter = `</body>
+</html>
+`
+
+var htmlNote = template.Must(template.New("note").Parse(`<article id="note-{{.Id}}">
+<h2>{{if .Title}}{{.Title}}{{else}}Note {{.Id}}{{end}}</h2>
+<p class="meta">#{{.Id}} &middot; {{.CreatedAt.Format "2006-01-02 15:04"}}
+{{- if .Author}} &middot; {{.Author}}{{end}}
+{{- if .SourceURL}} &middot; <a href="{{.SourceURL}}">{{if .SiteName}}{{.SiteName}}{{else}}source{{end}}</a>{{end}}</p>
+{{- if .Tags}}
+<p>{{range .Tags}}<span class="tag">{{.}}</span>{{end}}</p>
+{{- end}}
+<div class="content">{{.Content}}</div>
+</article>
+`))
+
+// htmlWriter produces a standalone page listing every note.
+type htmlWriter struct {
+	w             io.Writer
+	headerWritten bool
+}
+
+func (hw *htmlWriter) Write(note database.Note) error {
+	if err := hw.writeHeader(); err != nil {
+		return err
+	}
+	note.Content = strings.TrimSpace(note.Content)
+	return htmlNote.Execute(hw.w, note)
+}
+
+func (hw *htmlWriter) Close() error {
+	if err := hw.writeHeader(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(hw.w, htmlFooter)
+	return err
+}
+
+func (hw *htmlWriter) writeHeader() error {
+	if hw.headerWritten {
+		return nil
+	}
+	hw.headerWritten = true
+	_, err := io.WriteString(hw.w, htmlHeader)
+	return err
+}
@@ -0,0 +1,165 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"synapse/database"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// markdownWriter renders every note as a section of a single document.
+type markdownWriter struct {
+	w io.Writer
+}
+
+func (mw *markdownWriter) Write(note database.Note) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", noteTitle(note))
+	fmt.Fprintf(&b, "- ID: %d\n", note.Id)
+	if note.SourceURL != "" {
+		fmt.Fprintf(&b, "- URL: <%s>\n", note.SourceURL)
+	}
+	if note.Author != "" {
+		fmt.Fprintf(&b, "- Author: %s\n", note.Author)
+	}
+	if note.SiteName != "" {
+		fmt.Fprintf(&b, "- Site: %s\n", note.SiteName)
+	}
+	if len(note.Tags) > 0 {
+		fmt.Fprintf(&b, "- Tags: %s\n", strings.Join(note.Tags, ", "))
+	}
+	fmt.Fprintf(&b, "- Created: %s\n", note.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "\n%s\n\n---\n\n", strings.TrimSpace(note.Content))
+
+	_, err := io.WriteString(mw.w, b.String())
+	return err
+}
+
+func (mw *markdownWriter) Close() error {
+	return nil
+}
+
+func noteTitle(note database.Note) string {
+	if note.Title != "" {
+		return note.Title
+	}
+	return fmt.Sprintf("Note %d", note.Id)
+}
+
+// DirectoryWriter writes each note to its own Markdown file with YAML
+// front-matter, in the layout 'synapse import --format markdown' reads, so a
+// knowledge base can be moved to tools like Obsidian and back.
+type DirectoryWriter struct {
+	dir string
+}
+
+func NewDirectoryWriter(dir string) (*DirectoryWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DirectoryWriter{dir: dir}, nil
+}
+
+// Front-matter keys written from note fields, or read into them on import.
+// Metadata keys with the same names are dropped rather than overwrite them.
+var reservedFrontMatterKeys = map[string]bool{
+	"title": true, "url": true, "site_name": true, "author": true, "tags": true,
+	"source": true, "source_url": true,
+}
+
+func (dw *DirectoryWriter) Write(note database.Note) error {
+	frontMatter := yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, value any) error {
+		var node yaml.Node
+		if err := node.Encode(value); err != nil {
+			return err
+		}
+		frontMatter.Content = append(frontMatter.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &node)
+		return nil
+	}
+
+	fields := []struct {
+		key   string
+		value string
+	}{
+		{"title", note.Title},
+		{"url", note.SourceURL},
+		{"site_name", note.SiteName},
+		{"author", note.Author},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		if err := add(field.key, field.value); err != nil {
+			return err
+		}
+	}
+	if len(note.Tags) > 0 {
+		if err := add("tags", note.Tags); err != nil {
+			return err
+		}
+	}
+
+	var metadata map[string]any
+	if note.Metadata != "" {
+		if err := json.Unmarshal([]byte(note.Metadata), &metadata); err != nil {
+			return fmt.Errorf("note %d: invalid metadata: %w", note.Id, err)
+		}
+	}
+	// Keep a creation date carried over from an earlier import.
+	if _, ok := metadata["created"]; !ok {
+		if err := add("created", note.CreatedAt.Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	keys := slices.Sorted(maps.Keys(metadata))
+	for _, key := range keys {
+		if reservedFrontMatterKeys[key] {
+			continue
+		}
+		if err := add(key, metadata[key]); err != nil {
+			return err
+		}
+	}
+
+	var body bytes.Buffer
+	body.WriteString("---\n")
+	encoder := yaml.NewEncoder(&body)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&frontMatter); err != nil {
+		return err
+	}
+	encoder.Close()
+	fmt.Fprintf(&body, "---\n\n%s\n", strings.TrimSpace(note.Content))
+	return os.WriteFile(filepath.Join(dw.dir, noteFileName(note)), body.Bytes(), 0o644)
+}
+
+func (dw *DirectoryWriter) Close() error {
+	return nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// noteFileName builds a readable file name from the note title, suffixed
+// with the note ID so it is unique.
+func noteFileName(note database.Note) string {
+	slug := strings.Trim(unsafeFileChars.ReplaceAllString(strings.ToLower(note.Title), "-"), "-")
+	if len([]rune(slug)) > 60 {
+		slug = strings.TrimRight(string([]rune(slug)[:60]), "-")
+	}
+	if slug == "" {
+		return fmt.Sprintf("note-%d.md", note.Id)
+	}
+	return fmt.Sprintf("%s-%d.md", slug, note.Id)
+}
@@ -0,0 +1,260 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"synapse/database"
+)
+
+type Format string
+
+const (
+	FormatAuto     Format = ""
+	FormatMarkdown Format = "markdown"
+	FormatJSON     Format = "json"
+	FormatJSONL    Format = "jsonl"
+	FormatText     Format = "txt"
+)
+
+// maxLineSize bounds a single JSONL record.
+const maxLineSize = 16 * 1024 * 1024
+
+var extensionFormats = map[string]Format{
+	".md":       FormatMarkdown,
+	".markdown": FormatMarkdown,
+	".json":     FormatJSON,
+	".jsonl":    FormatJSONL,
+	".ndjson":   FormatJSONL,
+	".txt":      FormatText,
+}
+
+// ParseFormat accepts markdown, json, jsonl or txt. An empty name means the
+// format is picked per file from its extension.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatAuto, FormatMarkdown, FormatJSON, FormatJSONL, FormatText:
+		return format, nil
+	case "md":
+		return FormatMarkdown, nil
+	}
+	return "", fmt.Errorf("unknown import format %q: expected markdown, json, jsonl or txt", name)
+}
+
+// Record is one note read from an import source, or the error that prevented
+// reading it. Source names the file, with a line or element number for
+// formats that hold several notes per file.
+type Record struct {
+	Source string
+	Note   database.Note
+	Err    error
+}
+
+// Walk reads notes from path, which may be a single file or a directory that
+// is walked recursively, and passes each to fn in file order. With FormatAuto,
+// files with unrecognised extensions are ignored; with an explicit format,
+// only files of that format's extensions are read from directories. Hidden
+// files and directories, such as .obsidian or .git, are skipped.
+func Walk(path string, format Format, fn func(Record) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		fileFormat := format
+		if fileFormat == FormatAuto {
+			fileFormat = extensionFormats[strings.ToLower(filepath.Ext(path))]
+			if fileFormat == FormatAuto {
+				return fmt.Errorf("cannot tell the format of %s from its extension; use --format", path)
+			}
+		}
+		return readFile(path, fileFormat, fn)
+	}
+
+	return filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return fn(Record{Source: file, Err: err})
+		}
+		if strings.HasPrefix(entry.Name(), ".") && file != path {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		fileFormat := extensionFormats[strings.ToLower(filepath.Ext(file))]
+		if fileFormat == FormatAuto || (format != FormatAuto && fileFormat != format) {
+			return nil
+		}
+		return readFile(file, fileFormat, fn)
+	})
+}
+
+func readFile(path string, format Format, fn func(Record) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fn(Record{Source: path, Err: err})
+	}
+	return Read(path, data, format, fn)
+}
+
+// Read parses data, which came from path, in the given format and passes
+// each note to fn. FormatAuto is not accepted, since there is no extension
+// to go by.
+func Read(path string, data []byte, format Format, fn func(Record) error) error {
+	switch format {
+	case FormatMarkdown:
+		note, err := parseMarkdown(path, data)
+		return fn(Record{Source: path, Note: note, Err: err})
+	case FormatText:
+		note := database.Note{Content: string(data), Title: fileTitle(path)}
+		return fn(Record{Source: path, Note: note})
+	case FormatJSON:
+		return readJSON(path, data, fn)
+	case FormatJSONL:
+		return readJSONL(path, data, fn)
+	}
+	return fmt.Errorf("unsupported import format %q", format)
+}
+
+// readJSON accepts either a single note object or an array of them.
+func readJSON(path string, data []byte, fn func(Record) error) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] != '[' {
+		note, err := parseJSONNote(trimmed)
+		return fn(Record{Source: path, Note: note, Err: err})
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(trimmed, &elements); err != nil {
+		return fn(Record{Source: path, Err: fmt.Errorf("invalid JSON: %w", err)})
+	}
+	for i, element := range elements {
+		note, err := parseJSONNote(element)
+		if err := fn(Record{Source: fmt.Sprintf("%s[%d]", path, i), Note: note, Err: err}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readJSONL(path string, data []byte, fn func(Record) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		note, err := parseJSONNote(raw)
+		if err := fn(Record{Source: fmt.Sprintf("%s:%d", path, line), Note: note, Err: err}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fn(Record{Source: fmt.Sprintf("%s:%d", path, line+1), Err: err})
+	}
+	return nil
+}
+
+// jsonNoteFields lists the fields understood in JSON imports, matching the note
+// objects returned by the API. Any other field is kept in the metadata.
+var jsonNoteFields = map[string]bool{
+	"content": true, "text": true, "body": true,
+	"url": true, "source_url": true, "title": true, "site_name": true, "author": true,
+	"tags": true, "metadata": true,
+	"id": true, "created_at": true, "updated_at": true,
+	"distance": true, "score": true, "snippet": true,
+	"embedding_model": true, "embedding_dim": true, "embedding": true,
+}
+
+func parseJSONNote(raw []byte) (database.Note, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return database.Note{}, fmt.Errorf("invalid JSON note: %w", err)
+	}
+	if fields == nil {
+		return database.Note{}, errors.New("invalid JSON note: expected an object")
+	}
+
+	note := database.Note{
+		Content:   firstString(fields, "content", "text", "body"),
+		SourceURL: firstString(fields, "url", "source_url"),
+		Title:     firstString(fields, "title"),
+		SiteName:  firstString(fields, "site_name"),
+		Author:    firstString(fields, "author"),
+		Tags:      stringList(fields["tags"]),
+	}
+
+	metadata := map[string]any{}
+	if nested, ok := fields["metadata"].(map[string]any); ok {
+		metadata = nested
+	}
+	for key, value := range fields {
+		if !jsonNoteFields[key] {
+			metadata[key] = value
+		}
+	}
+	var err error
+	note.Metadata, err = encodeMetadata(metadata)
+	return note, err
+}
+
+func firstString(fields map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := fields[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// stringList accepts a list of strings or a single comma-separated string.
+func stringList(value any) []string {
+	switch v := value.(type) {
+	case string:
+		var list []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, strings.TrimPrefix(item, "#"))
+			}
+		}
+		return list
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				// Obsidian accepts tags written with their leading #.
+				list = append(list, strings.TrimPrefix(s, "#"))
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func encodeMetadata(metadata map[string]any) (string, error) {
+	if len(metadata) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("invalid metadata: %w", err)
+	}
+	return string(raw), nil
+}
+
+func fileTitle(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
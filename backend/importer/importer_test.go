@@ -0,0 +1,194 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readAll returns every record Read passes on for data.
+func readAll(t *testing.T, path string, data string, format Format) []Record {
+	t.Helper()
+	var records []Record
+	err := Read(path, []byte(data), format, func(record Record) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	return records
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Format
+		wantErr bool
+	}{
+		{"", FormatAuto, false},
+		{"Markdown", FormatMarkdown, false},
+		{"md", FormatMarkdown, false},
+		{"json", FormatJSON, false},
+		{"JSONL", FormatJSONL, false},
+		{"txt", FormatText, false},
+		{"csv", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.name)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestReadMarkdown(t *testing.T) {
+	records := readAll(t, "vault/k8s.md", `---
+title: Pod basics
+tags: ["#kubernetes", ops]
+source: https://example.com/pods
+author: Ada
+date: 2024-03-01
+rating: 4
+---
+# Ignored heading
+
+Pods group containers.
+`, FormatMarkdown)
+	note := records[0].Note
+	if records[0].Err != nil {
+		t.Fatal(records[0].Err)
+	}
+	if note.Title != "Pod basics" || note.SourceURL != "https://example.com/pods" || note.Author != "Ada" {
+		t.Errorf("front-matter fields not applied: %+v", note)
+	}
+	if strings.Join(note.Tags, " ") != "kubernetes ops" {
+		t.Errorf("tags = %q, want kubernetes and ops without the #", note.Tags)
+	}
+	if note.Metadata != `{"date":"2024-03-01","rating":4}` {
+		t.Errorf("metadata = %s, want the remaining front-matter keys", note.Metadata)
+	}
+	if note.Content != "# Ignored heading\n\nPods group containers." {
+		t.Errorf("content = %q, want the body without front-matter", note.Content)
+	}
+
+	titles := map[string]string{
+		"Intro\n\n# From heading\nText.": "From heading",
+		"No heading here.":               "plain",
+		"---\ntags: a, #b\n---\nBody.":   "plain",
+	}
+	for data, want := range titles {
+		note := readAll(t, "dir/plain.md", data, FormatMarkdown)[0].Note
+		if note.Title != want {
+			t.Errorf("title of %q = %q, want %q", data, note.Title, want)
+		}
+	}
+
+	for _, data := range []string{"---\ntitle: x\nNo closing line.", "---\n: [bad\n---\nBody."} {
+		if err := readAll(t, "bad.md", data, FormatMarkdown)[0].Err; err == nil {
+			t.Errorf("invalid front-matter %q was accepted", data)
+		}
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	object := readAll(t, "note.json", `{"text": "Hello", "url": "https://a", "tags": "x, y", "stars": 3}`, FormatJSON)
+	if len(object) != 1 || object[0].Err != nil {
+		t.Fatalf("single object records = %+v", object)
+	}
+	note := object[0].Note
+	if note.Content != "Hello" || note.SourceURL != "https://a" || strings.Join(note.Tags, " ") != "x y" || note.Metadata != `{"stars":3}` {
+		t.Errorf("single object note = %+v", note)
+	}
+
+	array := readAll(t, "notes.json", `[{"content": "one"}, 5, {"content": "two", "metadata": {"k": "v"}}]`, FormatJSON)
+	if len(array) != 3 {
+		t.Fatalf("array records = %+v, want 3", array)
+	}
+	if array[0].Note.Content != "one" || array[0].Source != "notes.json[0]" {
+		t.Errorf("first element = %+v", array[0])
+	}
+	if array[1].Err == nil {
+		t.Error("a non-object element was accepted")
+	}
+	if array[2].Note.Metadata != `{"k":"v"}` {
+		t.Errorf("nested metadata = %s", array[2].Note.Metadata)
+	}
+
+	if records := readAll(t, "broken.json", `[{"content": `, FormatJSON); len(records) != 1 || records[0].Err == nil {
+		t.Errorf("truncated JSON records = %+v, want one error", records)
+	}
+}
+
+func TestReadJSONL(t *testing.T) {
+	records := readAll(t, "notes.jsonl", "{\"content\": \"one\"}\n\nnot json\n{\"content\": \"two\"}\n", FormatJSONL)
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3 with the blank line skipped", len(records))
+	}
+	if records[0].Note.Content != "one" || records[2].Note.Content != "two" {
+		t.Errorf("records = %+v", records)
+	}
+	// A bad line is reported with its line number and does not stop the rest.
+	if records[1].Err == nil || records[1].Source != "notes.jsonl:3" {
+		t.Errorf("bad line record = %+v, want an error from notes.jsonl:3", records[1])
+	}
+}
+
+func TestWalk(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.md":                "# A\nAlpha.",
+		"sub/b.txt":           "Beta.",
+		"sub/c.jsonl":         "{\"content\": \"Gamma\"}\n",
+		"sub/image.png":       "not a note",
+		".hidden.md":          "Hidden.",
+		".obsidian/config.md": "Settings.",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	walk := func(path string, format Format) ([]string, error) {
+		var contents []string
+		err := Walk(path, format, func(record Record) error {
+			if record.Err != nil {
+				return record.Err
+			}
+			contents = append(contents, record.Note.Content)
+			return nil
+		})
+		return contents, err
+	}
+
+	contents, err := walk(dir, FormatAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(contents, " ") != "# A\nAlpha. Beta. Gamma" {
+		t.Errorf("auto walk read %q, want a.md, b.txt and c.jsonl only", contents)
+	}
+
+	contents, err = walk(dir, FormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(contents, " ") != "Beta." {
+		t.Errorf("txt walk read %q, want b.txt only", contents)
+	}
+
+	// A single file is read in the given format whatever its extension.
+	contents, err = walk(filepath.Join(dir, "sub/image.png"), FormatText)
+	if err != nil || strings.Join(contents, " ") != "not a note" {
+		t.Errorf("explicit format file read %q, %v", contents, err)
+	}
+	if _, err := walk(filepath.Join(dir, "sub/image.png"), FormatAuto); err == nil {
+		t.Error("a file with an unknown extension was read without a format")
+	}
+}
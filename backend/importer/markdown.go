@@ -0,0 +1,120 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"synapse/database"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Front-matter keys mapped onto note fields. Every other key is kept in the
+// note's metadata.
+var frontMatterFields = map[string]bool{
+	"title": true, "tags": true, "url": true, "source": true, "source_url": true,
+	"site_name": true, "author": true,
+}
+
+// parseMarkdown reads an optional YAML front-matter block delimited by ---
+// lines, then uses the rest of the file as the note content. Without a title
+// in the front-matter, the first level-one heading or the file name is used.
+func parseMarkdown(path string, data []byte) (database.Note, error) {
+	frontMatter, body, err := splitFrontMatter(data)
+	if err != nil {
+		return database.Note{}, err
+	}
+
+	fields := map[string]any{}
+	if len(frontMatter) > 0 {
+		if err := yaml.Unmarshal(frontMatter, &fields); err != nil {
+			return database.Note{}, fmt.Errorf("invalid front-matter: %w", err)
+		}
+	}
+
+	note := database.Note{
+		Content:   strings.TrimSpace(string(body)),
+		Title:     firstString(fields, "title"),
+		SourceURL: firstString(fields, "url", "source_url", "source"),
+		SiteName:  firstString(fields, "site_name"),
+		Author:    firstString(fields, "author"),
+		Tags:      stringList(fields["tags"]),
+	}
+	if note.Title == "" {
+		note.Title = firstHeading(note.Content)
+	}
+	if note.Title == "" {
+		note.Title = fileTitle(path)
+	}
+
+	metadata := map[string]any{}
+	for key, value := range fields {
+		if !frontMatterFields[key] {
+			metadata[key] = jsonValue(value)
+		}
+	}
+	note.Metadata, err = encodeMetadata(metadata)
+	return note, err
+}
+
+func splitFrontMatter(data []byte) ([]byte, []byte, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !bytes.HasPrefix(data, []byte("---\n")) && !bytes.HasPrefix(data, []byte("---\r\n")) {
+		return nil, data, nil
+	}
+
+	rest := data[bytes.IndexByte(data, '\n')+1:]
+	for offset := 0; offset < len(rest); {
+		end := bytes.IndexByte(rest[offset:], '\n')
+		line := rest[offset:]
+		next := len(rest)
+		if end >= 0 {
+			line = rest[offset : offset+end]
+			next = offset + end + 1
+		}
+		if strings.TrimRight(string(line), "\r") == "---" {
+			return rest[:offset], rest[next:], nil
+		}
+		offset = next
+	}
+	return nil, nil, fmt.Errorf("front-matter is not closed with ---")
+}
+
+func firstHeading(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if heading, ok := strings.CutPrefix(strings.TrimSpace(line), "# "); ok {
+			return strings.TrimSpace(heading)
+		}
+	}
+	return ""
+}
+
+// jsonValue converts values decoded from YAML into ones encoding/json can
+// marshal, as YAML allows non-string map keys.
+func jsonValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = jsonValue(item)
+		}
+		return v
+	case map[any]any:
+		converted := make(map[string]any, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return converted
+	case []any:
+		for i, item := range v {
+			v[i] = jsonValue(item)
+		}
+		return v
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339)
+	}
+	return value
+}
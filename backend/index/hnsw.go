@@ -0,0 +1,483 @@
+package index
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+const (
+	DEFAULT_M               = 16
+	DEFAULT_EF_CONSTRUCTION = 200
+	DEFAULT_EF_SEARCH       = 64
+
+	fileFormatVersion = 1
+)
+
+var ErrDimensionMismatch = errors.New("vector dimension does not match index")
+
+// Result is a single nearest-neighbour hit. Distance is cosine distance, the
+// same measure computed by the vector_distance SQL function.
+type Result struct {
+	Id       int
+	Distance float64
+}
+
+type node struct {
+	id      int
+	level   int
+	vector  []float32
+	friends [][]int
+}
+
+// HNSW is a Hierarchical Navigable Small World graph over unit-normalised
+// vectors. It is safe for concurrent use.
+type HNSW struct {
+	mu sync.RWMutex
+
+	m              int
+	efConstruction int
+	efSearch       int
+	levelMult      float64
+	dim            int
+
+	nodes    map[int]*node
+	entry    int
+	maxLevel int
+	rng      *rand.Rand
+}
+
+func New() *HNSW {
+	return &HNSW{
+		m:              DEFAULT_M,
+		efConstruction: DEFAULT_EF_CONSTRUCTION,
+		efSearch:       DEFAULT_EF_SEARCH,
+		levelMult:      1 / math.Log(DEFAULT_M),
+		nodes:          make(map[int]*node),
+		entry:          -1,
+		rng:            rand.New(rand.NewSource(rand.Int63())),
+	}
+}
+
+func (h *HNSW) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.nodes)
+}
+
+func (h *HNSW) Dim() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.dim
+}
+
+// MaxID returns the largest id stored in the index, or 0 if it is empty.
+func (h *HNSW) MaxID() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	maxID := 0
+	for id := range h.nodes {
+		maxID = max(maxID, id)
+	}
+	return maxID
+}
+
+// Add inserts or replaces the vector stored under id.
+func (h *HNSW) Add(id int, vector []float64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.dim != 0 && len(vector) != h.dim {
+		return fmt.Errorf("%w: got %d, want %d", ErrDimensionMismatch, len(vector), h.dim)
+	}
+	level := h.randomLevel()
+	if old, ok := h.nodes[id]; ok {
+		// Nodes that linked to id without being linked back still point at
+		// it on every level it had, so the replacement keeps them all.
+		level = old.level
+		h.remove(id)
+	}
+	if len(h.nodes) == 0 {
+		h.dim = len(vector)
+	}
+
+	n := &node{
+		id:     id,
+		level:  level,
+		vector: normalize(vector),
+	}
+	n.friends = make([][]int, n.level+1)
+	h.nodes[id] = n
+
+	if h.entry < 0 {
+		h.entry = id
+		h.maxLevel = n.level
+		return nil
+	}
+
+	current := h.entry
+	currentDist := h.distance(n.vector, h.nodes[current].vector)
+	for level := h.maxLevel; level > n.level; level-- {
+		current, currentDist = h.greedyClosest(n.vector, current, currentDist, level)
+	}
+
+	entryPoints := []Result{{Id: current, Distance: currentDist}}
+	for level := min(n.level, h.maxLevel); level >= 0; level-- {
+		candidates := h.searchLayer(n.vector, entryPoints, h.efConstruction, level)
+		neighbours := h.selectNeighbours(candidates, h.maxFriends(level))
+
+		n.friends[level] = make([]int, 0, len(neighbours))
+		for _, neighbour := range neighbours {
+			n.friends[level] = append(n.friends[level], neighbour.Id)
+			h.link(neighbour.Id, id, level)
+		}
+		entryPoints = candidates
+	}
+
+	if n.level > h.maxLevel {
+		h.maxLevel = n.level
+		h.entry = id
+	}
+	return nil
+}
+
+// Remove deletes id from the graph, reconnecting its former neighbours.
+func (h *HNSW) Remove(id int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(id)
+}
+
+func (h *HNSW) remove(id int) {
+	n, ok := h.nodes[id]
+	if !ok {
+		return
+	}
+	delete(h.nodes, id)
+
+	// Edges are directed, so nodes that linked to id without being linked
+	// back keep a dangling reference; traversal skips those lazily, along
+	// with references to a later node under the same id that has fewer
+	// levels.
+	for level, friends := range n.friends {
+		for _, friendID := range friends {
+			friend, ok := h.nodes[friendID]
+			if !ok || level >= len(friend.friends) {
+				continue
+			}
+			friend.friends[level] = without(friend.friends[level], id)
+
+			// Offer the removed node's other neighbours as replacements so
+			// the graph stays navigable around the hole.
+			for _, candidate := range friends {
+				if candidate != friendID {
+					h.link(friendID, candidate, level)
+				}
+			}
+		}
+	}
+
+	if len(h.nodes) == 0 {
+		h.entry = -1
+		h.maxLevel = 0
+		h.dim = 0
+		return
+	}
+	if h.entry == id {
+		h.entry = -1
+		h.maxLevel = -1
+		for otherID, other := range h.nodes {
+			if other.level > h.maxLevel {
+				h.entry = otherID
+				h.maxLevel = other.level
+			}
+		}
+	}
+}
+
+// Search returns up to k nearest neighbours of query ordered by distance.
+func (h *HNSW) Search(query []float64, k int) ([]Result, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.entry < 0 || k <= 0 {
+		return nil, nil
+	}
+	if len(query) != h.dim {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrDimensionMismatch, len(query), h.dim)
+	}
+
+	q := normalize(query)
+	current := h.entry
+	currentDist := h.distance(q, h.nodes[current].vector)
+	for level := h.maxLevel; level > 0; level-- {
+		current, currentDist = h.greedyClosest(q, current, currentDist, level)
+	}
+
+	results := h.searchLayer(q, []Result{{Id: current, Distance: currentDist}}, max(h.efSearch, k), 0)
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+func (h *HNSW) randomLevel() int {
+	return int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+}
+
+func (h *HNSW) maxFriends(level int) int {
+	if level == 0 {
+		return 2 * h.m
+	}
+	return h.m
+}
+
+func (h *HNSW) distance(a, b []float32) float64 {
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return 1.0 - float64(dot)
+}
+
+func (h *HNSW) greedyClosest(q []float32, current int, currentDist float64, level int) (int, float64) {
+	for changed := true; changed; {
+		changed = false
+		n := h.nodes[current]
+		if level >= len(n.friends) {
+			break
+		}
+		for _, friendID := range n.friends[level] {
+			friend, ok := h.nodes[friendID]
+			if !ok || level >= len(friend.friends) {
+				continue
+			}
+			if d := h.distance(q, friend.vector); d < currentDist {
+				current, currentDist = friendID, d
+				changed = true
+			}
+		}
+	}
+	return current, currentDist
+}
+
+// searchLayer runs the beam search from the HNSW paper and returns up to ef
+// results sorted by ascending distance.
+func (h *HNSW) searchLayer(q []float32, entryPoints []Result, ef int, level int) []Result {
+	visited := make(map[int]struct{}, ef*4)
+	candidates := &minHeap{}
+	found := &maxHeap{}
+
+	for _, ep := range entryPoints {
+		visited[ep.Id] = struct{}{}
+		heap.Push(candidates, ep)
+		heap.Push(found, ep)
+		if found.Len() > ef {
+			heap.Pop(found)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		closest := heap.Pop(candidates).(Result)
+		if found.Len() >= ef && closest.Distance > (*found)[0].Distance {
+			break
+		}
+
+		n := h.nodes[closest.Id]
+		if level >= len(n.friends) {
+			continue
+		}
+		for _, friendID := range n.friends[level] {
+			if _, seen := visited[friendID]; seen {
+				continue
+			}
+			visited[friendID] = struct{}{}
+
+			friend, ok := h.nodes[friendID]
+			if !ok || level >= len(friend.friends) {
+				continue
+			}
+			d := h.distance(q, friend.vector)
+			if found.Len() < ef || d < (*found)[0].Distance {
+				heap.Push(candidates, Result{Id: friendID, Distance: d})
+				heap.Push(found, Result{Id: friendID, Distance: d})
+				if found.Len() > ef {
+					heap.Pop(found)
+				}
+			}
+		}
+	}
+
+	results := make([]Result, found.Len())
+	for i := len(results) - 1; i >= 0; i-- {
+		results[i] = heap.Pop(found).(Result)
+	}
+	return results
+}
+
+// selectNeighbours keeps the closest candidates, which are already sorted.
+func (h *HNSW) selectNeighbours(candidates []Result, limit int) []Result {
+	if len(candidates) > limit {
+		return candidates[:limit]
+	}
+	return candidates
+}
+
+// link adds a directed edge from -> to at level, pruning from's neighbour
+// list back to its limit by dropping the farthest entries.
+func (h *HNSW) link(from, to, level int) {
+	n := h.nodes[from]
+	if n == nil || level >= len(n.friends) {
+		return
+	}
+	if _, ok := h.nodes[to]; !ok {
+		return
+	}
+	for _, existing := range n.friends[level] {
+		if existing == to {
+			return
+		}
+	}
+	n.friends[level] = append(n.friends[level], to)
+
+	limit := h.maxFriends(level)
+	if len(n.friends[level]) <= limit {
+		return
+	}
+
+	candidates := make([]Result, 0, len(n.friends[level]))
+	for _, friendID := range n.friends[level] {
+		if friend, ok := h.nodes[friendID]; ok {
+			candidates = append(candidates, Result{Id: friendID, Distance: h.distance(n.vector, friend.vector)})
+		}
+	}
+	sortResults(candidates)
+
+	pruned := make([]int, 0, limit)
+	for _, c := range candidates[:min(limit, len(candidates))] {
+		pruned = append(pruned, c.Id)
+	}
+	n.friends[level] = pruned
+}
+
+type fileNode struct {
+	Id      int
+	Level   int
+	Vector  []float32
+	Friends [][]int
+}
+
+type fileFormat struct {
+	Version        int
+	M              int
+	EfConstruction int
+	Dim            int
+	Entry          int
+	MaxLevel       int
+	Nodes          []fileNode
+}
+
+// Save writes the index to path atomically via a temporary file. The graph is
+// copied under the read lock, so Add and Remove may run while it is written.
+func (h *HNSW) Save(path string) error {
+	h.mu.RLock()
+	data := fileFormat{
+		Version:        fileFormatVersion,
+		M:              h.m,
+		EfConstruction: h.efConstruction,
+		Dim:            h.dim,
+		Entry:          h.entry,
+		MaxLevel:       h.maxLevel,
+		Nodes:          make([]fileNode, 0, len(h.nodes)),
+	}
+	for _, n := range h.nodes {
+		// Vectors are never modified once added, but link and remove
+		// rewrite neighbour lists in place.
+		friends := make([][]int, len(n.friends))
+		for level, ids := range n.friends {
+			friends[level] = slices.Clone(ids)
+		}
+		data.Nodes = append(data.Nodes, fileNode{Id: n.id, Level: n.level, Vector: n.vector, Friends: friends})
+	}
+	h.mu.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create index file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write index file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load reads an index previously written by Save.
+func Load(path string) (*HNSW, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var data fileFormat
+	if err := gob.NewDecoder(f).Decode(&data); err != nil {
+		return nil, fmt.Errorf("failed to decode index: %w", err)
+	}
+	if data.Version != fileFormatVersion {
+		return nil, fmt.Errorf("unsupported index file version %d", data.Version)
+	}
+
+	h := New()
+	h.m = data.M
+	h.efConstruction = data.EfConstruction
+	h.levelMult = 1 / math.Log(float64(data.M))
+	h.dim = data.Dim
+	h.entry = data.Entry
+	h.maxLevel = data.MaxLevel
+	for _, fn := range data.Nodes {
+		h.nodes[fn.Id] = &node{id: fn.Id, level: fn.Level, vector: fn.Vector, friends: fn.Friends}
+	}
+	if len(h.nodes) == 0 {
+		h.entry = -1
+	}
+	return h, nil
+}
+
+func normalize(vector []float64) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+
+	out := make([]float32, len(vector))
+	if norm == 0 {
+		return out
+	}
+	for i, v := range vector {
+		out[i] = float32(v / norm)
+	}
+	return out
+}
+
+func without(ids []int, id int) []int {
+	for i, existing := range ids {
+		if existing == id {
+			return append(ids[:i:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,284 @@
+package index
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func randomVectors(rng *rand.Rand, n, dim int) [][]float64 {
+	vectors := make([][]float64, n)
+	for i := range vectors {
+		vectors[i] = make([]float64, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = rng.NormFloat64()
+		}
+	}
+	return vectors
+}
+
+func cosineDistance(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	return 1 - dot/math.Sqrt(normA*normB)
+}
+
+// bruteForce returns the ids of the k vectors closest to query, skipping
+// removed ids. Ids are the vectors' positions.
+func bruteForce(vectors [][]float64, removed map[int]bool, query []float64, k int) []int {
+	var results []Result
+	for id, vector := range vectors {
+		if !removed[id] {
+			results = append(results, Result{Id: id, Distance: cosineDistance(query, vector)})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Distance < results[j].Distance })
+
+	ids := make([]int, 0, k)
+	for _, r := range results[:min(k, len(results))] {
+		ids = append(ids, r.Id)
+	}
+	return ids
+}
+
+// recall returns the share of the exact nearest neighbours that h finds.
+func recall(t *testing.T, h *HNSW, vectors [][]float64, removed map[int]bool, queries [][]float64, k int) float64 {
+	t.Helper()
+	found, total := 0, 0
+	for _, query := range queries {
+		results, err := h.Search(query, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[int]bool, len(results))
+		for _, r := range results {
+			if removed[r.Id] {
+				t.Fatalf("Search returned removed id %d", r.Id)
+			}
+			got[r.Id] = true
+		}
+		for _, id := range bruteForce(vectors, removed, query, k) {
+			if got[id] {
+				found++
+			}
+			total++
+		}
+	}
+	return float64(found) / float64(total)
+}
+
+func buildIndex(t *testing.T, vectors [][]float64) *HNSW {
+	t.Helper()
+	h := New()
+	for id, vector := range vectors {
+		if err := h.Add(id, vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return h
+}
+
+func TestSearchRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vectors := randomVectors(rng, 1000, 32)
+	queries := randomVectors(rng, 50, 32)
+	h := buildIndex(t, vectors)
+
+	if got := h.Len(); got != len(vectors) {
+		t.Fatalf("Len = %d, want %d", got, len(vectors))
+	}
+	if r := recall(t, h, vectors, nil, queries, 10); r < 0.9 {
+		t.Errorf("recall@10 = %.2f, want at least 0.9", r)
+	}
+}
+
+func TestSearchOrdersByDistance(t *testing.T) {
+	h := New()
+	vectors := map[int][]float64{1: {1, 0}, 2: {1, 1}, 3: {0, 1}, 4: {-1, 0}}
+	for id, vector := range vectors {
+		if err := h.Add(id, vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := h.Search([]float64{2, 0}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{1, 2, 3}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, r := range results {
+		if r.Id != want[i] {
+			t.Errorf("result %d = %d, want %d", i, r.Id, want[i])
+		}
+		if d := cosineDistance([]float64{2, 0}, vectors[r.Id]); math.Abs(r.Distance-d) > 1e-6 {
+			t.Errorf("result %d distance = %v, want %v", i, r.Distance, d)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	vectors := randomVectors(rng, 500, 16)
+	queries := randomVectors(rng, 30, 16)
+	h := buildIndex(t, vectors)
+
+	removed := make(map[int]bool)
+	for id := 0; id < len(vectors); id += 3 {
+		h.Remove(id)
+		removed[id] = true
+	}
+	// Removing an unknown id is a no-op.
+	h.Remove(len(vectors) + 1)
+
+	if got, want := h.Len(), len(vectors)-len(removed); got != want {
+		t.Fatalf("Len = %d, want %d", got, want)
+	}
+	if r := recall(t, h, vectors, removed, queries, 10); r < 0.85 {
+		t.Errorf("recall@10 after removals = %.2f, want at least 0.85", r)
+	}
+
+	for id := range vectors {
+		h.Remove(id)
+	}
+	if h.Len() != 0 || h.MaxID() != 0 {
+		t.Errorf("emptied index has Len %d, MaxID %d", h.Len(), h.MaxID())
+	}
+	if results, err := h.Search(queries[0], 5); err != nil || len(results) != 0 {
+		t.Errorf("Search on empty index = %v, %v", results, err)
+	}
+	// An emptied index accepts vectors of a new dimension.
+	if err := h.Add(1, []float64{1, 2, 3}); err != nil {
+		t.Errorf("Add after emptying: %v", err)
+	}
+}
+
+func TestAddReplacesVector(t *testing.T) {
+	h := New()
+	h.Add(1, []float64{1, 0})
+	h.Add(2, []float64{0, 1})
+	h.Add(1, []float64{0, 1})
+
+	if h.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", h.Len())
+	}
+	results, _ := h.Search([]float64{0, 1}, 2)
+	for _, r := range results {
+		if r.Distance > 1e-6 {
+			t.Errorf("id %d distance = %v, want 0", r.Id, r.Distance)
+		}
+	}
+
+	// Re-adding ids many times, directly and after a Remove, leaves edges
+	// from other nodes pointing at levels the new node may not have.
+	rng := rand.New(rand.NewSource(5))
+	vectors := randomVectors(rng, 200, 8)
+	queries := randomVectors(rng, 20, 8)
+	h = buildIndex(t, vectors)
+	for round := range 5 {
+		for id := range vectors {
+			vectors[id] = randomVectors(rng, 1, 8)[0]
+			if id%2 == round%2 {
+				h.Remove(id)
+			}
+			if err := h.Add(id, vectors[id]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := h.Len(); got != len(vectors) {
+			t.Fatalf("round %d: Len = %d, want %d", round, got, len(vectors))
+		}
+		if r := recall(t, h, vectors, nil, queries, 10); r < 0.85 {
+			t.Errorf("round %d: recall@10 = %.2f, want at least 0.85", round, r)
+		}
+	}
+}
+
+func TestDimensionMismatch(t *testing.T) {
+	h := New()
+	h.Add(1, []float64{1, 0, 0})
+
+	if err := h.Add(2, []float64{1, 0}); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Add error = %v, want ErrDimensionMismatch", err)
+	}
+	if _, err := h.Search([]float64{1, 0}, 1); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("Search error = %v, want ErrDimensionMismatch", err)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	vectors := randomVectors(rng, 200, 8)
+	queries := randomVectors(rng, 20, 8)
+	h := buildIndex(t, vectors)
+	h.Remove(7)
+
+	path := filepath.Join(t.TempDir(), "index.hnsw")
+	if err := h.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Len() != h.Len() || loaded.Dim() != h.Dim() || loaded.MaxID() != h.MaxID() {
+		t.Fatalf("loaded index has Len %d, Dim %d, MaxID %d; want %d, %d, %d",
+			loaded.Len(), loaded.Dim(), loaded.MaxID(), h.Len(), h.Dim(), h.MaxID())
+	}
+	for _, query := range queries {
+		want, _ := h.Search(query, 5)
+		got, _ := loaded.Search(query, 5)
+		if len(got) != len(want) {
+			t.Fatalf("loaded index returned %d results, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("result %d = %+v, want %+v", i, got[i], want[i])
+			}
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
+
+// TestSaveDuringWrites is meant for go test -race: Save must not read
+// neighbour lists while Add and Remove rewrite them.
+func TestSaveDuringWrites(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	vectors := randomVectors(rng, 200, 8)
+	h := buildIndex(t, vectors[:200])
+	path := filepath.Join(t.TempDir(), "index.hnsw")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for id := 200; id < len(vectors); id++ {
+			h.Add(id, vectors[id])
+			h.Remove(id - 200)
+		}
+	}()
+	for range 5 {
+		if err := h.Save(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	if _, err := Load(path); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"synapse/client"
+	"synapse/database"
+)
+
+const DEFAULT_ASK_NOTES = 5
+
+// maxContextChars caps the note text sent to the chat model, which keeps the
+// prompt within the context window of small local models.
+const maxContextChars = 12000
+
+const askSystemPrompt = `You answer questions using only the user's notes below. Cite every note you use by its ID in square brackets, like [12]. If the notes do not contain the answer, say so instead of guessing.
+
+Notes:
+`
+
+// noAnswer is the reply when no note is close enough to the question to be
+// worth asking the model about.
+const noAnswer = "None of your notes seem to cover this question."
+
+// citationPattern matches citations such as [12] and [3, 7].
+var citationPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// AskOptions selects the notes an answer is based on. Notes is how many of
+// the closest notes are retrieved, DEFAULT_ASK_NOTES if zero.
+type AskOptions struct {
+	database.NoteFilter
+	Notes       int
+	MaxDistance float64
+}
+
+// Answer is the reply to a question. Sources are the notes the model was
+// given, closest first, and Citations the IDs of those it cited, in the
+// order they first appear in Text.
+type Answer struct {
+	Text      string
+	Citations []int
+	Sources   []database.Note
+}
+
+// Ask answers question from the knowledge base: the closest notes are found
+// with SemanticSearch and passed to the chat model as context. When onDelta
+// is set the answer is streamed through it as it is generated.
+func (s *NoteService) Ask(ctx context.Context, question string, opts AskOptions, onDelta func(delta string) error) (Answer, error) {
+	if strings.TrimSpace(question) == "" {
+		return Answer{}, invalidf("question must not be empty")
+	}
+	if s.Chat == nil {
+		return Answer{}, ErrNoChatModel
+	}
+	if opts.Notes == 0 {
+		opts.Notes = DEFAULT_ASK_NOTES
+	}
+
+	notes, err := s.SemanticSearch(ctx, question, database.SearchOptions{
+		NoteFilter:  opts.NoteFilter,
+		Limit:       opts.Notes,
+		MaxDistance: opts.MaxDistance,
+	})
+	if err != nil {
+		return Answer{}, err
+	}
+
+	answer := Answer{Sources: notes}
+	if len(notes) == 0 {
+		answer.Text = noAnswer
+		if onDelta != nil {
+			err = onDelta(noAnswer)
+		}
+		return answer, err
+	}
+
+	messages := []client.ChatMessage{
+		{Role: "system", Content: askSystemPrompt + buildContext(notes)},
+		{Role: "user", Content: question},
+	}
+	answer.Text, err = s.Chat.Chat(ctx, messages, onDelta)
+	if err != nil {
+		return answer, fmt.Errorf("chat completion failed: %w", err)
+	}
+	answer.Citations = citedNoteIds(answer.Text, notes)
+	return answer, nil
+}
+
+// buildContext lists notes for the system prompt, giving each an equal share
+// of maxContextChars. A note that doesn't fit is represented by the passage
+// that matched the question.
+func buildContext(notes []database.Note) string {
+	budget := maxContextChars / len(notes)
+
+	var b strings.Builder
+	for _, note := range notes {
+		fmt.Fprintf(&b, "[%d]", note.Id)
+		if note.Title != "" {
+			fmt.Fprintf(&b, " %s", note.Title)
+		}
+		if note.SourceURL != "" {
+			fmt.Fprintf(&b, " (%s)", note.SourceURL)
+		}
+		b.WriteString("\n")
+
+		text := note.Content
+		if len(text) > budget && note.Snippet != "" {
+			text = note.Snippet
+		}
+		b.WriteString(truncate(text, budget))
+		b.WriteString("\n\n")
+	}
+	return b.String()
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !isRuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// citedNoteIds returns the IDs cited in text that belong to sources, without
+// repeats, in order of first citation.
+func citedNoteIds(text string, sources []database.Note) []int {
+	known := make(map[int]bool, len(sources))
+	for _, note := range sources {
+		known[note.Id] = true
+	}
+
+	ids := make([]int, 0)
+	seen := make(map[int]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(text, -1) {
+		for _, part := range strings.Split(match[1], ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || !known[id] || seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"synapse/client"
+	"synapse/database"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAskWithoutChatModel(t *testing.T) {
+	s := &NoteService{}
+
+	_, err := s.Ask(context.Background(), "What is HNSW?", AskOptions{}, nil)
+	if !errors.Is(err, ErrNoChatModel) || !errors.Is(err, client.ErrChatUnavailable) {
+		t.Errorf("error = %v, want ErrNoChatModel", err)
+	}
+	if _, err := s.Ask(context.Background(), " ", AskOptions{}, nil); !errors.Is(err, ErrInvalid) {
+		t.Errorf("empty question: error = %v, want ErrInvalid", err)
+	}
+}
+
+// stubChat replies with reply in two pieces and records the conversation.
+type stubChat struct {
+	reply    string
+	err      error
+	messages []client.ChatMessage
+}
+
+func (c *stubChat) Model() string { return "chat" }
+
+func (c *stubChat) Chat(ctx context.Context, messages []client.ChatMessage, onDelta func(delta string) error) (string, error) {
+	c.messages = messages
+	if c.err != nil {
+		return "", c.err
+	}
+	if onDelta != nil {
+		half := len(c.reply) / 2
+		for _, delta := range []string{c.reply[:half], c.reply[half:]} {
+			if err := onDelta(delta); err != nil {
+				return "", err
+			}
+		}
+	}
+	return c.reply, nil
+}
+
+func TestAsk(t *testing.T) {
+	s := newTestService(t)
+	first := saveEmbeddedNote(t, s, "HNSW is a graph index.", []float64{1, 0})
+	second := saveEmbeddedNote(t, s, "IVF partitions vectors.", []float64{1, 1})
+	saveEmbeddedNote(t, s, "Unrelated recipe.", []float64{0, 1})
+	chat := &stubChat{reply: fmt.Sprintf("Graphs [%d], see also [%d, 99] and [%d].", second, first, second)}
+	s.Chat = chat
+
+	var streamed strings.Builder
+	answer, err := s.Ask(context.Background(), "What is HNSW?", AskOptions{Notes: 2}, func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamed.String() != chat.reply || answer.Text != chat.reply {
+		t.Errorf("streamed %q and answered %q, want %q", streamed.String(), answer.Text, chat.reply)
+	}
+	if fmt.Sprint(noteIds(answer.Sources)) != fmt.Sprint([]int{first, second}) {
+		t.Errorf("sources = %v, want the two closest notes", noteIds(answer.Sources))
+	}
+	// Unknown and repeated citations are dropped.
+	if fmt.Sprint(answer.Citations) != fmt.Sprint([]int{second, first}) {
+		t.Errorf("citations = %v, want %v", answer.Citations, []int{second, first})
+	}
+	if len(chat.messages) != 2 || chat.messages[1].Content != "What is HNSW?" {
+		t.Fatalf("messages = %+v, want the system prompt and the question", chat.messages)
+	}
+	prompt := chat.messages[0].Content
+	if !strings.Contains(prompt, fmt.Sprintf("[%d]\nHNSW is a graph index.", first)) || strings.Contains(prompt, "recipe") {
+		t.Errorf("system prompt does not hold exactly the sources:\n%s", prompt)
+	}
+}
+
+func TestAskWithoutSources(t *testing.T) {
+	s := newTestService(t)
+	saveEmbeddedNote(t, s, "Unrelated recipe.", []float64{0, 1})
+	chat := &stubChat{reply: "unused"}
+	s.Chat = chat
+
+	var streamed string
+	answer, err := s.Ask(context.Background(), "What is HNSW?", AskOptions{MaxDistance: 0.5}, func(delta string) error {
+		streamed += delta
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer.Text != noAnswer || streamed != noAnswer || len(answer.Sources) != 0 {
+		t.Errorf("answer = %+v, streamed %q; want the no-answer reply", answer, streamed)
+	}
+	if chat.messages != nil {
+		t.Error("the chat model was asked without any notes")
+	}
+
+	saveEmbeddedNote(t, s, "HNSW is a graph index.", []float64{1, 0})
+	chat.err = &client.ChatError{StatusCode: 503, Err: errors.New("overloaded")}
+	if _, err := s.Ask(context.Background(), "What is HNSW?", AskOptions{}, nil); !errors.Is(err, client.ErrChatUnavailable) {
+		t.Errorf("chat outage error = %v, want ErrChatUnavailable", err)
+	}
+}
+
+func TestBuildContextBudget(t *testing.T) {
+	long := strings.Repeat("é", maxContextChars)
+	built := buildContext([]database.Note{
+		{Id: 1, Title: "Long", SourceURL: "https://a", Content: long, Snippet: "the matching passage"},
+		{Id: 2, Content: long},
+	})
+	if !strings.Contains(built, "[1] Long (https://a)\nthe matching passage\n") {
+		t.Errorf("a note over budget was not replaced by its snippet:\n%.200s", built)
+	}
+	if !utf8.ValidString(built) || !strings.Contains(built, "…") {
+		t.Error("a note without a snippet was not truncated cleanly")
+	}
+	if len(built) > maxContextChars+100 {
+		t.Errorf("context is %d bytes, want about %d", len(built), maxContextChars)
+	}
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"synapse/database"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// DedupePolicy decides what happens when a new note duplicates a stored one.
+type DedupePolicy string
+
+const (
+	// DedupeReject refuses the new note with a *DuplicateError.
+	DedupeReject DedupePolicy = "reject"
+	// DedupeMerge folds the new note's tags, source fields and metadata into
+	// the existing note instead of creating another.
+	DedupeMerge DedupePolicy = "merge"
+	// DedupeAllow stores the duplicate as a note of its own.
+	DedupeAllow DedupePolicy = "allow"
+)
+
+// DEFAULT_DUPLICATE_THRESHOLD is the note embedding distance under which two
+// notes count as near-duplicates: a cosine similarity of 0.95.
+const DEFAULT_DUPLICATE_THRESHOLD = 0.05
+
+// ParseDedupePolicy reads a policy name. An empty name is returned as is and
+// means the service's configured policy.
+func ParseDedupePolicy(policy string) (DedupePolicy, error) {
+	switch DedupePolicy(policy) {
+	case "", DedupeReject, DedupeMerge, DedupeAllow:
+		return DedupePolicy(policy), nil
+	default:
+		return "", invalidf("unknown duplicate policy %q (expected %s, %s or %s)",
+			policy, DedupeReject, DedupeMerge, DedupeAllow)
+	}
+}
+
+// DuplicateError reports that a note's content is already stored. ExistingId
+// is zero when the original is an earlier note of the same import. Distance
+// is zero for exact duplicates and the embedding distance for near ones.
+type DuplicateError struct {
+	ExistingId int
+	Distance   float64
+}
+
+// Is makes every DuplicateError match ErrConflict.
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+func (e *DuplicateError) Error() string {
+	switch {
+	case e.ExistingId == 0:
+		return "duplicate of an earlier note in this import"
+	case e.Distance > 0:
+		return fmt.Sprintf("near-duplicate of note %d (distance %.3f)", e.ExistingId, e.Distance)
+	default:
+		return fmt.Sprintf("duplicate of note %d", e.ExistingId)
+	}
+}
+
+// CreateNoteWithPolicy is CreateNote with the duplicate policy chosen by the
+// caller; an empty policy uses DedupePolicy. A note is a duplicate when its
+// whitespace-normalised content matches a stored note, or when its embedding
+// is within DuplicateThreshold of one. Under DedupeMerge the existing note's
+// ID is returned with merged set. Concurrent calls with the same content
+// store it once; the others see it as a duplicate.
+func (s *NoteService) CreateNoteWithPolicy(ctx context.Context, note database.Note, policy DedupePolicy) (id int, merged bool, err error) {
+	if policy == "" {
+		policy = s.DedupePolicy
+	}
+	if err := validateNote(note); err != nil {
+		return 0, false, err
+	}
+
+	var duplicate *DuplicateError
+	if policy != DedupeAllow {
+		existingId, err := s.DBManager.FindNoteIdByContentHash(database.ContentHash(note.Content))
+		if err != nil {
+			return 0, false, err
+		}
+		if existingId != 0 {
+			duplicate = &DuplicateError{ExistingId: existingId}
+		}
+	}
+
+	var chunks [][]database.NoteChunk
+	var chunkVectors [][][]float64
+	if duplicate == nil {
+		notes := []database.Note{note}
+		chunks, chunkVectors, err = s.embedNewNotes(ctx, notes)
+		if err != nil {
+			return 0, false, err
+		}
+		note = notes[0]
+
+		if policy != DedupeAllow {
+			if duplicate, err = s.findNearDuplicate(note); err != nil {
+				return 0, false, err
+			}
+		}
+	}
+
+	if duplicate == nil {
+		if policy == DedupeAllow {
+			ids, err := s.saveNewNotes([]database.Note{note}, chunks, chunkVectors)
+			if err != nil {
+				return 0, false, err
+			}
+			return ids[0], false, nil
+		}
+
+		// The same content may have been saved while this note was being
+		// embedded, so the save checks again.
+		id, existingId, err := s.saveUniqueNote(note, chunks[0], chunkVectors[0])
+		if err != nil {
+			return 0, false, err
+		}
+		if existingId == 0 {
+			return id, false, nil
+		}
+		duplicate = &DuplicateError{ExistingId: existingId}
+	}
+
+	if policy == DedupeReject {
+		return 0, false, duplicate
+	}
+	if err := s.mergeInto(ctx, duplicate.ExistingId, note); err != nil {
+		return 0, false, err
+	}
+	return duplicate.ExistingId, true, nil
+}
+
+// findNearDuplicate compares the embedding of a note about to be created with
+// the stored notes. It returns nil when nothing is within DuplicateThreshold
+// or the note has no embedding yet.
+func (s *NoteService) findNearDuplicate(note database.Note) (*DuplicateError, error) {
+	if s.DuplicateThreshold <= 0 || note.EmbeddingStatus == database.EMBEDDING_PENDING {
+		return nil, nil
+	}
+
+	space := database.VectorSpace{Model: note.EmbeddingModel, Dim: note.EmbeddingDim}
+	id, distance, err := s.DBManager.FindNearestNote(note.EmbeddingVector, space)
+	if err != nil {
+		return nil, fmt.Errorf("db duplicate search failed: %w", err)
+	}
+	if id == 0 || distance > s.DuplicateThreshold {
+		return nil, nil
+	}
+	return &DuplicateError{ExistingId: id, Distance: distance}, nil
+}
+
+// mergeInto folds note into the stored note with the given id: its tags are
+// added, empty source fields are filled in and metadata keys the existing
+// note lacks are copied. The existing content is kept.
+func (s *NoteService) mergeInto(ctx context.Context, id int, note database.Note) error {
+	existing, err := s.DBManager.GetNoteById(id)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return notFoundf("note %d to merge into no longer exists", id)
+	}
+
+	var update NoteUpdate
+	fillString(&update.SourceURL, existing.SourceURL, note.SourceURL)
+	fillString(&update.Title, existing.Title, note.Title)
+	fillString(&update.SiteName, existing.SiteName, note.SiteName)
+	fillString(&update.Author, existing.Author, note.Author)
+
+	metadata, changed, err := mergeMetadata(existing.Metadata, note.Metadata)
+	if err != nil {
+		return err
+	}
+	if changed {
+		update.Metadata = &metadata
+	}
+
+	if _, err := s.UpdateNote(ctx, id, update); err != nil {
+		return err
+	}
+	if len(note.Tags) > 0 {
+		if err := s.DBManager.AddTags(id, note.Tags); err != nil {
+			return err
+		}
+		s.publishNote(EventNoteUpdated, id)
+	}
+	return nil
+}
+
+func fillString(field **string, existing string, value string) {
+	if existing == "" && value != "" {
+		*field = &value
+	}
+}
+
+// mergeMetadata adds the keys of extra that base lacks. Both are JSON objects
+// or empty.
+func mergeMetadata(base string, extra string) (string, bool, error) {
+	if extra == "" || extra == "{}" {
+		return base, false, nil
+	}
+
+	merged := map[string]json.RawMessage{}
+	if base != "" {
+		if err := json.Unmarshal([]byte(base), &merged); err != nil {
+			return "", false, fmt.Errorf("invalid stored metadata: %w", err)
+		}
+	}
+	var additions map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(extra), &additions); err != nil {
+		return "", false, fmt.Errorf("invalid metadata: %w", err)
+	}
+
+	changed := false
+	for key, value := range additions {
+		if _, ok := merged[key]; !ok {
+			merged[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return base, false, nil
+	}
+
+	encoded, err := json.Marshal(merged)
+	if err != nil {
+		return "", false, err
+	}
+	return string(encoded), true, nil
+}
+
+// DuplicateCluster is a group of notes found to duplicate each other. KeepId
+// is the oldest note, which the others are merged into.
+type DuplicateCluster struct {
+	KeepId       int
+	DuplicateIds []int
+}
+
+// dedupePageSize is how many notes FindDuplicates loads per query.
+const dedupePageSize = 500
+
+// FindDuplicates groups stored notes that are exact duplicates, or whose
+// note-level embeddings from the active model are within threshold of each
+// other. Similarity is transitive, so a chain of near-duplicates forms one
+// cluster. Every pair of embeddings is compared, which is fine for a personal
+// knowledge base but grows quadratically.
+func (s *NoteService) FindDuplicates(threshold float64) ([]DuplicateCluster, error) {
+	model := s.Embedder.Model()
+	parent := map[int]int{}
+	var find func(id int) int
+	find = func(id int) int {
+		if parent[id] == id {
+			return id
+		}
+		parent[id] = find(parent[id])
+		return parent[id]
+	}
+	// The smaller id always becomes the root so the oldest note is kept.
+	union := func(a, b int) {
+		a, b = find(a), find(b)
+		if a > b {
+			a, b = b, a
+		}
+		parent[b] = a
+	}
+
+	type embedded struct {
+		id     int
+		vector []float64
+	}
+	var vectors []embedded
+	byHash := map[string]int{}
+
+	afterID := 0
+	for {
+		notes, err := s.DBManager.ListNotesWithEmbeddings(database.NoteFilter{}, afterID, dedupePageSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(notes) == 0 {
+			break
+		}
+		afterID = notes[len(notes)-1].Id
+
+		for _, note := range notes {
+			parent[note.Id] = note.Id
+			hash := database.ContentHash(note.Content)
+			if original, ok := byHash[hash]; ok {
+				union(original, note.Id)
+			} else {
+				byHash[hash] = note.Id
+			}
+
+			if threshold <= 0 || note.EmbeddingModel != model || note.EmbeddingStatus != database.EMBEDDING_READY {
+				continue
+			}
+			vector, err := database.DecodeVector(note.EmbeddingVector)
+			if err != nil {
+				return nil, fmt.Errorf("note %d: %w", note.Id, err)
+			}
+			norm := floats.Norm(vector, 2)
+			if norm == 0 {
+				continue
+			}
+			floats.Scale(1/norm, vector)
+			vectors = append(vectors, embedded{id: note.Id, vector: vector})
+		}
+	}
+
+	for i := range vectors {
+		for j := i + 1; j < len(vectors); j++ {
+			if len(vectors[i].vector) != len(vectors[j].vector) {
+				continue
+			}
+			if 1-floats.Dot(vectors[i].vector, vectors[j].vector) <= threshold {
+				union(vectors[i].id, vectors[j].id)
+			}
+		}
+	}
+
+	members := map[int][]int{}
+	for id := range parent {
+		if root := find(id); root != id {
+			members[root] = append(members[root], id)
+		}
+	}
+	clusters := make([]DuplicateCluster, 0, len(members))
+	for root, ids := range members {
+		slices.Sort(ids)
+		clusters = append(clusters, DuplicateCluster{KeepId: root, DuplicateIds: ids})
+	}
+	slices.SortFunc(clusters, func(a, b DuplicateCluster) int { return a.KeepId - b.KeepId })
+	return clusters, nil
+}
+
+// MergeDuplicates merges every duplicate of cluster into its kept note, as
+// DedupeMerge does on capture, and deletes the duplicates.
+func (s *NoteService) MergeDuplicates(ctx context.Context, cluster DuplicateCluster) error {
+	for _, id := range cluster.DuplicateIds {
+		note, err := s.DBManager.GetNoteById(id)
+		if err != nil {
+			return err
+		}
+		if note == nil {
+			continue
+		}
+		if err := s.mergeInto(ctx, cluster.KeepId, *note); err != nil {
+			return fmt.Errorf("failed to merge note %d into %d: %w", id, cluster.KeepId, err)
+		}
+		if err := s.Delete(id); err != nil {
+			return fmt.Errorf("failed to delete merged note %d: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"synapse/database"
+	"sync"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	s := newTestService(t)
+	first := saveEmbeddedNote(t, s, "Raft elects a leader.", []float64{1, 0})
+	duplicate := saveEmbeddedNote(t, s, "Raft elects a leader.", []float64{1, 0})
+	near := saveEmbeddedNote(t, s, "Raft elects one leader.", []float64{1, 0.05})
+	saveEmbeddedNote(t, s, "HNSW is a graph index.", []float64{0, 1})
+
+	tests := []struct {
+		name      string
+		threshold float64
+		want      []DuplicateCluster
+	}{
+		{"exact only", 0, []DuplicateCluster{{KeepId: first, DuplicateIds: []int{duplicate}}}},
+		{"near", 0.01, []DuplicateCluster{{KeepId: first, DuplicateIds: []int{duplicate, near}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusters, err := s.FindDuplicates(tt.threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(clusters) != fmt.Sprint(tt.want) {
+				t.Errorf("FindDuplicates(%v) = %v, want %v", tt.threshold, clusters, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateNoteWithPolicyConcurrentDuplicates(t *testing.T) {
+	for _, policy := range []DedupePolicy{DedupeReject, DedupeMerge} {
+		t.Run(string(policy), func(t *testing.T) {
+			s := newTestService(t)
+			// Only the content hash can catch these duplicates.
+			s.DuplicateThreshold = 0
+
+			const callers = 8
+			ids := make([]int, callers)
+			errs := make([]error, callers)
+			var wg sync.WaitGroup
+			for i := range callers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					ids[i], _, errs[i] = s.CreateNoteWithPolicy(context.Background(), database.Note{Content: "Same text."}, policy)
+				}()
+			}
+			wg.Wait()
+
+			var stored int
+			if err := s.DBManager.DB.QueryRow(`SELECT COUNT(*) FROM notes`).Scan(&stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored != 1 {
+				t.Fatalf("%d concurrent saves stored %d notes, want 1", callers, stored)
+			}
+			created := 0
+			for i, err := range errs {
+				var duplicate *DuplicateError
+				switch {
+				case err == nil:
+					created++
+				case policy == DedupeReject && errors.As(err, &duplicate):
+				default:
+					t.Errorf("caller %d: %v", i, err)
+				}
+			}
+			if policy == DedupeReject && created != 1 {
+				t.Errorf("%d callers created the note, want 1", created)
+			}
+			if policy == DedupeMerge && created != callers {
+				t.Errorf("%d callers succeeded, want all %d to create or merge", created, callers)
+			}
+		})
+	}
+}
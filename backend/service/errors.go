@@ -0,0 +1,46 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"synapse/client"
+)
+
+// Error kinds. Errors caused by the caller's input, a missing note or a clash
+// with stored data match one of these with errors.Is, so callers can react to
+// them without parsing messages. An embedding server that cannot be reached
+// matches client.ErrUnavailable, and a chat server client.ErrChatUnavailable.
+// Anything else is an internal failure.
+var (
+	ErrInvalid  = errors.New("invalid input")
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("conflict")
+)
+
+var (
+	ErrNoteNotFound    error = &kindError{kind: ErrNotFound, message: "note not found"}
+	ErrNoteNotEmbedded error = &kindError{kind: ErrConflict, message: "note has no embedding yet"}
+	ErrNoChatModel     error = &kindError{kind: client.ErrChatUnavailable, message: "no chat model is configured"}
+)
+
+// kindError has a message of its own and matches one of the error kinds.
+type kindError struct {
+	kind    error
+	message string
+}
+
+func (e *kindError) Error() string {
+	return e.message
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func invalidf(format string, args ...any) error {
+	return &kindError{kind: ErrInvalid, message: fmt.Sprintf(format, args...)}
+}
+
+func notFoundf(format string, args ...any) error {
+	return &kindError{kind: ErrNotFound, message: fmt.Sprintf(format, args...)}
+}
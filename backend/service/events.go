@@ -0,0 +1,127 @@
+package service
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// EventType names what an Event reports.
+type EventType string
+
+const (
+	EventNoteCreated EventType = "note.created"
+	EventNoteUpdated EventType = "note.updated"
+	EventNoteDeleted EventType = "note.deleted"
+	EventJobProgress EventType = "job.progress"
+)
+
+// JobKind names a long-running job that reports progress.
+type JobKind string
+
+const (
+	JobImport       JobKind = "import"
+	JobReindex      JobKind = "reindex"
+	JobEmbedPending JobKind = "embed_pending"
+)
+
+// DEFAULT_EVENT_BUFFER is how many events a subscriber may fall behind before
+// it is dropped.
+const DEFAULT_EVENT_BUFFER = 256
+
+// Event is a change to the knowledge base. Note events carry the ID of the
+// note; job events carry the job's progress so far.
+type Event struct {
+	Type   EventType
+	NoteId int
+	Job    *JobProgress
+}
+
+// JobProgress counts the items a job has handled out of Total. Finished is
+// set on the job's last event.
+type JobProgress struct {
+	Job      JobKind
+	Total    int
+	Done     int
+	Skipped  int
+	Failed   int
+	Finished bool
+}
+
+// EventHub fans events out to its subscribers. Publishing never blocks: a
+// subscriber that falls more than its buffer behind is dropped and its
+// channel closed, so it knows it has missed events and must resynchronise.
+type EventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{subscribers: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel receiving every event published from now on,
+// and a function that ends the subscription. buffer is how many events may
+// queue up, DEFAULT_EVENT_BUFFER if zero.
+func (h *EventHub) Subscribe(buffer int) (<-chan Event, func()) {
+	if buffer <= 0 {
+		buffer = DEFAULT_EVENT_BUFFER
+	}
+	events := make(chan Event, buffer)
+
+	h.mu.Lock()
+	h.subscribers[events] = struct{}{}
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(events)
+	}
+	return events, unsubscribe
+}
+
+// Publish sends event to every subscriber.
+func (h *EventHub) Publish(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for events := range h.subscribers {
+		select {
+		case events <- event:
+		default:
+			slog.Warn("Event subscriber fell behind, dropping it", "buffer", cap(events))
+			h.remove(events)
+		}
+	}
+}
+
+// Close ends every subscription, which lets long-lived subscribers such as
+// event streams finish when the server shuts down.
+func (h *EventHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for events := range h.subscribers {
+		h.remove(events)
+	}
+}
+
+// remove ends a subscription if it is still active. h.mu must be held.
+func (h *EventHub) remove(events chan Event) {
+	if _, ok := h.subscribers[events]; ok {
+		delete(h.subscribers, events)
+		close(events)
+	}
+}
+
+func (s *NoteService) publish(event Event) {
+	if s.Events != nil {
+		s.Events.Publish(event)
+	}
+}
+
+func (s *NoteService) publishNote(eventType EventType, id int) {
+	s.publish(Event{Type: eventType, NoteId: id})
+}
+
+func (s *NoteService) publishJob(progress JobProgress) {
+	s.publish(Event{Type: EventJobProgress, Job: &progress})
+}
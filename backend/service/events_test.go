@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"synapse/database"
+	"testing"
+)
+
+// drain returns the events queued on events without waiting for more.
+func drain(events <-chan Event) []Event {
+	var received []Event
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return received
+			}
+			received = append(received, event)
+		default:
+			return received
+		}
+	}
+}
+
+func TestEventHubFanOut(t *testing.T) {
+	hub := NewEventHub()
+	first, unsubscribeFirst := hub.Subscribe(0)
+	second, unsubscribeSecond := hub.Subscribe(0)
+	defer unsubscribeSecond()
+
+	hub.Publish(Event{Type: EventNoteCreated, NoteId: 1})
+	hub.Publish(Event{Type: EventNoteDeleted, NoteId: 2})
+	for name, events := range map[string]<-chan Event{"first": first, "second": second} {
+		received := drain(events)
+		if len(received) != 2 || received[0].NoteId != 1 || received[1].Type != EventNoteDeleted {
+			t.Errorf("%s subscriber got %+v, want both events in order", name, received)
+		}
+	}
+
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("unsubscribed channel is still open")
+	}
+	// Ending a subscription twice is harmless.
+	unsubscribeFirst()
+
+	hub.Publish(Event{Type: EventNoteUpdated, NoteId: 3})
+	if received := drain(second); len(received) != 1 || received[0].NoteId != 3 {
+		t.Errorf("remaining subscriber got %+v, want the note 3 update", received)
+	}
+}
+
+func TestEventHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewEventHub()
+	slow, unsubscribeSlow := hub.Subscribe(2)
+	defer unsubscribeSlow()
+	fast, unsubscribeFast := hub.Subscribe(0)
+	defer unsubscribeFast()
+
+	for id := range 3 {
+		hub.Publish(Event{Type: EventNoteCreated, NoteId: id})
+	}
+
+	// The slow subscriber keeps what fitted in its buffer, then finds its
+	// channel closed.
+	if received := drain(slow); len(received) != 2 {
+		t.Errorf("slow subscriber got %d events, want its buffer of 2", len(received))
+	}
+	if _, ok := <-slow; ok {
+		t.Error("slow subscriber was not dropped")
+	}
+	if received := drain(fast); len(received) != 3 {
+		t.Errorf("fast subscriber got %d events, want 3", len(received))
+	}
+}
+
+func TestEventHubClose(t *testing.T) {
+	hub := NewEventHub()
+	first, unsubscribeFirst := hub.Subscribe(0)
+	second, _ := hub.Subscribe(0)
+
+	hub.Close()
+	for _, events := range []<-chan Event{first, second} {
+		if _, ok := <-events; ok {
+			t.Error("subscription outlived Close")
+		}
+	}
+	// Late unsubscribes and publishes find no subscribers.
+	unsubscribeFirst()
+	hub.Publish(Event{Type: EventNoteCreated, NoteId: 1})
+}
+
+func TestJobProgressEvents(t *testing.T) {
+	s := newTestService(t)
+	events, unsubscribe := s.Events.Subscribe(0)
+	defer unsubscribe()
+
+	// A reindex with nothing to do still tells subscribers it is finished.
+	if _, err := s.Reindex(context.Background(), 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	received := drain(events)
+	if len(received) != 1 || received[0].Job == nil || received[0].Job.Job != JobReindex || !received[0].Job.Finished {
+		t.Fatalf("reindex events = %+v, want one finished reindex event", received)
+	}
+
+	notes := []database.Note{{Content: "one"}, {Content: "two"}, {Content: "one"}}
+	if _, err := s.ImportNotes(context.Background(), notes, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	var last *JobProgress
+	created := 0
+	for _, event := range drain(events) {
+		switch event.Type {
+		case EventNoteCreated:
+			created++
+		case EventJobProgress:
+			last = event.Job
+		}
+	}
+	if created != 2 {
+		t.Errorf("got %d note.created events, want 2", created)
+	}
+	if last == nil || last.Job != JobImport || !last.Finished || last.Total != 3 || last.Done != 2 || last.Skipped != 1 {
+		t.Errorf("last import progress = %+v, want 2 of 3 done, 1 skipped, finished", last)
+	}
+}
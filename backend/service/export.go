@@ -0,0 +1,32 @@
+package service
+
+import "synapse/database"
+
+const exportPageSize = 500
+
+// ExportNotes passes every note matching filter to fn in id order. Notes are
+// read a page at a time so exports of any size stream in bounded memory.
+// Embeddings are only loaded when withEmbeddings is set.
+func (s *NoteService) ExportNotes(filter database.NoteFilter, withEmbeddings bool, fn func(database.Note) error) error {
+	list := s.DBManager.ListNotes
+	if withEmbeddings {
+		list = s.DBManager.ListNotesWithEmbeddings
+	}
+
+	afterID := 0
+	for {
+		notes, err := list(filter, afterID, exportPageSize)
+		if err != nil {
+			return err
+		}
+		for _, note := range notes {
+			if err := fn(note); err != nil {
+				return err
+			}
+		}
+		if len(notes) < exportPageSize {
+			return nil
+		}
+		afterID = notes[len(notes)-1].Id
+	}
+}
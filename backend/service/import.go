@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"synapse/database"
+	"sync"
+)
+
+const DEFAULT_IMPORT_CONCURRENCY = 4
+
+// ImportSummary counts the outcome of ImportNotes.
+type ImportSummary struct {
+	Imported int
+	Skipped  int
+	Failed   int
+}
+
+// importBatchSize is how many notes ImportNotes embeds and saves together.
+const importBatchSize = 32
+
+// ImportNotes creates every note in notes in batches, embedding and saving
+// each batch together with up to concurrency batches in flight. Notes whose
+// whitespace-normalised content is already stored, or appears earlier in
+// notes, are skipped without being embedded. report, if set, is called once
+// per note with its index in notes and either the new note ID or the error,
+// which is a *DuplicateError for skipped notes. Calls may come from other
+// goroutines but are never concurrent. A batch that fails to embed or save
+// fails all of its notes. Progress is published to Events after each batch.
+func (s *NoteService) ImportNotes(ctx context.Context, notes []database.Note, concurrency int, report func(i int, id int, err error)) (ImportSummary, error) {
+	if concurrency <= 0 {
+		concurrency = DEFAULT_IMPORT_CONCURRENCY
+	}
+
+	var summary ImportSummary
+	publishProgress := func(finished bool) {
+		s.publishJob(JobProgress{
+			Job:      JobImport,
+			Total:    len(notes),
+			Done:     summary.Imported,
+			Skipped:  summary.Skipped,
+			Failed:   summary.Failed,
+			Finished: finished,
+		})
+	}
+	defer publishProgress(true)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, concurrency)
+
+	finish := func(i int, id int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch err.(type) {
+		case nil:
+			summary.Imported++
+		case *DuplicateError:
+			summary.Skipped++
+		default:
+			summary.Failed++
+		}
+		if report != nil {
+			report(i, id, err)
+		}
+	}
+
+	importBatch := func(indexes []int) {
+		batch := make([]database.Note, len(indexes))
+		for j, i := range indexes {
+			batch[j] = notes[i]
+		}
+		ids, err := s.CreateNotes(ctx, batch)
+		for j, i := range indexes {
+			if err != nil {
+				finish(i, 0, err)
+				continue
+			}
+			finish(i, ids[j], nil)
+		}
+		mu.Lock()
+		publishProgress(false)
+		mu.Unlock()
+	}
+
+	var pending []int
+	dispatch := func() {
+		indexes := pending
+		pending = nil
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			importBatch(indexes)
+		}()
+	}
+
+	seen := make(map[string]bool, len(notes))
+	for i, note := range notes {
+		if err := ctx.Err(); err != nil {
+			wg.Wait()
+			return summary, err
+		}
+
+		// Invalid notes are reported on their own so they can't fail a batch.
+		if err := validateNote(note); err != nil {
+			finish(i, 0, err)
+			continue
+		}
+
+		hash := database.ContentHash(note.Content)
+		if seen[hash] {
+			finish(i, 0, &DuplicateError{})
+			continue
+		}
+		seen[hash] = true
+
+		existingId, err := s.DBManager.FindNoteIdByContentHash(hash)
+		if err != nil {
+			finish(i, 0, err)
+			continue
+		}
+		if existingId != 0 {
+			finish(i, 0, &DuplicateError{ExistingId: existingId})
+			continue
+		}
+
+		pending = append(pending, i)
+		if len(pending) == importBatchSize {
+			dispatch()
+		}
+	}
+	if len(pending) > 0 {
+		dispatch()
+	}
+	wg.Wait()
+	return summary, nil
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"synapse/database"
+	"synapse/index"
+	"sync"
+	"time"
+)
+
+// DEFAULT_INDEX_SAVE_INTERVAL is how often a running server persists the
+// vector index.
+const DEFAULT_INDEX_SAVE_INTERVAL = 5 * time.Minute
+
+// annIndex holds the approximate nearest neighbour index. It is loaded on
+// first use so commands that never search or mutate notes don't pay for it.
+type annIndex struct {
+	path string
+
+	// saveMu orders saves, so an older snapshot never replaces a newer one.
+	saveMu sync.Mutex
+
+	once     sync.Once
+	mu       sync.Mutex
+	hnsw     *index.HNSW
+	dirty    bool
+	disabled bool
+}
+
+// annIndex returns the loaded index, or nil when searches must fall back to an
+// exact scan.
+func (s *NoteService) annIndex() *index.HNSW {
+	ai := s.ann
+	ai.once.Do(func() {
+		hnsw, rebuilt, err := s.loadIndex()
+		ai.mu.Lock()
+		defer ai.mu.Unlock()
+		if err != nil {
+			slog.Warn("Vector index unavailable, using exact search", "error", err)
+			ai.disabled = true
+			return
+		}
+		ai.hnsw = hnsw
+		ai.dirty = rebuilt
+	})
+
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	if ai.disabled {
+		return nil
+	}
+	return ai.hnsw
+}
+
+// loadIndex reads the persisted index of chunk embeddings from the active
+// model, rebuilding it from the note_chunks table if it is missing or no
+// longer matches the database.
+func (s *NoteService) loadIndex() (*index.HNSW, bool, error) {
+	count, maxID, err := s.DBManager.ChunkStats(s.Embedder.Model())
+	if err != nil {
+		return nil, false, err
+	}
+
+	if s.ann.path != "" {
+		hnsw, err := index.Load(s.ann.path)
+		switch {
+		case err == nil && hnsw.Len() == count && hnsw.MaxID() == maxID:
+			slog.Debug("Vector index loaded", "path", s.ann.path, "size", count)
+			return hnsw, false, nil
+		case err == nil:
+			slog.Info("Vector index is stale, rebuilding", "indexed", hnsw.Len(), "chunks", count)
+		case !errors.Is(err, os.ErrNotExist):
+			slog.Warn("Failed to load vector index, rebuilding", "path", s.ann.path, "error", err)
+		}
+	}
+
+	hnsw, err := s.buildIndex()
+	return hnsw, true, err
+}
+
+func (s *NoteService) buildIndex() (*index.HNSW, error) {
+	hnsw := index.New()
+	err := s.DBManager.ForEachChunkEmbedding(s.Embedder.Model(), func(id int, embedding []byte) error {
+		vector, err := database.DecodeVector(embedding)
+		if err != nil {
+			return fmt.Errorf("chunk %d: %w", id, err)
+		}
+		return hnsw.Add(id, vector)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build vector index: %w", err)
+	}
+	slog.Debug("Vector index built", "size", hnsw.Len())
+	return hnsw, nil
+}
+
+// ensureIndexLoaded must be called before mutating notes so that the
+// staleness check in loadIndex compares against the pre-change table.
+func (s *NoteService) ensureIndexLoaded() {
+	s.annIndex()
+}
+
+func (s *NoteService) indexAdd(id int, vector []float64) {
+	hnsw := s.annIndex()
+	if hnsw == nil {
+		return
+	}
+	if err := hnsw.Add(id, vector); err != nil {
+		s.disableIndex(err)
+		return
+	}
+	s.markIndexDirty()
+}
+
+func (s *NoteService) indexRemove(id int) {
+	hnsw := s.annIndex()
+	if hnsw == nil {
+		return
+	}
+	hnsw.Remove(id)
+	s.markIndexDirty()
+}
+
+func (s *NoteService) markIndexDirty() {
+	s.ann.mu.Lock()
+	s.ann.dirty = true
+	s.ann.mu.Unlock()
+}
+
+// disableIndex stops using the index for the rest of the process and drops
+// the persisted copy so the next run rebuilds it from the database.
+func (s *NoteService) disableIndex(cause error) {
+	slog.Warn("Vector index out of sync, falling back to exact search", "error", cause)
+
+	s.ann.mu.Lock()
+	defer s.ann.mu.Unlock()
+	s.ann.disabled = true
+	s.ann.dirty = false
+	if s.ann.path != "" {
+		if err := os.Remove(s.ann.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Failed to remove stale vector index", "path", s.ann.path, "error", err)
+		}
+	}
+}
+
+// SaveIndex persists the vector index next to the database if it changed.
+// Notes may be added and removed while it is being written.
+func (s *NoteService) SaveIndex() error {
+	s.ann.saveMu.Lock()
+	defer s.ann.saveMu.Unlock()
+
+	s.ann.mu.Lock()
+	hnsw := s.ann.hnsw
+	if s.ann.path == "" || hnsw == nil || s.ann.disabled || !s.ann.dirty {
+		s.ann.mu.Unlock()
+		return nil
+	}
+	// Cleared before the copy is taken, so changes made during the save
+	// mark the index dirty again.
+	s.ann.dirty = false
+	s.ann.mu.Unlock()
+
+	if err := hnsw.Save(s.ann.path); err != nil {
+		s.markIndexDirty()
+		return fmt.Errorf("failed to save vector index: %w", err)
+	}
+	slog.Debug("Vector index saved", "path", s.ann.path)
+	return nil
+}
+
+// RunIndexSaver saves the vector index every interval until ctx is done, so
+// a long-running server that is killed rather than stopped loses at most one
+// interval of index changes. Those are rebuilt from the database on the next
+// start anyway, which is slow for large collections.
+func (s *NoteService) RunIndexSaver(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DEFAULT_INDEX_SAVE_INTERVAL
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.saveIndexLogged()
+		}
+	}
+}
+
+func (s *NoteService) saveIndexLogged() {
+	if err := s.SaveIndex(); err != nil {
+		slog.Error("Failed to persist vector index", "error", err)
+	}
+}
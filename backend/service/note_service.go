@@ -2,69 +2,491 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"synapse/chunker"
 	"synapse/client"
 	"synapse/database"
+	"synapse/index"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+const DEFAULT_SEARCH_LIMIT = 10
+
+const (
+	DEFAULT_PAGE_SIZE = 50
+	MAX_PAGE_SIZE     = 500
 )
 
+const chunkOversampling = 4
+
 type NoteService struct {
 	DBManager *database.SQLiteManager
+	Embedder  client.Embedder
+	// Chat answers questions in Ask; nil disables it.
+	Chat         client.ChatModel
+	ChunkOptions chunker.Options
+	// DedupePolicy decides what CreateNote does with duplicates of stored
+	// notes, and DuplicateThreshold is the note embedding distance under
+	// which a note counts as a near-duplicate; 0 only catches exact copies.
+	DedupePolicy       DedupePolicy
+	DuplicateThreshold float64
+	// VectorFormat is how new embeddings are stored. Search queries are
+	// always encoded as float32 so quantized notes lose no further accuracy.
+	VectorFormat database.VectorFormat
+	// Events receives a note event for every change and progress events
+	// from imports, reindexing and pending embedding; nil disables them.
+	Events *EventHub
+
+	ann *annIndex
 }
 
-func NewNoteService(dbManager *database.SQLiteManager) *NoteService {
+// NewNoteService wires the service to its dependencies. indexPath is where the
+// vector index is persisted; an empty path keeps the index in memory only.
+func NewNoteService(dbManager *database.SQLiteManager, embedder client.Embedder, indexPath string) *NoteService {
 	return &NoteService{
-		DBManager: dbManager,
+		DBManager:    dbManager,
+		Embedder:     embedder,
+		ChunkOptions: chunker.DefaultOptions(),
+		DedupePolicy: DedupeReject,
+		VectorFormat: database.DEFAULT_VECTOR_FORMAT,
+		Events:       NewEventHub(),
+		ann:          &annIndex{path: indexPath},
+
+		DuplicateThreshold: DEFAULT_DUPLICATE_THRESHOLD,
 	}
 }
 
-func (s *NoteService) CreateNote(ctx context.Context, content string) error {
-	embeddingFloats, err := client.GenerateEmbedding(ctx, content)
+// CreateNote splits the note content into chunks, embeds each one and stores
+// the note with its source fields, returning the new note ID. The note-level
+// embedding is the mean of its chunk embeddings. Metadata, if set, must be a
+// JSON object. If the embedding server is unavailable the note is still saved,
+// marked pending, for EmbedPending to embed later. Duplicates are handled as
+// DedupePolicy says; see CreateNoteWithPolicy.
+func (s *NoteService) CreateNote(ctx context.Context, note database.Note) (int, error) {
+	id, _, err := s.CreateNoteWithPolicy(ctx, note, "")
+	return id, err
+}
+
+// CreateNotes is the bulk form of CreateNote. The chunks of all notes are
+// embedded in as few batched requests as the embedder allows, and the notes
+// are saved in a single transaction, so either all are stored or none is.
+// The new IDs are returned in the order of notes.
+func (s *NoteService) CreateNotes(ctx context.Context, notes []database.Note) ([]int, error) {
+	for _, note := range notes {
+		if err := validateNote(note); err != nil {
+			return nil, err
+		}
+	}
+
+	notes = slices.Clone(notes)
+	chunks, chunkVectors, err := s.embedNewNotes(ctx, notes)
 	if err != nil {
-		return fmt.Errorf("AI generation failed: %w", err)
+		return nil, err
 	}
+	return s.saveNewNotes(notes, chunks, chunkVectors)
+}
+
+// embedNewNotes is embedNotes for notes about to be created: when the
+// embedding server is unavailable the notes are marked pending instead.
+func (s *NoteService) embedNewNotes(ctx context.Context, notes []database.Note) ([][]database.NoteChunk, [][][]float64, error) {
+	chunks, chunkVectors, err := s.embedNotes(ctx, notes)
+	if errors.Is(err, client.ErrUnavailable) {
+		slog.Warn("Embedding server unavailable, saving notes for later embedding", "count", len(notes), "error", err)
+		chunks = make([][]database.NoteChunk, len(notes))
+		chunkVectors = make([][][]float64, len(notes))
+		for i := range notes {
+			notes[i].EmbeddingStatus = database.EMBEDDING_PENDING
+		}
+		return chunks, chunkVectors, nil
+	}
+	return chunks, chunkVectors, err
+}
 
-	embeddingBytes, err := database.FloatSliceToBytes(embeddingFloats)
+func (s *NoteService) saveNewNotes(notes []database.Note, chunks [][]database.NoteChunk, chunkVectors [][][]float64) ([]int, error) {
+	s.ensureIndexLoaded()
+	ids, err := s.DBManager.SaveNotes(notes, chunks)
 	if err != nil {
-		return fmt.Errorf("vector encoding failed: %w", err)
+		return nil, fmt.Errorf("db save failed: %w", err)
 	}
+	s.notesSaved(ids, chunks, chunkVectors)
+	return ids, nil
+}
 
-	note := database.Note{
-		Content:         content,
-		EmbeddingVector: embeddingBytes,
+// saveUniqueNote is saveNewNotes for a single note, unless a stored note has
+// the same content. Then nothing is saved and that note's ID is returned as
+// existingId.
+func (s *NoteService) saveUniqueNote(note database.Note, chunks []database.NoteChunk, chunkVectors [][]float64) (id int, existingId int, err error) {
+	s.ensureIndexLoaded()
+	id, existingId, err = s.DBManager.SaveNoteUnlessDuplicate(note, chunks)
+	if err != nil {
+		return 0, 0, fmt.Errorf("db save failed: %w", err)
+	}
+	if existingId != 0 {
+		return 0, existingId, nil
+	}
+	s.notesSaved([]int{id}, [][]database.NoteChunk{chunks}, [][][]float64{chunkVectors})
+	return id, 0, nil
+}
+
+// notesSaved indexes the chunks of newly saved notes and announces them.
+func (s *NoteService) notesSaved(ids []int, chunks [][]database.NoteChunk, chunkVectors [][][]float64) {
+	for i := range ids {
+		for j, chunk := range chunks[i] {
+			s.indexAdd(chunk.Id, chunkVectors[i][j])
+		}
+	}
+	for _, id := range ids {
+		s.publishNote(EventNoteCreated, id)
 	}
+}
 
-	if err := s.DBManager.SaveNote(note); err != nil {
-		return fmt.Errorf("db save failed: %w", err)
+func validateNote(note database.Note) error {
+	if strings.TrimSpace(note.Content) == "" {
+		return invalidf("note content must not be empty")
+	}
+	if note.Metadata != "" && !isJSONObject(note.Metadata) {
+		return invalidf("note metadata must be a JSON object")
 	}
 	return nil
 }
 
-func (s *NoteService) SemanticSearch(ctx context.Context, query string) ([]database.Note, error) {
-	embeddingFloats, err := client.GenerateEmbedding(ctx, query)
+// embedNote chunks and embeds note.Content, setting the note-level embedding
+// and the model it came from on note. It returns the chunks ready to be saved
+// alongside their decoded vectors.
+func (s *NoteService) embedNote(ctx context.Context, note *database.Note) ([]database.NoteChunk, [][]float64, error) {
+	notes := []database.Note{*note}
+	chunks, chunkVectors, err := s.embedNotes(ctx, notes)
+	if err != nil {
+		return nil, nil, err
+	}
+	*note = notes[0]
+	return chunks[0], chunkVectors[0], nil
+}
+
+// embedNotes is embedNote for several notes at once, embedding the chunks of
+// all of them together so the embedder can batch the requests. The results
+// are indexed like notes.
+func (s *NoteService) embedNotes(ctx context.Context, notes []database.Note) ([][]database.NoteChunk, [][][]float64, error) {
+	parts := make([][]chunker.Chunk, len(notes))
+	var texts []string
+	for i, note := range notes {
+		parts[i] = chunker.Split(note.Content, s.ChunkOptions)
+		for _, part := range parts[i] {
+			texts = append(texts, part.Text)
+		}
+	}
+
+	embeddings, err := client.GenerateEmbeddings(ctx, s.Embedder, texts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("AI generation failed: %w", err)
+	}
+
+	chunks := make([][]database.NoteChunk, len(notes))
+	vectors := make([][][]float64, len(notes))
+	next := 0
+	for i := range notes {
+		chunks[i] = make([]database.NoteChunk, len(parts[i]))
+		vectors[i] = embeddings[next : next+len(parts[i])]
+		next += len(parts[i])
+
+		for j, part := range parts[i] {
+			embeddingBytes, err := database.EncodeVector(vectors[i][j], s.VectorFormat)
+			if err != nil {
+				return nil, nil, fmt.Errorf("vector encoding failed: %w", err)
+			}
+			chunks[i][j] = database.NoteChunk{
+				ChunkIndex:      part.Index,
+				Content:         part.Text,
+				EmbeddingVector: embeddingBytes,
+			}
+		}
+
+		mean := meanVector(vectors[i])
+		notes[i].EmbeddingVector, err = database.EncodeVector(mean, s.VectorFormat)
+		if err != nil {
+			return nil, nil, fmt.Errorf("vector encoding failed: %w", err)
+		}
+		notes[i].EmbeddingModel = s.Embedder.Model()
+		notes[i].EmbeddingDim = len(mean)
+	}
+	return chunks, vectors, nil
+}
+
+// meanVector averages unit-normalised vectors so long chunks don't dominate
+// the note-level embedding.
+func meanVector(vectors [][]float64) []float64 {
+	if len(vectors) == 1 {
+		return vectors[0]
+	}
+
+	mean := make([]float64, len(vectors[0]))
+	for _, vector := range vectors {
+		norm := floats.Norm(vector, 2)
+		if norm == 0 {
+			continue
+		}
+		floats.AddScaled(mean, 1/norm, vector)
+	}
+	floats.Scale(1/float64(len(vectors)), mean)
+	return mean
+}
+
+func isJSONObject(raw string) bool {
+	var obj map[string]any
+	return json.Unmarshal([]byte(raw), &obj) == nil && obj != nil
+}
+
+func (s *NoteService) SemanticSearch(ctx context.Context, query string, opts database.SearchOptions) ([]database.Note, error) {
+	opts, err := normalizeSearchOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.semanticSearch(ctx, query, opts)
+}
+
+func (s *NoteService) semanticSearch(ctx context.Context, query string, opts database.SearchOptions) ([]database.Note, error) {
+	embeddingFloats, err := s.Embedder.GenerateEmbedding(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("AI generation failed: %w", err)
 	}
 
-	embeddingBytes, err := database.FloatSliceToBytes(embeddingFloats)
+	embeddingBytes, err := database.EncodeVector(embeddingFloats, database.VectorFloat32)
 	if err != nil {
 		return nil, fmt.Errorf("vector encoding failed: %w", err)
 	}
 
-	notes, err := s.DBManager.SearchNotes(embeddingBytes)
+	// The index has no notion of tags, so filtered searches scan exactly.
+	if opts.NoteFilter.IsEmpty() {
+		if notes, ok := s.indexSearch(embeddingFloats, opts); ok {
+			return notes, nil
+		}
+	}
+
+	space := database.VectorSpace{Model: s.Embedder.Model(), Dim: len(embeddingFloats)}
+	notes, err := s.DBManager.SearchNotes(embeddingBytes, space, opts)
 	if err != nil {
 		return nil, fmt.Errorf("db search failed: %w", err)
 	}
 	return notes, nil
 }
 
-func (s *NoteService) GetAll() ([]database.Note, error) {
-	return s.DBManager.GetAllNotes()
+// indexSearch answers a query from the vector index, which holds chunk
+// embeddings, keeping each note's best chunk as its snippet. It reports false
+// when the caller should fall back to an exact scan.
+func (s *NoteService) indexSearch(vector []float64, opts database.SearchOptions) ([]database.Note, bool) {
+	hnsw := s.annIndex()
+	if hnsw == nil || hnsw.Len() == 0 {
+		return nil, false
+	}
+
+	// Several chunks of one note can crowd the top results, so over-fetch
+	// chunks to still end up with enough distinct notes.
+	wanted := opts.Offset + opts.Limit
+	results, err := hnsw.Search(vector, wanted*chunkOversampling)
+	if err != nil {
+		slog.Warn("Vector index search failed, using exact search", "error", err)
+		return nil, false
+	}
+
+	chunkIds := make([]int, len(results))
+	for i, result := range results {
+		chunkIds[i] = result.Id
+	}
+	chunks, err := s.DBManager.GetChunksByIds(chunkIds)
+	if err != nil || len(chunks) != len(results) {
+		slog.Warn("Vector index returned unknown chunks, using exact search", "error", err)
+		return nil, false
+	}
+
+	noteIds := make([]int, 0, wanted)
+	best := make(map[int]index.Result, wanted)
+	for _, result := range results {
+		if opts.MaxDistance > 0 && result.Distance > opts.MaxDistance {
+			break
+		}
+		noteId := chunks[result.Id].NoteId
+		if _, seen := best[noteId]; seen {
+			continue
+		}
+		best[noteId] = result
+		noteIds = append(noteIds, noteId)
+		if len(noteIds) == wanted {
+			break
+		}
+	}
+	noteIds = noteIds[min(opts.Offset, len(noteIds)):]
+
+	notes, err := s.DBManager.GetNotesByIds(noteIds)
+	if err != nil || len(notes) != len(noteIds) {
+		slog.Warn("Vector index returned unknown notes, using exact search", "error", err)
+		return nil, false
+	}
+	for i := range notes {
+		result := best[notes[i].Id]
+		notes[i].Distance = result.Distance
+		notes[i].Snippet = chunks[result.Id].Content
+	}
+	return notes, true
+}
+
+// NoteUpdate lists the fields to change on an existing note. Nil fields are
+// left as they are.
+type NoteUpdate struct {
+	Content   *string
+	SourceURL *string
+	Title     *string
+	SiteName  *string
+	Author    *string
+	Metadata  *string
+}
+
+// UpdateNote applies update to the note with the given id and returns the
+// stored result, or an error matching ErrNoteNotFound if no such note exists.
+// The note is only re-chunked and re-embedded when its content actually
+// changes.
+func (s *NoteService) UpdateNote(ctx context.Context, id int, update NoteUpdate) (*database.Note, error) {
+	existing, err := s.DBManager.GetNoteById(id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, fmt.Errorf("%w: %d", ErrNoteNotFound, id)
+	}
+
+	note := *existing
+	applyString(&note.SourceURL, update.SourceURL)
+	applyString(&note.Title, update.Title)
+	applyString(&note.SiteName, update.SiteName)
+	applyString(&note.Author, update.Author)
+	applyString(&note.Metadata, update.Metadata)
+	applyString(&note.Content, update.Content)
+
+	if err := validateNote(note); err != nil {
+		return nil, err
+	}
+
+	var chunks []database.NoteChunk
+	var chunkVectors [][]float64
+	contentChanged := note.Content != existing.Content
+	if contentChanged {
+		chunks, chunkVectors, err = s.embedNote(ctx, &note)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.ensureIndexLoaded()
+	var oldChunkIds []int
+	if contentChanged {
+		oldChunkIds, err = s.DBManager.GetChunkIdsForNote(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.DBManager.UpdateNote(note, chunks); err != nil {
+		return nil, fmt.Errorf("db update failed: %w", err)
+	}
+
+	for _, chunkId := range oldChunkIds {
+		s.indexRemove(chunkId)
+	}
+	for i, chunk := range chunks {
+		s.indexAdd(chunk.Id, chunkVectors[i])
+	}
+	s.publishNote(EventNoteUpdated, id)
+
+	return s.DBManager.GetNoteById(id)
+}
+
+func applyString(field *string, value *string) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+func (s *NoteService) GetAll(filter database.NoteFilter) ([]database.Note, error) {
+	return s.DBManager.GetAllNotes(filter)
+}
+
+// ListNotes returns one page of notes in id order along with the cursor for
+// the next page, which is empty once there are no more notes. An empty
+// cursor starts from the beginning.
+func (s *NoteService) ListNotes(filter database.NoteFilter, cursor string, limit int) ([]database.Note, string, error) {
+	if limit < 0 || limit > MAX_PAGE_SIZE {
+		return nil, "", invalidf("page size must be between 1 and %d", MAX_PAGE_SIZE)
+	}
+	if limit == 0 {
+		limit = DEFAULT_PAGE_SIZE
+	}
+
+	afterID, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Fetch one extra row to learn whether another page follows.
+	notes, err := s.DBManager.ListNotes(filter, afterID, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(notes) <= limit {
+		return notes, "", nil
+	}
+
+	notes = notes[:limit]
+	return notes, encodeCursor(notes[limit-1].Id), nil
+}
+
+// Cursors are opaque to clients so the paging scheme can change later.
+func encodeCursor(lastID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(lastID)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, invalidf("invalid cursor")
+	}
+	lastID, err := strconv.Atoi(string(raw))
+	if err != nil || lastID < 0 {
+		return 0, invalidf("invalid cursor")
+	}
+	return lastID, nil
 }
 
 func (s *NoteService) GetByID(id int) (*database.Note, error) {
 	return s.DBManager.GetNoteById(id)
 }
 
+// Delete removes the note with the given id, or returns an error matching
+// ErrNoteNotFound if there is none.
 func (s *NoteService) Delete(id int) error {
-	return s.DBManager.DeleteNote(id)
+	if err := s.requireNote(id); err != nil {
+		return err
+	}
+	s.ensureIndexLoaded()
+	chunkIds, err := s.DBManager.GetChunkIdsForNote(id)
+	if err != nil {
+		return err
+	}
+	if err := s.DBManager.DeleteNote(id); err != nil {
+		return err
+	}
+	for _, chunkId := range chunkIds {
+		s.indexRemove(chunkId)
+	}
+	s.publishNote(EventNoteDeleted, id)
+	return nil
 }
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"synapse/database"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCreateNoteKeepsSourceFields(t *testing.T) {
+	s := newTestService(t)
+	// stubEmbedder makes every note a near-duplicate of every other.
+	s.DuplicateThreshold = 0
+	note := database.Note{
+		Content:   "Captured paragraph.",
+		SourceURL: "https://example.com/post",
+		Title:     "A post",
+		SiteName:  "Example",
+		Author:    "Someone",
+		Metadata:  `{"lang":"en"}`,
+	}
+	id, err := s.CreateNote(context.Background(), note)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := s.GetByID(id)
+	if err != nil || saved == nil {
+		t.Fatalf("GetByID = %v, %v", saved, err)
+	}
+	if saved.Content != note.Content || saved.SourceURL != note.SourceURL || saved.Title != note.Title ||
+		saved.SiteName != note.SiteName || saved.Author != note.Author || saved.Metadata != note.Metadata {
+		t.Errorf("saved note = %+v, want the fields of %+v", saved, note)
+	}
+	if saved.EmbeddingModel != "m" || saved.EmbeddingStatus != database.EMBEDDING_READY {
+		t.Errorf("saved note embedded with %q, status %q, want model m, ready", saved.EmbeddingModel, saved.EmbeddingStatus)
+	}
+
+	// Notes without metadata get an empty object.
+	id, err = s.CreateNote(context.Background(), database.Note{Content: "Plain note."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := s.GetByID(id); saved == nil || saved.Metadata != "{}" {
+		t.Errorf("metadata of a plain note = %+v, want {}", saved)
+	}
+}
+
+func TestCreateNoteValidation(t *testing.T) {
+	s := newTestService(t)
+	tests := []struct {
+		name string
+		note database.Note
+	}{
+		{"empty content", database.Note{Content: "  \n"}},
+		{"metadata array", database.Note{Content: "x", Metadata: `["a"]`}},
+		{"metadata not JSON", database.Note{Content: "x", Metadata: `lang=en`}},
+	}
+	for _, tt := range tests {
+		if _, err := s.CreateNote(context.Background(), tt.note); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: error = %v, want ErrInvalid", tt.name, err)
+		}
+	}
+	if got, _ := s.GetByID(1); got != nil {
+		t.Errorf("an invalid note was saved: %+v", got)
+	}
+}
+
+// countingEmbedder is stubEmbedder that counts the inputs it embeds.
+type countingEmbedder struct {
+	stubEmbedder
+	inputs atomic.Int32
+}
+
+func (e *countingEmbedder) GenerateEmbedding(ctx context.Context, input string) ([]float64, error) {
+	e.inputs.Add(1)
+	return e.stubEmbedder.GenerateEmbedding(ctx, input)
+}
+
+func TestUpdateNote(t *testing.T) {
+	s := newTestService(t)
+	embedder := &countingEmbedder{stubEmbedder: stubEmbedder{model: "m", vector: []float64{1, 0}}}
+	s.Embedder = embedder
+	ctx := context.Background()
+
+	id, err := s.CreateNote(ctx, database.Note{Content: "Original text.", Title: "Old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldChunks, err := s.DBManager.GetChunkIdsForNote(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded := embedder.inputs.Load()
+	if _, err := s.DBManager.DB.Exec(`UPDATE notes SET updated_at = datetime('now', '-1 hour') WHERE id = ?`, id); err != nil {
+		t.Fatal(err)
+	}
+	events, unsubscribe := s.Events.Subscribe(0)
+	defer unsubscribe()
+
+	// Changing only the title keeps the embedding.
+	title := "New"
+	note, err := s.UpdateNote(ctx, id, NoteUpdate{Title: &title})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.Title != "New" || note.Content != "Original text." {
+		t.Errorf("note after title change = %+v", note)
+	}
+	if time.Since(note.UpdatedAt) > time.Minute {
+		t.Errorf("updated_at = %v, want it bumped", note.UpdatedAt)
+	}
+	if got := embedder.inputs.Load(); got != embedded {
+		t.Errorf("title change embedded %d inputs, want none", got-embedded)
+	}
+	if chunks, _ := s.DBManager.GetChunkIdsForNote(id); fmt.Sprint(chunks) != fmt.Sprint(oldChunks) {
+		t.Errorf("chunks = %v after a title change, want %v kept", chunks, oldChunks)
+	}
+
+	// Changing the content re-embeds it and replaces its chunks.
+	content := "Rewritten text."
+	note, err = s.UpdateNote(ctx, id, NoteUpdate{Content: &content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.Content != content || note.Title != "New" {
+		t.Errorf("note after content change = %+v", note)
+	}
+	if got := embedder.inputs.Load(); got == embedded {
+		t.Error("content change was not re-embedded")
+	}
+	if chunks, _ := s.DBManager.GetChunkIdsForNote(id); len(chunks) == 0 || fmt.Sprint(chunks) == fmt.Sprint(oldChunks) {
+		t.Errorf("chunks = %v after a content change, want new ones replacing %v", chunks, oldChunks)
+	}
+
+	updates := 0
+	for _, event := range drain(events) {
+		if event.Type == EventNoteUpdated && event.NoteId == id {
+			updates++
+		}
+	}
+	if updates != 2 {
+		t.Errorf("got %d note.updated events, want 2", updates)
+	}
+
+	empty := " "
+	if _, err := s.UpdateNote(ctx, id, NoteUpdate{Content: &empty}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("empty content error = %v, want ErrInvalid", err)
+	}
+	if _, err := s.UpdateNote(ctx, id+1, NoteUpdate{Title: &title}); !errors.Is(err, ErrNoteNotFound) || !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing note error = %v, want ErrNoteNotFound", err)
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"synapse/client"
+	"time"
+)
+
+// DEFAULT_PENDING_INTERVAL is how often the background worker checks for
+// notes that were saved while the embedding server was unavailable.
+const DEFAULT_PENDING_INTERVAL = 30 * time.Second
+
+// EmbedPending embeds the notes that were saved while the embedding server
+// was unavailable. It works like Reindex, except that it first embeds a
+// single note and gives up with an error wrapping client.ErrUnavailable if
+// the server is still unreachable, instead of failing every pending note.
+func (s *NoteService) EmbedPending(ctx context.Context, concurrency int, progress func(ReindexProgress)) (ReindexProgress, error) {
+	if concurrency <= 0 {
+		concurrency = DEFAULT_REINDEX_CONCURRENCY
+	}
+
+	total, err := s.DBManager.CountPendingNotes()
+	if err != nil {
+		return ReindexProgress{}, err
+	}
+	result := ReindexProgress{Total: total}
+	if total == 0 {
+		return result, nil
+	}
+
+	s.ensureIndexLoaded()
+	// Nothing is published while the server is still down, or the worker
+	// would announce a failed job on every tick.
+	published := false
+	defer func() {
+		if published {
+			s.saveIndexLogged()
+			s.publishJob(result.jobProgress(JobEmbedPending, true))
+		}
+	}()
+	afterID := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		// Probe with one note until the server has accepted a request.
+		limit := REINDEX_BATCH_SIZE
+		if result.Embedded == 0 {
+			limit = 1
+		}
+		ids, err := s.DBManager.PendingNoteIds(afterID, limit)
+		if err != nil {
+			return result, err
+		}
+		if len(ids) == 0 {
+			return result, nil
+		}
+		afterID = ids[len(ids)-1]
+
+		batch, lastErr := s.reindexBatch(ctx, ids, concurrency)
+		result.add(batch)
+		if progress != nil {
+			progress(result)
+		}
+		if batch.Embedded == 0 && errors.Is(lastErr, client.ErrUnavailable) {
+			return result, lastErr
+		}
+		published = true
+		s.publishJob(result.jobProgress(JobEmbedPending, false))
+	}
+}
+
+// RunPendingWorker calls EmbedPending every interval until ctx is done, so
+// notes captured while the embedding server was down become searchable once
+// it is back.
+func (s *NoteService) RunPendingWorker(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DEFAULT_PENDING_INTERVAL
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := s.EmbedPending(ctx, DEFAULT_REINDEX_CONCURRENCY, nil)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, client.ErrUnavailable):
+			slog.Debug("Embedding server still unavailable, pending notes left for later", "pending", result.Total)
+		case err != nil:
+			slog.Warn("Failed to embed pending notes", "error", err)
+		case result.Total > 0:
+			slog.Info("Embedded pending notes", "embedded", result.Embedded, "failed", result.Failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
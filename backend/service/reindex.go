@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+const (
+	REINDEX_BATCH_SIZE          = 100
+	DEFAULT_REINDEX_CONCURRENCY = 4
+)
+
+// ReindexProgress counts the notes handled so far by Reindex out of Total.
+// Skipped notes were edited or deleted while being embedded; an edit embeds
+// the note itself, so they need nothing further.
+type ReindexProgress struct {
+	Total    int
+	Embedded int
+	Skipped  int
+	Failed   int
+}
+
+// Reindex re-embeds, with the service's embedder, every note that was embedded
+// with a different model. Notes are processed in id-ordered batches with up to
+// concurrency embedding requests in flight, and each note is committed on its
+// own, so an interrupted run picks up where it stopped. Notes that fail are
+// logged, counted and left for the next run. progress, if set, is called
+// after every batch, and progress is published to Events, ending with a
+// finished event even when there is nothing to do. The vector index is saved
+// once the run ends.
+func (s *NoteService) Reindex(ctx context.Context, concurrency int, progress func(ReindexProgress)) (ReindexProgress, error) {
+	if concurrency <= 0 {
+		concurrency = DEFAULT_REINDEX_CONCURRENCY
+	}
+
+	model := s.Embedder.Model()
+	total, err := s.DBManager.CountNotesNotEmbeddedWith(model)
+	if err != nil {
+		return ReindexProgress{}, err
+	}
+	result := ReindexProgress{Total: total}
+	if total == 0 {
+		s.publishJob(result.jobProgress(JobReindex, true))
+		return result, nil
+	}
+
+	s.ensureIndexLoaded()
+	defer func() {
+		s.saveIndexLogged()
+		s.publishJob(result.jobProgress(JobReindex, true))
+	}()
+	afterID := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		ids, err := s.DBManager.NoteIdsNotEmbeddedWith(model, afterID, REINDEX_BATCH_SIZE)
+		if err != nil {
+			return result, err
+		}
+		if len(ids) == 0 {
+			return result, nil
+		}
+		// Skip past failures so they aren't retried until the next run.
+		afterID = ids[len(ids)-1]
+
+		batch, _ := s.reindexBatch(ctx, ids, concurrency)
+		result.add(batch)
+		s.publishJob(result.jobProgress(JobReindex, false))
+		if progress != nil {
+			progress(result)
+		}
+	}
+}
+
+func (p *ReindexProgress) add(batch ReindexProgress) {
+	p.Embedded += batch.Embedded
+	p.Skipped += batch.Skipped
+	p.Failed += batch.Failed
+}
+
+func (p ReindexProgress) jobProgress(job JobKind, finished bool) JobProgress {
+	return JobProgress{Job: job, Total: p.Total, Done: p.Embedded, Skipped: p.Skipped, Failed: p.Failed, Finished: finished}
+}
+
+// reindexBatch re-embeds the notes in ids, returning how many were embedded,
+// skipped and failed along with the last error seen.
+func (s *NoteService) reindexBatch(ctx context.Context, ids []int, concurrency int) (batch ReindexProgress, lastErr error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, concurrency)
+
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			replaced, err := s.reembedNote(ctx, id)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				slog.Warn("Failed to re-embed note", "id", id, "error", err)
+				batch.Failed++
+				lastErr = err
+			case !replaced:
+				batch.Skipped++
+			default:
+				batch.Embedded++
+			}
+		}()
+	}
+	wg.Wait()
+	return batch, lastErr
+}
+
+// reembedNote embeds the note with the given id again. It returns false if
+// the note was deleted, or edited while it was being embedded, in which case
+// neither the database nor the index is touched.
+func (s *NoteService) reembedNote(ctx context.Context, id int) (bool, error) {
+	note, err := s.DBManager.GetNoteById(id)
+	if err != nil {
+		return false, err
+	}
+	if note == nil {
+		// Deleted since the batch was listed.
+		return false, nil
+	}
+
+	chunks, chunkVectors, err := s.embedNote(ctx, note)
+	if err != nil {
+		return false, err
+	}
+
+	oldChunkIds, err := s.DBManager.GetChunkIdsForNote(id)
+	if err != nil {
+		return false, err
+	}
+	replaced, err := s.DBManager.ReplaceEmbedding(*note, chunks)
+	if err != nil {
+		return false, fmt.Errorf("db update failed: %w", err)
+	}
+	if !replaced {
+		slog.Debug("Note changed while being re-embedded, skipping it", "id", id)
+		return false, nil
+	}
+
+	for _, chunkId := range oldChunkIds {
+		s.indexRemove(chunkId)
+	}
+	for i, chunk := range chunks {
+		s.indexAdd(chunk.Id, chunkVectors[i])
+	}
+	s.publishNote(EventNoteUpdated, id)
+	return true, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"synapse/database"
+)
+
+// Related returns the k notes closest to the note with the given id, or
+// DEFAULT_SEARCH_LIMIT notes when k is 0, excluding the note itself. They are
+// ranked like SemanticSearch with the note's stored embedding as the query,
+// so nothing is re-embedded. filter further restricts the candidates. Notes
+// embedded with a different model than the given note are never compared.
+func (s *NoteService) Related(id int, k int, filter database.NoteFilter) ([]database.Note, error) {
+	opts, err := normalizeSearchOptions(database.SearchOptions{NoteFilter: filter, Limit: k})
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := s.DBManager.GetNoteById(id)
+	if err != nil {
+		return nil, err
+	}
+	if note == nil {
+		return nil, fmt.Errorf("%w: %d", ErrNoteNotFound, id)
+	}
+	if note.EmbeddingStatus == database.EMBEDDING_PENDING || len(note.EmbeddingVector) == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrNoteNotEmbedded, id)
+	}
+
+	vector, err := database.DecodeVector(note.EmbeddingVector)
+	if err != nil {
+		return nil, fmt.Errorf("note %d: %w", id, err)
+	}
+
+	// The note usually finds itself first, so ask for one extra.
+	limit := opts.Limit
+	opts.Limit++
+
+	var notes []database.Note
+	found := false
+	if opts.NoteFilter.IsEmpty() && note.EmbeddingModel == s.Embedder.Model() {
+		notes, found = s.indexSearch(vector, opts)
+	}
+	if !found {
+		query, err := database.EncodeVector(vector, database.VectorFloat32)
+		if err != nil {
+			return nil, fmt.Errorf("vector encoding failed: %w", err)
+		}
+		space := database.VectorSpace{Model: note.EmbeddingModel, Dim: len(vector)}
+		if notes, err = s.DBManager.SearchNotes(query, space, opts); err != nil {
+			return nil, fmt.Errorf("db search failed: %w", err)
+		}
+	}
+
+	related := make([]database.Note, 0, len(notes))
+	for _, candidate := range notes {
+		if candidate.Id != id {
+			related = append(related, candidate)
+		}
+	}
+	return related[:min(len(related), limit)], nil
+}
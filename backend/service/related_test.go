@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"synapse/database"
+	"testing"
+)
+
+func TestRelated(t *testing.T) {
+	s := newTestService(t)
+	source := saveEmbeddedNote(t, s, "source", []float64{1, 0})
+	near := saveEmbeddedNote(t, s, "near", []float64{1, 0.1})
+	middle := saveEmbeddedNote(t, s, "middle", []float64{1, 1})
+	far := saveEmbeddedNote(t, s, "far", []float64{0, 1})
+	// Notes embedded with another model are never compared.
+	stranger := saveEmbeddedNote(t, s, "stranger", []float64{1, 0})
+	if _, err := s.DBManager.DB.Exec(`UPDATE notes SET embedding_model = 'other' WHERE id = ?`, stranger); err != nil {
+		t.Fatal(err)
+	}
+
+	notes, err := s.Related(source, 0, database.NoteFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(noteIds(notes)) != fmt.Sprint([]int{near, middle, far}) {
+		t.Errorf("related = %v, want every other note closest first", noteIds(notes))
+	}
+
+	notes, err = s.Related(source, 2, database.NoteFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(noteIds(notes)) != fmt.Sprint([]int{near, middle}) {
+		t.Errorf("two related = %v, want %v", noteIds(notes), []int{near, middle})
+	}
+
+	if err := s.DBManager.AddTags(far, []string{"keep"}); err != nil {
+		t.Fatal(err)
+	}
+	notes, err = s.Related(source, 0, database.NoteFilter{Tags: []string{"keep"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(noteIds(notes)) != fmt.Sprint([]int{far}) {
+		t.Errorf("related tagged keep = %v, want only %d", noteIds(notes), far)
+	}
+}
+
+func TestRelatedErrors(t *testing.T) {
+	s := newTestService(t)
+	embedded := saveEmbeddedNote(t, s, "embedded", []float64{1, 0})
+	pending, err := s.DBManager.SaveNote(database.Note{Content: "pending", EmbeddingStatus: database.EMBEDDING_PENDING}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Related(pending+1, 0, database.NoteFilter{}); !errors.Is(err, ErrNoteNotFound) || !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing note error = %v, want ErrNoteNotFound", err)
+	}
+	if _, err := s.Related(pending, 0, database.NoteFilter{}); !errors.Is(err, ErrNoteNotEmbedded) || !errors.Is(err, ErrConflict) {
+		t.Errorf("pending note error = %v, want ErrNoteNotEmbedded", err)
+	}
+	if _, err := s.Related(embedded, MAX_SEARCH_LIMIT+1, database.NoteFilter{}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("oversized k error = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"synapse/database"
+)
+
+type SearchMode string
+
+const (
+	SearchModeSemantic SearchMode = "semantic"
+	SearchModeKeyword  SearchMode = "keyword"
+	SearchModeHybrid   SearchMode = "hybrid"
+)
+
+const MAX_SEARCH_LIMIT = 100
+
+const (
+	// rrfK dampens the contribution of top ranks in reciprocal rank fusion;
+	// 60 is the value from the original RRF paper.
+	rrfK = 60
+	// hybridCandidates is how many results each ranking contributes before
+	// fusion, so notes ranked moderately by both lists can still surface.
+	hybridCandidates = 50
+)
+
+func ParseSearchMode(mode string) (SearchMode, error) {
+	switch SearchMode(mode) {
+	case "", SearchModeSemantic:
+		return SearchModeSemantic, nil
+	case SearchModeKeyword:
+		return SearchModeKeyword, nil
+	case SearchModeHybrid:
+		return SearchModeHybrid, nil
+	default:
+		return "", invalidf("unknown search mode %q (expected %s, %s or %s)",
+			mode, SearchModeKeyword, SearchModeSemantic, SearchModeHybrid)
+	}
+}
+
+// normalizeSearchOptions applies the default limit and rejects windows that
+// make no sense.
+func normalizeSearchOptions(opts database.SearchOptions) (database.SearchOptions, error) {
+	switch {
+	case opts.Limit < 0 || opts.Limit > MAX_SEARCH_LIMIT:
+		return opts, invalidf("search limit must be between 1 and %d", MAX_SEARCH_LIMIT)
+	case opts.Offset < 0:
+		return opts, invalidf("search offset must not be negative")
+	case opts.MaxDistance < 0:
+		return opts, invalidf("max distance must not be negative")
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DEFAULT_SEARCH_LIMIT
+	}
+	return opts, nil
+}
+
+// Search dispatches query to the ranking selected by mode.
+func (s *NoteService) Search(ctx context.Context, query string, mode SearchMode, opts database.SearchOptions) ([]database.Note, error) {
+	switch mode {
+	case SearchModeKeyword:
+		return s.KeywordSearch(query, opts)
+	case SearchModeHybrid:
+		return s.HybridSearch(ctx, query, opts)
+	default:
+		return s.SemanticSearch(ctx, query, opts)
+	}
+}
+
+// KeywordSearch ranks notes by BM25 over their content and title. BM25
+// scores are not distances, so opts.MaxDistance is ignored.
+func (s *NoteService) KeywordSearch(query string, opts database.SearchOptions) ([]database.Note, error) {
+	opts, err := normalizeSearchOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := s.DBManager.KeywordSearchNotes(query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("db keyword search failed: %w", err)
+	}
+	return notes, nil
+}
+
+// HybridSearch fuses the keyword and semantic rankings with reciprocal rank
+// fusion. Each note's Score is its fused score; Distance is kept from the
+// semantic ranking when the note appeared there. opts.MaxDistance only
+// restricts the semantic candidates.
+func (s *NoteService) HybridSearch(ctx context.Context, query string, opts database.SearchOptions) ([]database.Note, error) {
+	opts, err := normalizeSearchOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := database.SearchOptions{
+		NoteFilter:  opts.NoteFilter,
+		Limit:       max(hybridCandidates, opts.Offset+opts.Limit),
+		MaxDistance: opts.MaxDistance,
+	}
+
+	semantic, err := s.semanticSearch(ctx, query, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates.MaxDistance = 0
+	keyword, err := s.DBManager.KeywordSearchNotes(query, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("db keyword search failed: %w", err)
+	}
+
+	fused := fuseRankings(semantic, keyword)
+	return fused[min(opts.Offset, len(fused)):min(opts.Offset+opts.Limit, len(fused))], nil
+}
+
+func fuseRankings(rankings ...[]database.Note) []database.Note {
+	fused := make(map[int]*database.Note)
+	for _, ranking := range rankings {
+		for rank, note := range ranking {
+			entry, ok := fused[note.Id]
+			if !ok {
+				note.Score = 0
+				entry = &note
+				fused[note.Id] = entry
+			}
+			if note.Distance != 0 {
+				entry.Distance = note.Distance
+			}
+			entry.Score += 1.0 / float64(rrfK+rank+1)
+		}
+	}
+
+	notes := make([]database.Note, 0, len(fused))
+	for _, note := range fused {
+		notes = append(notes, *note)
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		if notes[i].Score != notes[j].Score {
+			return notes[i].Score > notes[j].Score
+		}
+		return notes[i].Id < notes[j].Id
+	})
+	return notes
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"synapse/database"
+	"testing"
+)
+
+func TestSemanticSearchWindow(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+
+	// The stub embeds the query as [1, 0], so notes rank in this order.
+	var ids []int
+	for i, vector := range [][]float64{{1, 0}, {1, 0.2}, {1, 1}, {0, 1}} {
+		ids = append(ids, saveEmbeddedNote(t, s, fmt.Sprintf("note %d", i), vector))
+	}
+
+	tests := []struct {
+		name string
+		opts database.SearchOptions
+		want []int
+	}{
+		{"default limit", database.SearchOptions{}, ids},
+		{"limit", database.SearchOptions{Limit: 2}, ids[:2]},
+		{"offset", database.SearchOptions{Limit: 2, Offset: 1}, ids[1:3]},
+		{"offset past the end", database.SearchOptions{Offset: 10}, nil},
+		{"max distance", database.SearchOptions{MaxDistance: 0.5}, ids[:3]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notes, err := s.SemanticSearch(ctx, "query", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(noteIds(notes)) != fmt.Sprint(tt.want) {
+				t.Errorf("results = %v, want %v", noteIds(notes), tt.want)
+			}
+		})
+	}
+
+	for _, opts := range []database.SearchOptions{{Limit: -1}, {Limit: MAX_SEARCH_LIMIT + 1}, {Offset: -1}, {MaxDistance: -0.1}} {
+		if _, err := s.SemanticSearch(ctx, "query", opts); !errors.Is(err, ErrInvalid) {
+			t.Errorf("SemanticSearch(%+v) error = %v, want ErrInvalid", opts, err)
+		}
+	}
+}
+
+func TestListNotesPages(t *testing.T) {
+	s := newTestService(t)
+	var ids []int
+	for i := range 5 {
+		ids = append(ids, saveEmbeddedNote(t, s, fmt.Sprintf("note %d", i), []float64{1, 0}))
+	}
+
+	var pages [][]int
+	cursor := ""
+	for {
+		notes, next, err := s.ListNotes(database.NoteFilter{}, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, noteIds(notes))
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	want := [][]int{ids[0:2], ids[2:4], ids[4:5]}
+	if fmt.Sprint(pages) != fmt.Sprint(want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+
+	// A page that exactly fills the limit has no next cursor.
+	notes, next, err := s.ListNotes(database.NoteFilter{}, "", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 5 || next != "" {
+		t.Errorf("full listing returned %d notes and cursor %q, want 5 and none", len(notes), next)
+	}
+
+	for _, tt := range []struct {
+		cursor string
+		limit  int
+	}{{"not base64!", 2}, {encodeCursor(-1), 2}, {"", -1}, {"", MAX_PAGE_SIZE + 1}} {
+		if _, _, err := s.ListNotes(database.NoteFilter{}, tt.cursor, tt.limit); !errors.Is(err, ErrInvalid) {
+			t.Errorf("ListNotes(%q, %d) error = %v, want ErrInvalid", tt.cursor, tt.limit, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"synapse/database"
+	"sync"
+	"testing"
+)
+
+var registerDriver sync.Once
+
+// stubEmbedder embeds every input as the same vector.
+type stubEmbedder struct {
+	model  string
+	vector []float64
+}
+
+func (e stubEmbedder) Model() string { return e.model }
+
+func (e stubEmbedder) GenerateEmbedding(ctx context.Context, input string) ([]float64, error) {
+	return e.vector, nil
+}
+
+// newTestService returns a service over a migrated database in a temporary
+// directory, embedding with model "m".
+func newTestService(t *testing.T) *NoteService {
+	t.Helper()
+	registerDriver.Do(database.RegisterCustomDriver)
+
+	dir := t.TempDir()
+	manager, err := database.Initialize(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	t.Cleanup(func() { manager.DB.Close() })
+	return NewNoteService(manager, stubEmbedder{model: "m", vector: []float64{1, 0}}, filepath.Join(dir, "test.db.hnsw"))
+}
+
+// saveEmbeddedNote stores a note embedded as vector with model "m", as a
+// single chunk with the same vector.
+func saveEmbeddedNote(t *testing.T, s *NoteService, content string, vector []float64) int {
+	t.Helper()
+	encoded, err := database.EncodeVector(vector, database.VectorFloat32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.DBManager.SaveNote(database.Note{
+		Content:         content,
+		EmbeddingVector: encoded,
+		EmbeddingModel:  "m",
+		EmbeddingDim:    len(vector),
+	}, []database.NoteChunk{{Content: content, EmbeddingVector: encoded}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"synapse/database"
+)
+
+// AddTags attaches tags to an existing note.
+func (s *NoteService) AddTags(noteId int, tags []string) error {
+	if err := s.requireNote(noteId); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return invalidf("at least one tag is required")
+	}
+	if err := s.DBManager.AddTags(noteId, tags); err != nil {
+		return err
+	}
+	s.publishNote(EventNoteUpdated, noteId)
+	return nil
+}
+
+// RemoveTags detaches tags from an existing note.
+func (s *NoteService) RemoveTags(noteId int, tags []string) error {
+	if err := s.requireNote(noteId); err != nil {
+		return err
+	}
+	if err := s.DBManager.RemoveTags(noteId, tags); err != nil {
+		return err
+	}
+	s.publishNote(EventNoteUpdated, noteId)
+	return nil
+}
+
+func (s *NoteService) ListTags() ([]database.Tag, error) {
+	return s.DBManager.ListTags()
+}
+
+func (s *NoteService) requireNote(id int) error {
+	note, err := s.DBManager.GetNoteById(id)
+	if err != nil {
+		return err
+	}
+	if note == nil {
+		return fmt.Errorf("%w: %d", ErrNoteNotFound, id)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"synapse/database"
+	"testing"
+)
+
+// noteIds returns the ids of notes in order.
+func noteIds(notes []database.Note) []int {
+	ids := make([]int, len(notes))
+	for i, note := range notes {
+		ids[i] = note.Id
+	}
+	return ids
+}
+
+func TestTagFlow(t *testing.T) {
+	s := newTestService(t)
+	s.DuplicateThreshold = 0
+	ctx := context.Background()
+
+	k8s, err := s.CreateNote(ctx, database.Note{Content: "Pods and deployments.", Tags: []string{"Kubernetes ", "ops"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.CreateNote(ctx, database.Note{Content: "Sourdough starter."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Names are normalised, and adding a tag twice is harmless.
+	if err := s.AddTags(other, []string{"Baking", "OPS", "ops"}); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := s.ListTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(tags) != "[{baking 1} {kubernetes 1} {ops 2}]" {
+		t.Errorf("tags = %v, want baking, kubernetes and ops on two notes", tags)
+	}
+
+	note, err := s.GetByID(k8s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(note.Tags) != "[kubernetes ops]" {
+		t.Errorf("note tags = %v, want [kubernetes ops]", note.Tags)
+	}
+
+	filter := database.NoteFilter{Tags: []string{"kubernetes"}}
+	notes, err := s.GetAll(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(noteIds(notes)) != fmt.Sprint([]int{k8s}) {
+		t.Errorf("GetAll tagged kubernetes = %v, want only note %d", noteIds(notes), k8s)
+	}
+	notes, err = s.SemanticSearch(ctx, "containers", database.SearchOptions{NoteFilter: filter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(noteIds(notes)) != fmt.Sprint([]int{k8s}) {
+		t.Errorf("search tagged kubernetes = %v, want only note %d", noteIds(notes), k8s)
+	}
+	// Every tag in the filter must match.
+	notes, err = s.GetAll(database.NoteFilter{Tags: []string{"ops", "baking"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(noteIds(notes)) != fmt.Sprint([]int{other}) {
+		t.Errorf("GetAll tagged ops and baking = %v, want only note %d", noteIds(notes), other)
+	}
+
+	// Removing the last use of a tag deletes it.
+	if err := s.RemoveTags(other, []string{"baking", "ops"}); err != nil {
+		t.Fatal(err)
+	}
+	tags, err = s.ListTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(tags) != "[{kubernetes 1} {ops 1}]" {
+		t.Errorf("tags after removal = %v, want kubernetes and ops on one note each", tags)
+	}
+
+	if err := s.AddTags(k8s, nil); !errors.Is(err, ErrInvalid) {
+		t.Errorf("AddTags without tags error = %v, want ErrInvalid", err)
+	}
+	if err := s.AddTags(other+1, []string{"x"}); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("AddTags on a missing note error = %v, want ErrNoteNotFound", err)
+	}
+	if err := s.RemoveTags(other+1, []string{"x"}); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("RemoveTags on a missing note error = %v, want ErrNoteNotFound", err)
+	}
+}
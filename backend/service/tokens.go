@@ -0,0 +1,113 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"synapse/database"
+)
+
+// TokenScope is what an API token may do.
+type TokenScope string
+
+const (
+	// ScopeRead allows reading notes, searching and asking questions.
+	ScopeRead TokenScope = "read"
+	// ScopeWrite additionally allows creating, changing and deleting notes.
+	ScopeWrite TokenScope = "write"
+)
+
+// tokenPrefix marks Synapse API tokens so they are easy to recognise, e.g.
+// by secret scanners.
+const tokenPrefix = "syn_"
+
+var ErrInvalidToken = errors.New("invalid API token")
+
+func ParseTokenScope(scope string) (TokenScope, error) {
+	switch TokenScope(scope) {
+	case ScopeRead, ScopeWrite:
+		return TokenScope(scope), nil
+	default:
+		return "", invalidf("unknown token scope %q (expected %s or %s)", scope, ScopeRead, ScopeWrite)
+	}
+}
+
+// Allows reports whether a token with this scope may make a request with the
+// given HTTP method. Read tokens are limited to methods that change nothing;
+// POST /api/search and /api/ask are reads that use POST, so callers say so
+// with readOnly.
+func (scope TokenScope) Allows(method string, readOnly bool) bool {
+	if scope == ScopeWrite {
+		return true
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return readOnly
+}
+
+// CreateToken generates a new API token with the given scope and stores its
+// hash. The token itself is returned only here and cannot be recovered.
+func (s *NoteService) CreateToken(name string, scope TokenScope) (string, database.APIToken, error) {
+	if _, err := ParseTokenScope(string(scope)); err != nil {
+		return "", database.APIToken{}, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", database.APIToken{}, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	stored, err := s.DBManager.SaveAPIToken(name, token[:len(tokenPrefix)+6], hashToken(token), string(scope))
+	if err != nil {
+		return "", database.APIToken{}, err
+	}
+	return token, stored, nil
+}
+
+// Authenticate returns the stored record of token, or ErrInvalidToken if it
+// is not a known token.
+func (s *NoteService) Authenticate(token string) (*database.APIToken, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+	stored, err := s.DBManager.FindAPITokenByHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, ErrInvalidToken
+	}
+	return stored, nil
+}
+
+func (s *NoteService) ListTokens() ([]database.APIToken, error) {
+	return s.DBManager.ListAPITokens()
+}
+
+// RevokeToken deletes the token with the given id so it is refused from then
+// on.
+func (s *NoteService) RevokeToken(id int) error {
+	deleted, err := s.DBManager.DeleteAPIToken(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return notFoundf("token with ID %d not found", id)
+	}
+	return nil
+}
+
+// Tokens are long and random, so a plain SHA-256 is enough; a slow password
+// hash would only add latency to every request.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
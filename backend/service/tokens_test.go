@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTokenScopeAllows(t *testing.T) {
+	tests := []struct {
+		scope    TokenScope
+		method   string
+		readOnly bool
+		want     bool
+	}{
+		{ScopeRead, http.MethodGet, false, true},
+		{ScopeRead, http.MethodHead, false, true},
+		{ScopeRead, http.MethodOptions, false, true},
+		{ScopeRead, http.MethodPost, false, false},
+		{ScopeRead, http.MethodPost, true, true},
+		{ScopeRead, http.MethodPut, false, false},
+		{ScopeRead, http.MethodDelete, false, false},
+		{ScopeWrite, http.MethodPost, false, true},
+		{ScopeWrite, http.MethodDelete, false, true},
+		{ScopeWrite, http.MethodGet, false, true},
+	}
+	for _, tt := range tests {
+		if got := tt.scope.Allows(tt.method, tt.readOnly); got != tt.want {
+			t.Errorf("%s.Allows(%s, %v) = %v, want %v", tt.scope, tt.method, tt.readOnly, got, tt.want)
+		}
+	}
+}
+
+func TestTokenLifecycle(t *testing.T) {
+	s := newTestService(t)
+
+	if _, _, err := s.CreateToken("bad", "admin"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unknown scope error = %v, want ErrInvalid", err)
+	}
+
+	token, stored, err := s.CreateToken("laptop", ScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, tokenPrefix) || !strings.HasPrefix(token, stored.Prefix) {
+		t.Errorf("token %q does not start with %q and its stored prefix %q", token, tokenPrefix, stored.Prefix)
+	}
+
+	authenticated, err := s.Authenticate(token)
+	if err != nil || authenticated.Id != stored.Id || authenticated.Scope != string(ScopeRead) {
+		t.Fatalf("Authenticate = %+v, %v, want token %d with read scope", authenticated, err, stored.Id)
+	}
+	for _, bad := range []string{"", "syn_unknown", strings.TrimPrefix(token, tokenPrefix)} {
+		if _, err := s.Authenticate(bad); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Authenticate(%q) error = %v, want ErrInvalidToken", bad, err)
+		}
+	}
+
+	if err := s.RevokeToken(stored.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("revoked token error = %v, want ErrInvalidToken", err)
+	}
+	if err := s.RevokeToken(stored.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second revoke error = %v, want ErrNotFound", err)
+	}
+}
@@ -6,46 +6,65 @@ chrome.runtime.onInstalled.addListener(() => {
   });
 });
 
-chrome.contextMenus.onClicked.addListener((info, tab) => {
+chrome.contextMenus.onClicked.addListener(async (info, tab) => {
   const tabId = tab.id;
   const tabURL = tab.url;
 
   if (info.menuItemId === "saveSelectedText") {
     const selectedText = info.selectionText;
 
-    chrome.scripting.executeScript(
-      {
+    try {
+      await chrome.scripting.executeScript({
         target: { tabId },
         files: ["Readability.js"],
-      },
-      () => {
-        chrome.scripting.executeScript({
-          target: { tabId },
-          func: (text, url) => {
-            const docClone = document.cloneNode(true);
-            const article = new Readability(docClone).parse();
+      });
+      const [{ result: title }] = await chrome.scripting.executeScript({
+        target: { tabId },
+        func: () => {
+          const docClone = document.cloneNode(true);
+          const article = new Readability(docClone).parse();
+          return article ? article.title : document.title;
+        },
+      });
 
-            fetch("http://localhost:8080/api/notes", {
-              method: "POST",
-              body: JSON.stringify({
-                url: url,
-                title: article.title,
-                content: text,
-              }),
-              headers: {
-                "Content-type": "application/json; charset=UTF-8",
-              },
-            })
-              .then((response) => response.json())
-              .then((jsonResponse) => console.log(jsonResponse))
-              .catch((error) => {
-                console.error("Fetch error:", error.message);
-                alert(`Failed to save article: ${error.message}`);
-              });
-          },
-          args: [selectedText, tabURL],
-        });
-      },
-    );
+      // The request is made here rather than in the page, so it comes from
+      // the extension's origin instead of the page's and isn't subject to
+      // the page's CORS rules.
+      const jsonResponse = await saveNote({
+        url: tabURL,
+        title: title,
+        content: selectedText,
+      });
+      console.log(jsonResponse);
+    } catch (error) {
+      console.error("Fetch error:", error.message);
+      chrome.scripting.executeScript({
+        target: { tabId },
+        func: (message) => alert(`Failed to save article: ${message}`),
+        args: [error.message],
+      });
+    }
   }
 });
+
+async function saveNote(note) {
+  const { apiToken } = await chrome.storage.local.get("apiToken");
+  const response = await fetch("http://localhost:8080/api/notes", {
+    method: "POST",
+    body: JSON.stringify(note),
+    headers: {
+      "Content-type": "application/json; charset=UTF-8",
+      Authorization: `Bearer ${apiToken || ""}`,
+    },
+  });
+
+  if (response.status === 401 || response.status === 403) {
+    throw new Error("set a valid write token in the Synapse extension options");
+  }
+  const body = await response.json();
+  if (!response.ok) {
+    // Errors come as {code, message, details}.
+    throw new Error(body.message);
+  }
+  return body;
+}
@@ -0,0 +1,12 @@
+const tokenInput = document.getElementById("apiToken");
+const status = document.getElementById("status");
+
+document.addEventListener("DOMContentLoaded", async () => {
+  const { apiToken } = await chrome.storage.local.get("apiToken");
+  tokenInput.value = apiToken || "";
+});
+
+document.getElementById("save").addEventListener("click", async () => {
+  await chrome.storage.local.set({ apiToken: tokenInput.value.trim() });
+  status.textContent = "Saved.";
+});
@@ -5,7 +5,7 @@ document.addEventListener("DOMContentLoaded", async () => {
 
     const notesContainer = document.getElementById("notesContainer");
 
-    if (!notes || notes.length === 0) {
+    if (!notes || !notes.data || notes.data.length === 0) {
       notesContainer.innerHTML = "<p>No notes found.</p>";
       return;
     }
@@ -14,22 +14,35 @@ document.addEventListener("DOMContentLoaded", async () => {
     notes.data.forEach((note) => {
       const div = document.createElement("div");
       div.className = "note";
-      div.textContent = note.title;
+      div.textContent = note.title || note.content;
       notesContainer.appendChild(div);
     });
   } catch (err) {
     console.error(err);
+    if (err.status === 401 || err.status === 403) {
+      document.getElementById("notesContainer").innerHTML =
+        '<p>Set a valid API token in the <a href="#" id="openOptions">extension options</a>.</p>';
+      document
+        .getElementById("openOptions")
+        .addEventListener("click", () => chrome.runtime.openOptionsPage());
+    }
   }
 });
 
 async function fetchNotes() {
+  const { apiToken } = await chrome.storage.local.get("apiToken");
   const response = await fetch("http://localhost:8080/api/notes", {
     method: "GET",
-    headers: { "Content-Type": "application/json" },
+    headers: {
+      "Content-Type": "application/json",
+      Authorization: `Bearer ${apiToken || ""}`,
+    },
   });
 
   if (!response.ok) {
-    throw new Error("Failed to fetch notes");
+    const error = new Error("Failed to fetch notes");
+    error.status = response.status;
+    throw error;
   }
 
   return await response.json();